@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/config"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/delivery"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/destination"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/downloader"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/kindle"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/maintenance"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/metrics"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/readinglist"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/search"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/secrets"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/subscription"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
+)
+
+// app holds the configuration and components shared by the subcommands.
+// Components are created on first use, so each command only connects to
+// what it needs.
+type app struct {
+	cfg      *config.Config
+	logger   *slog.Logger
+	redactor *logging.Redactor
+	resolver *secrets.Resolver
+	metrics  *metrics.Metrics
+
+	rawRepo     user.Repository // Uninstrumented, for optional interfaces such as user.Migrator
+	repo        user.Repository
+	userManager *user.Manager
+	contexts    *search.ContextStore
+	readingList *readinglist.Manager
+	follows     *subscription.Manager
+	botAPI      *tgbotapi.BotAPI
+	delivery    *delivery.Service
+	maintenance *maintenance.Service
+}
+
+// newApp resolves secrets and sets up logging
+func newApp(cfg *config.Config) (*app, error) {
+	// Resolve Key Vault references in secrets
+	resolver := secrets.NewResolver(secrets.NewKeyVaultClient(secrets.NewManagedIdentityTokenSource(cfg.KeyVaultClientID, nil), nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := cfg.ResolveSecrets(ctx, resolver); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets:\n%w", err)
+	}
+
+	// Set up logging
+	redactor := logging.NewRedactor(cfg.Secrets())
+	logger, err := logging.New(os.Stderr, logging.Options{
+		Level:    cfg.LogLevel,
+		Format:   cfg.LogFormat,
+		Redactor: redactor,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up logging: %w", err)
+	}
+	slog.SetDefault(logger)
+
+	// Route library logs (including request errors that embed the token) through the redacting logger
+	if err := tgbotapi.SetLogger(slog.NewLogLogger(logger.Handler(), slog.LevelInfo)); err != nil {
+		return nil, fmt.Errorf("failed to set Telegram logger: %w", err)
+	}
+
+	return &app{
+		cfg:      cfg,
+		logger:   logger,
+		redactor: redactor,
+		resolver: resolver,
+		metrics:  metrics.New(),
+	}, nil
+}
+
+// repository returns the user repository, instrumented with metrics
+func (a *app) repository() (user.Repository, error) {
+	if a.repo != nil {
+		return a.repo, nil
+	}
+
+	switch a.cfg.DBType {
+	case "memory":
+		a.rawRepo = user.NewMemoryRepository()
+		a.logger.Info("Using in-memory user repository")
+	case "postgres":
+		// TODO: Implement PostgreSQL repository
+		return nil, fmt.Errorf("PostgreSQL repository not implemented yet")
+	case "cosmos":
+		// TODO: Implement Cosmos DB repository
+		return nil, fmt.Errorf("Cosmos DB repository not implemented yet")
+	default:
+		return nil, fmt.Errorf("unknown database type: %s", a.cfg.DBType)
+	}
+
+	a.repo = metrics.InstrumentRepository(a.rawRepo, a.metrics)
+
+	return a.repo, nil
+}
+
+// users returns the user manager
+func (a *app) users() (*user.Manager, error) {
+	if a.userManager != nil {
+		return a.userManager, nil
+	}
+
+	repo, err := a.repository()
+	if err != nil {
+		return nil, err
+	}
+	a.userManager = user.NewManager(repo)
+
+	destinations, err := destination.NewValidatorFromNames(a.cfg.DestinationNames())
+	if err != nil {
+		return nil, err
+	}
+	a.userManager.SetDestinations(destinations)
+
+	// Search sessions are included in data exports and deletions
+	a.contexts = search.NewContextStore(a.cfg.SearchContextTTL)
+	a.userManager.RegisterDataHolder(a.contexts)
+
+	// Reading lists are kept next to users; only the in-memory store exists so far
+	a.readingList = readinglist.NewManager(readinglist.NewMemoryRepository(), a.cfg.ReadingListMaxBooks)
+	a.userManager.RegisterDataHolder(a.readingList)
+
+	// Author and series follows, likewise in memory only
+	a.follows = subscription.NewManager(subscription.NewMemoryRepository(), a.cfg.MaxFollows)
+	a.userManager.RegisterDataHolder(a.follows)
+
+	return a.userManager, nil
+}
+
+// telegram returns the Telegram bot API client
+func (a *app) telegram() (*tgbotapi.BotAPI, error) {
+	if a.botAPI != nil {
+		return a.botAPI, nil
+	}
+
+	botAPI, err := tgbotapi.NewBotAPI(a.cfg.TelegramBotToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Telegram bot: %w", err)
+	}
+
+	botAPI.Debug = a.cfg.LogLevel == "debug"
+	a.logger.Info("Authorized on Telegram", "account", botAPI.Self.UserName)
+	a.botAPI = botAPI
+
+	return a.botAPI, nil
+}
+
+// deliveryService returns the service sending books to Kindles
+func (a *app) deliveryService() (*delivery.Service, error) {
+	if a.delivery != nil {
+		return a.delivery, nil
+	}
+
+	users, err := a.users()
+	if err != nil {
+		return nil, err
+	}
+
+	if a.cfg.AzureCommunicationConnectionString == "" {
+		return nil, fmt.Errorf("AZURE_COMMUNICATION_CONNECTION_STRING is required to send books")
+	}
+	emailClient, err := kindle.NewACSClient(a.cfg.AzureCommunicationConnectionString, a.cfg.SenderEmail, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	a.delivery = delivery.NewService(
+		users,
+		downloader.NewDownloader(a.cfg.FlibustaURL, nil),
+		kindle.NewSender(emailClient),
+		a.metrics,
+	)
+
+	return a.delivery, nil
+}
+
+// maintenanceService returns the service running maintenance tasks
+func (a *app) maintenanceService() (*maintenance.Service, error) {
+	if a.maintenance != nil {
+		return a.maintenance, nil
+	}
+
+	users, err := a.users()
+	if err != nil {
+		return nil, err
+	}
+
+	a.maintenance = maintenance.NewService(users, a.contexts, a.cfg.InactiveUserPeriod)
+
+	return a.maintenance, nil
+}
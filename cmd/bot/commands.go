@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/bot"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/delivery"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
+)
+
+// migrateCommand brings the repository schema up to date
+func migrateCommand(_ *flag.FlagSet) runFunc {
+	return func(ctx context.Context, a *app, _ []string) error {
+		if _, err := a.repository(); err != nil {
+			return err
+		}
+
+		migrator, ok := a.rawRepo.(user.Migrator)
+		if !ok {
+			slog.Info("Repository has no migrations", "db_type", a.cfg.DBType)
+			return nil
+		}
+
+		if err := migrator.Migrate(ctx); err != nil {
+			return fmt.Errorf("failed to migrate repository: %w", err)
+		}
+
+		slog.Info("Repository migrated", "db_type", a.cfg.DBType)
+		return nil
+	}
+}
+
+// exportUsersCommand writes all users in the portable JSONL export format
+func exportUsersCommand(fs *flag.FlagSet) runFunc {
+	output := fs.String("output", "-", "file to write users to, - for stdout")
+
+	return func(ctx context.Context, a *app, _ []string) (err error) {
+		repo, err := a.repository()
+		if err != nil {
+			return err
+		}
+		warnInMemory(a)
+
+		w := io.Writer(os.Stdout)
+		if *output != "-" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return fmt.Errorf("failed to create output file: %w", err)
+			}
+			defer func() {
+				if cerr := f.Close(); cerr != nil && err == nil {
+					err = fmt.Errorf("failed to write output file: %w", cerr)
+				}
+			}()
+			w = f
+		}
+
+		n, err := user.Export(ctx, repo, w)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("Users exported", "count", n, "output", *output)
+		return nil
+	}
+}
+
+// importUsersCommand reads an export into the configured repository. Together
+// with export it moves users between backends, e.g.
+// bot users export --db-type cosmos | bot users import --db-type postgres
+func importUsersCommand(fs *flag.FlagSet) runFunc {
+	input := fs.String("input", "-", "file to read users from, - for stdin")
+	dryRun := fs.Bool("dry-run", false, "report what would change without writing")
+	onConflict := fs.String("on-conflict", string(user.ConflictSkip), "existing users with different data: skip, overwrite, or newer")
+
+	return func(ctx context.Context, a *app, _ []string) error {
+		policy, err := user.ParseConflictPolicy(*onConflict)
+		if err != nil {
+			return err
+		}
+
+		repo, err := a.repository()
+		if err != nil {
+			return err
+		}
+		warnInMemory(a)
+
+		r := io.Reader(os.Stdin)
+		if *input != "-" {
+			f, err := os.Open(*input)
+			if err != nil {
+				return fmt.Errorf("failed to open input file: %w", err)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		report, err := user.Import(ctx, repo, r, user.ImportOptions{DryRun: *dryRun, OnConflict: policy})
+		for _, c := range report.Conflicts {
+			slog.Warn("Conflict", "telegram_id", c.TelegramID, "fields", c.Fields, "resolution", c.Resolution)
+		}
+		for _, e := range report.Errors {
+			slog.Error("Invalid record", "line", e.Line, "error", e.Err)
+		}
+		slog.Info("Import finished",
+			"dry_run", report.DryRun,
+			"created", report.Created,
+			"updated", report.Updated,
+			"unchanged", report.Unchanged,
+			"skipped", report.Skipped,
+			"conflicts", len(report.Conflicts),
+			"errors", len(report.Errors),
+		)
+		if err != nil {
+			return err
+		}
+
+		if len(report.Errors) > 0 {
+			return fmt.Errorf("%d records could not be imported", len(report.Errors))
+		}
+		return nil
+	}
+}
+
+// warnInMemory warns that the in-memory repository does not outlive the command
+func warnInMemory(a *app) {
+	if a.cfg.DBType == "memory" {
+		slog.Warn("DB_TYPE is memory: users are not shared with the running bot and are lost when the command exits")
+	}
+}
+
+// sendCommand delivers a book to a user's Kindle or Telegram chat
+func sendCommand(fs *flag.FlagSet) runFunc {
+	telegramID := fs.Int64("user", 0, "Telegram ID of the recipient")
+	bookID := fs.String("book", "", "Flibusta book ID")
+	format := fs.String("format", "", "book format to download (default: preferred by the target)")
+	target := fs.String("target", delivery.TargetEmail, "where to send the book: email or telegram")
+
+	return func(ctx context.Context, a *app, _ []string) error {
+		if *telegramID == 0 || *bookID == "" {
+			return errors.New("--user and --book are required")
+		}
+
+		service, err := a.deliveryService()
+		if err != nil {
+			return err
+		}
+
+		if *target == delivery.TargetTelegram {
+			botAPI, err := a.telegram()
+			if err != nil {
+				return err
+			}
+			service.EnableTelegram(bot.NewDocumentSender(botAPI))
+		}
+
+		if err := service.Deliver(ctx, *telegramID, *bookID, *format, *target); err != nil {
+			return fmt.Errorf("failed to send book %s to user %d: %w", *bookID, *telegramID, err)
+		}
+
+		return nil
+	}
+}
+
+// cleanupCommand runs maintenance once and prints the report as JSON. Search
+// sessions live in the serving process, so only users are cleaned; use
+// /cleanup to clean a running bot.
+func cleanupCommand(_ *flag.FlagSet) runFunc {
+	return func(ctx context.Context, a *app, _ []string) error {
+		svc, err := a.maintenanceService()
+		if err != nil {
+			return err
+		}
+		warnInMemory(a)
+
+		report := svc.Run(ctx, "cli")
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+		if len(report.Errors) > 0 {
+			return fmt.Errorf("maintenance finished with %d errors", len(report.Errors))
+		}
+		return nil
+	}
+}
+
+// checkConfigCommand reports whether the configuration is valid. Loading the
+// configuration and resolving secrets happens before any command runs, so
+// reaching this command means both succeeded.
+func checkConfigCommand(_ *flag.FlagSet) runFunc {
+	return func(ctx context.Context, a *app, _ []string) error {
+		fmt.Println("Configuration OK")
+		return nil
+	}
+}
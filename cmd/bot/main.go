@@ -2,234 +2,130 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
-	"net/http"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-
-	"github.com/stpatrick2016/flibusta_kindle_bot/internal/bot"
 	"github.com/stpatrick2016/flibusta_kindle_bot/internal/config"
-	"github.com/stpatrick2016/flibusta_kindle_bot/internal/i18n"
-	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
 )
 
-func main() {
-	// Load configuration
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
-	}
-
-	// Set up logging
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	log.Printf("Starting Flibusta Kindle Bot...")
-	log.Printf("Bot mode: %s", cfg.BotMode)
-	log.Printf("Database type: %s", cfg.DBType)
+// runFunc runs a command with its remaining positional arguments
+type runFunc func(ctx context.Context, a *app, args []string) error
 
-	// Initialize i18n
-	i18nInstance, err := i18n.NewI18n("internal/i18n/locales", "en")
-	if err != nil {
-		log.Fatalf("Failed to initialize i18n: %v", err)
-	}
-	log.Printf("Loaded translations for languages: %v", i18nInstance.GetSupportedLanguages())
-
-	// Initialize user repository
-	var userRepo user.Repository
-	switch cfg.DBType {
-	case "memory":
-		userRepo = user.NewMemoryRepository()
-		log.Printf("Using in-memory user repository")
-	case "postgres":
-		// TODO: Implement PostgreSQL repository
-		log.Fatalf("PostgreSQL repository not implemented yet")
-	case "cosmos":
-		// TODO: Implement Cosmos DB repository
-		log.Fatalf("Cosmos DB repository not implemented yet")
-	default:
-		log.Fatalf("Unknown database type: %s", cfg.DBType)
-	}
+// command is a bot subcommand. register adds the command's own flags to the
+// flag set and returns the function running it.
+type command struct {
+	name     string
+	usage    string
+	register func(fs *flag.FlagSet) runFunc
+}
 
-	// Initialize user manager
-	userManager := user.NewManager(userRepo)
+// commands lists the subcommands; serve runs when none is given
+var commands = []command{
+	{name: "serve", usage: "run the bot (default)", register: func(*flag.FlagSet) runFunc { return runServe }},
+	{name: "migrate", usage: "bring the repository schema up to date", register: migrateCommand},
+	{name: "users export", usage: "write all users as versioned JSONL", register: exportUsersCommand},
+	{name: "users import", usage: "read users written by users export (--dry-run, --on-conflict)", register: importUsersCommand},
+	{name: "send", usage: "deliver a book: send --user <telegram id> --book <book id> [--target email|telegram]", register: sendCommand},
+	{name: "set-webhook", usage: "register the webhook URL with Telegram", register: setWebhookCommand},
+	{name: "delete-webhook", usage: "remove the webhook", register: deleteWebhookCommand},
+	{name: "webhook-info", usage: "print the webhook status reported by Telegram", register: webhookInfoCommand},
+	{name: "cleanup", usage: "run maintenance once and print the report", register: cleanupCommand},
+	{name: "check-config", usage: "validate the configuration and resolve secrets", register: checkConfigCommand},
+}
 
-	// Initialize Telegram bot
-	botAPI, err := tgbotapi.NewBotAPI(cfg.TelegramBotToken)
-	if err != nil {
-		log.Fatalf("Failed to create Telegram bot: %v", err)
+func main() {
+	name, args := splitCommand(os.Args[1:])
+	if name == "help" {
+		printUsage()
+		return
 	}
 
-	botAPI.Debug = cfg.LogLevel == "debug"
-	log.Printf("Authorized on account @%s", botAPI.Self.UserName)
-
-	// Initialize bot handler
-	handler := bot.NewHandler(botAPI, i18nInstance, userManager)
-
-	// Create context with cancellation
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Set up graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
-	// Start bot based on mode
-	switch cfg.BotMode {
-	case "polling":
-		go runPollingMode(ctx, botAPI, handler)
-	case "webhook":
-		go runWebhookMode(ctx, cfg, botAPI, handler)
-	default:
-		// Cancel context and log error, then exit
-		cancel()
-		log.Printf("Unknown bot mode: %s", cfg.BotMode)
-		os.Exit(1)
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printUsage()
+		os.Exit(2)
 	}
 
-	// Wait for shutdown signal
-	<-sigChan
-	log.Println("Shutting down gracefully...")
-	cancel()
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: bot %s [flags]\n\n%s\n\nFlags:\n", cmd.name, cmd.usage)
+		fs.PrintDefaults()
+	}
+	flags := config.RegisterFlags(fs)
+	run := cmd.register(fs)
+	_ = fs.Parse(args) // ExitOnError
 
-	// Give the bot some time to finish processing
-	time.Sleep(2 * time.Second)
-	log.Println("Bot stopped")
-}
+	// Load configuration
+	cfg, err := config.LoadWithOptions(flags.Options())
+	if err != nil {
+		log.Fatalf("Failed to load configuration:\n%v", err)
+	}
 
-// runPollingMode runs the bot in polling mode (long polling).
-func runPollingMode(ctx context.Context, botAPI *tgbotapi.BotAPI, handler *bot.Handler) {
-	log.Println("Starting bot in polling mode...")
-
-	// Create update config
-	updateConfig := tgbotapi.NewUpdate(0)
-	updateConfig.Timeout = 60
-
-	// Get update channel
-	updates := botAPI.GetUpdatesChan(updateConfig)
-
-	// Process updates
-	for {
-		select {
-		case <-ctx.Done():
-			log.Println("Stopping polling...")
-			botAPI.StopReceivingUpdates()
-
-			return
-		case update := <-updates:
-			// Process update in background to avoid blocking
-			go func(update tgbotapi.Update) {
-				if err := handler.HandleUpdate(ctx, &update); err != nil {
-					log.Printf("Error handling update: %v", err)
-				}
-			}(update)
+	if flags.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("Failed to print configuration: %v", err)
 		}
+		return
 	}
-}
-
-// runWebhookMode runs the bot in webhook mode.
-func runWebhookMode(ctx context.Context, cfg *config.Config, botAPI *tgbotapi.BotAPI, handler *bot.Handler) {
-	log.Println("Starting bot in webhook mode...")
 
-	// Set webhook
-	webhookConfig, wErr := tgbotapi.NewWebhook(cfg.WebhookURL)
-	if wErr != nil {
-		log.Fatalf("Failed to create webhook config: %v", wErr)
+	a, err := newApp(cfg)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
 
-	// Note: SecretToken is available in newer versions of telegram-bot-api.
-	// If needed, upgrade to v6+ for webhook secret token support.
+	// Commands stop on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if _, err := botAPI.Request(webhookConfig); err != nil {
-		log.Fatalf("Failed to set webhook: %v", err)
+	if err := run(ctx, a, fs.Args()); err != nil {
+		stop()
+		fatal("Command failed", err, "command", cmd.name)
 	}
+}
 
-	info, err := botAPI.GetWebhookInfo()
-	if err != nil {
-		log.Fatalf("Failed to get webhook info: %v", err)
+// splitCommand returns the command name and its arguments. Without a
+// command (no arguments, or flags only) the bot is served.
+func splitCommand(args []string) (string, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "serve", args
 	}
-
-	if info.LastErrorDate != 0 {
-		log.Printf("Webhook last error: %s", info.LastErrorMessage)
+	if args[0] == "users" && len(args) > 1 {
+		return "users " + args[1], args[2:]
 	}
+	return args[0], args[1:]
+}
 
-	log.Printf("Webhook set to: %s", cfg.WebhookURL)
-
-	// Create HTTP server
-	http.HandleFunc("/webhook", func(w http.ResponseWriter, r *http.Request) {
-		// Verify secret token if configured
-		if cfg.WebhookSecret != "" {
-			token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
-			if token != cfg.WebhookSecret {
-				log.Printf("Invalid webhook secret token")
-				w.WriteHeader(http.StatusUnauthorized)
-
-				return
-			}
-		}
-
-		// Parse update
-		update, err := botAPI.HandleUpdate(r)
-		if err != nil {
-			log.Printf("Error parsing webhook update: %v", err)
-			w.WriteHeader(http.StatusBadRequest)
-
-			return
-		}
-
-		// Handle update
-		if err := handler.HandleUpdate(ctx, update); err != nil {
-			log.Printf("Error handling webhook update: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-
-			return
+// findCommand looks up a command by name
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
 		}
-
-		w.WriteHeader(http.StatusOK)
-	})
-
-	// Health check endpoint
-	http.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "OK")
-	})
-
-	// Start HTTP server
-	addr := fmt.Sprintf(":%s", cfg.Port)
-	server := &http.Server{
-		Addr:         addr,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
 	}
+	return command{}, false
+}
 
-	log.Printf("Starting HTTP server on %s", addr)
-
-	// Run server in goroutine
-	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("HTTP server error: %v", err)
-		}
-	}()
-
-	// Wait for context cancellation
-	<-ctx.Done()
-
-	// Shutdown server
-	log.Println("Shutting down HTTP server...")
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer shutdownCancel()
-
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Printf("HTTP server shutdown error: %v", err)
+// printUsage lists the commands
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: bot [command] [flags]\n\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.usage)
 	}
+	fmt.Fprintln(os.Stderr, "\nRun bot <command> -h for the command's flags.")
+}
 
-	// Remove webhook
-	if _, err := botAPI.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
-		log.Printf("Failed to delete webhook: %v", err)
+// fatal logs an error with optional fields and exits.
+func fatal(msg string, err error, args ...any) {
+	if err != nil {
+		args = append(args, "error", err)
 	}
-
-	log.Println("Webhook mode stopped")
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
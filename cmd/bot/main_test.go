@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantName string
+		wantArgs []string
+	}{
+		{name: "no arguments", args: nil, wantName: "serve", wantArgs: nil},
+		{name: "flags only", args: []string{"--bot-mode", "webhook"}, wantName: "serve", wantArgs: []string{"--bot-mode", "webhook"}},
+		{name: "command", args: []string{"send", "--user", "1"}, wantName: "send", wantArgs: []string{"--user", "1"}},
+		{name: "nested command", args: []string{"users", "export", "--output", "users.json"}, wantName: "users export", wantArgs: []string{"--output", "users.json"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, args := splitCommand(tt.args)
+			if name != tt.wantName {
+				t.Errorf("name = %v, want %v", name, tt.wantName)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	for _, cmd := range commands {
+		if _, ok := findCommand(cmd.name); !ok {
+			t.Errorf("findCommand(%q) not found", cmd.name)
+		}
+	}
+	if _, ok := findCommand("users"); ok {
+		t.Error("findCommand(\"users\") should require a subcommand")
+	}
+}
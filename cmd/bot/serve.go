@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/bot"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/config"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/delivery"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/health"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/i18n"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/opds"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/search"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/subscription"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/tracing"
+)
+
+// runServe runs the bot until ctx is cancelled
+func runServe(ctx context.Context, a *app, _ []string) error {
+	cfg, logger := a.cfg, a.logger
+	logger.Info("Starting Flibusta Kindle Bot...", "bot_mode", cfg.BotMode, "db_type", cfg.DBType)
+
+	// Initialize tracing
+	appInsights := cfg.AppInsightsConnectionString
+	if appInsights == "" {
+		appInsights = cfg.AppInsightsInstrumentationKey
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName:                 "flibusta-kindle-bot",
+		Exporter:                    cfg.TracingExporter,
+		SampleRatio:                 cfg.TracingSampleRatio,
+		AppInsightsConnectionString: appInsights,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("Failed to flush traces", "error", err)
+		}
+	}()
+	logger.Info("Tracing configured", "exporter", cfg.TracingExporter)
+
+	// Initialize i18n
+	i18nInstance, err := i18n.NewEmbedded(cfg.LocalesDir, "en")
+	if err != nil {
+		return fmt.Errorf("failed to initialize i18n: %w", err)
+	}
+	logger.Info("Loaded translations", "languages", i18nInstance.GetSupportedLanguages())
+	if err := i18nInstance.Validate(); err != nil {
+		if cfg.I18nStrict {
+			return fmt.Errorf("invalid translations:\n%w", err)
+		}
+		logger.Warn("Translations are incomplete or inconsistent", "error", err)
+	}
+	if cfg.I18nStrict {
+		i18nInstance.EnableStrictReload()
+	}
+
+	// Pick up translation fixes without a redeploy; files that fail to load,
+	// or to validate in strict mode, keep the current translations
+	logReload := func(err error) {
+		if err != nil {
+			logger.Error("Failed to reload translations", "error", err)
+			return
+		}
+		logger.Info("Reloaded translations", "languages", i18nInstance.GetSupportedLanguages())
+	}
+	if cfg.LocalesDir != "" && cfg.LocalesWatchInterval > 0 {
+		go i18nInstance.Watch(ctx, cfg.LocalesWatchInterval, logReload)
+	}
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				logging.Safely(ctx, "Panic while reloading translations", func() {
+					logReload(i18nInstance.Reload())
+				})
+			}
+		}
+	}()
+
+	// Initialize user manager
+	userRepo, err := a.repository()
+	if err != nil {
+		return err
+	}
+	userManager, err := a.users()
+	if err != nil {
+		return err
+	}
+	userManager.SetLanguages(i18nInstance.GetSupportedLanguages)
+	a.metrics.RegisterActiveUsers(func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		count, err := userManager.CountActiveUsers(ctx, 24*time.Hour)
+		if err != nil {
+			slog.Warn("Failed to count active users", "error", err)
+			return 0
+		}
+		return float64(count)
+	})
+
+	// Initialize Telegram bot
+	botAPI, err := a.telegram()
+	if err != nil {
+		return err
+	}
+
+	// Initialize bot handler
+	handler := bot.NewHandler(botAPI, i18nInstance, userManager, a.metrics, cfg.SenderEmail)
+	if cfg.UserRateLimit > 0 {
+		handler.EnableRateLimit(cfg.UserRateLimit)
+	}
+	if cfg.CallbackSecret != "" {
+		handler.EnableCallbackSigning(cfg.CallbackSecret)
+	}
+	var deliveryQueue *delivery.Queue
+	if deliveryService, err := a.deliveryService(); err != nil {
+		logger.Warn("Book delivery disabled", "error", err)
+	} else {
+		deliveryService.EnableTelegram(bot.NewDocumentSender(botAPI))
+
+		// Book sends run in the background
+		deliveryQueue = delivery.NewQueue(deliveryService, cfg.DeliveryQueueSize)
+		go deliveryQueue.Start(ctx, cfg.DeliveryWorkers)
+		handler.EnableDelivery(deliveryService, deliveryQueue)
+	}
+	handler.EnableReadingList(a.readingList)
+
+	// Search Flibusta inline, and check followed authors and series for new books
+	catalog := opds.NewClient(cfg.FlibustaURL, nil)
+	handler.EnableSearch(search.NewCache(catalog, 10*time.Minute, 1000), a.contexts)
+	handler.EnableFollows(a.follows)
+	if cfg.FollowPollInterval > 0 {
+		poller := subscription.NewPoller(a.follows, catalog, handler)
+		go poller.Start(ctx, cfg.FollowPollInterval)
+	}
+
+	// Run maintenance in the background; admins can also trigger it with /cleanup
+	maintenanceService, err := a.maintenanceService()
+	if err != nil {
+		return err
+	}
+	adminIDs, err := cfg.AdminIDs()
+	if err != nil {
+		return err
+	}
+	handler.EnableAdmin(adminIDs, maintenanceService)
+	if cfg.MaintenanceInterval > 0 {
+		go maintenanceService.Start(ctx, cfg.MaintenanceInterval)
+	}
+
+	// Show the commands in Telegram's menu, in each user's language
+	if err := handler.RegisterCommands(ctx); err != nil {
+		// Commands still work when typed, there is just no menu
+		logger.Warn("Failed to register bot commands", "error", err)
+	}
+
+	// Register health checks
+	healthRegistry := health.NewRegistry(cfg.HealthCheckTimeout)
+	healthRegistry.EnableRedaction(a.redactor.Redact)
+	healthRegistry.Register(health.NewChecker("repository", userRepo.Ping))
+	healthRegistry.Register(health.NewTelegramChecker("telegram", fmt.Sprintf(tgbotapi.APIEndpoint, cfg.TelegramBotToken, "getMe"), nil))
+	healthRegistry.RegisterNonCritical(health.NewHTTPChecker("flibusta", cfg.FlibustaURL, nil))
+	if endpoint := cfg.CommunicationEndpoint(); endpoint != "" {
+		healthRegistry.RegisterNonCritical(health.NewHTTPChecker("mail_sender", endpoint, nil))
+	}
+	healthRegistry.RegisterNonCritical(maintenanceService)
+	if deliveryQueue != nil {
+		// A backlog means deliveries are stuck or workers are too few
+		healthRegistry.RegisterNonCritical(health.NewQueueDepthChecker("delivery_queue", deliveryQueue.Depth, cfg.DeliveryQueueSize*3/4))
+	}
+
+	// Probes and metrics are served in every mode, on their own port when
+	// HEALTH_PORT is set; webhook mode adds /webhook
+	probes := http.NewServeMux()
+	probes.Handle("/livez", healthRegistry.LivenessHandler())
+	probes.Handle("/health", healthRegistry.LivenessHandler()) // Kept for existing probes
+	probes.Handle("/readyz", healthRegistry.ReadinessHandler())
+	probes.Handle("/metrics", a.metrics.Handler())
+	mux := probes
+	if cfg.HealthPort != "" {
+		mux = http.NewServeMux()
+		go runHTTPServer(ctx, cfg, cfg.HealthPort, probes)
+	}
+
+	// Keep Key Vault secrets up to date. Only the webhook secret is read
+	// again for each request; the others are used as read at startup.
+	a.resolver.OnChange(func(name, value string) {
+		a.redactor.Add(value)
+		if name != "WEBHOOK_SECRET" {
+			logger.Warn("Secret rotated; restart the bot to use it", "setting", name)
+			return
+		}
+		logger.Info("Secret rotated", "setting", name)
+	})
+	if cfg.KeyVaultRefreshInterval > 0 {
+		go a.resolver.Run(ctx, cfg.KeyVaultRefreshInterval)
+	}
+
+	// Start bot based on mode
+	switch cfg.BotMode {
+	case "polling":
+		if cfg.HealthPort == "" {
+			go runHTTPServer(ctx, cfg, cfg.Port, mux)
+		}
+		go runPollingMode(ctx, botAPI, handler, cfg.MaxConcurrentUpdates)
+	case "webhook":
+		webhookSecret := func() string {
+			if secret, ok := a.resolver.Get("WEBHOOK_SECRET"); ok {
+				return secret
+			}
+			return cfg.WebhookSecret
+		}
+		mux.Handle("/webhook", webhookHandler(ctx, webhookSecret, botAPI, handler))
+		go runHTTPServer(ctx, cfg, cfg.Port, mux)
+		go runWebhookMode(ctx, cfg, botAPI)
+	default:
+		return fmt.Errorf("unknown bot mode: %s", cfg.BotMode)
+	}
+
+	// Wait for shutdown signal
+	<-ctx.Done()
+	logger.Info("Shutting down gracefully...")
+
+	// Give the bot some time to finish processing
+	time.Sleep(2 * time.Second)
+	logger.Info("Bot stopped")
+
+	return nil
+}
+
+// runPollingMode runs the bot in polling mode (long polling), handling at most
+// maxConcurrent updates at a time.
+func runPollingMode(ctx context.Context, botAPI *tgbotapi.BotAPI, handler *bot.Handler, maxConcurrent int) {
+	slog.Info("Starting bot in polling mode...")
+
+	// Create update config
+	updateConfig := tgbotapi.NewUpdate(0)
+	updateConfig.Timeout = 60
+
+	// Get update channel
+	updates := botAPI.GetUpdatesChan(updateConfig)
+	slots := make(chan struct{}, maxConcurrent)
+
+	// Process updates
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Stopping polling...")
+			botAPI.StopReceivingUpdates()
+
+			return
+		case update := <-updates:
+			// Wait for a free slot, then process update in background
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				continue
+			}
+			go func(update tgbotapi.Update) {
+				defer func() { <-slots }()
+				if err := handler.HandleUpdate(ctx, &update); err != nil {
+					slog.Error("Error handling update", "update_id", update.UpdateID, "error", err)
+				}
+			}(update)
+		}
+	}
+}
+
+// runWebhookMode registers the webhook with Telegram and removes it on shutdown.
+func runWebhookMode(ctx context.Context, cfg *config.Config, botAPI *tgbotapi.BotAPI) {
+	slog.Info("Starting bot in webhook mode...")
+
+	if err := setWebhook(botAPI, cfg.WebhookURL); err != nil {
+		fatal("Failed to set webhook", err)
+	}
+
+	// Wait for context cancellation
+	<-ctx.Done()
+
+	// Remove webhook
+	if _, err := botAPI.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		slog.Error("Failed to delete webhook", "error", err)
+	}
+
+	slog.Info("Webhook mode stopped")
+}
+
+// webhookHandler receives updates pushed by Telegram. secret returns the
+// current webhook secret, which may be rotated while the bot runs.
+func webhookHandler(ctx context.Context, secret func() string, botAPI *tgbotapi.BotAPI, handler *bot.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Verify secret token if configured
+		if expected := secret(); expected != "" {
+			token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+			if token != expected {
+				slog.Warn("Invalid webhook secret token", "remote_addr", r.RemoteAddr)
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+		}
+
+		// Parse update
+		update, err := botAPI.HandleUpdate(r)
+		if err != nil {
+			slog.Error("Error parsing webhook update", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		// Handle update
+		if err := handler.HandleUpdate(ctx, update); err != nil {
+			slog.Error("Error handling webhook update", "update_id", update.UpdateID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// runHTTPServer serves handler on port until ctx is cancelled.
+func runHTTPServer(ctx context.Context, cfg *config.Config, port string, handler http.Handler) {
+	addr := fmt.Sprintf(":%s", port)
+	server := &http.Server{
+		Addr:         addr,
+		Handler:      handler,
+		ReadTimeout:  cfg.HTTPReadTimeout,
+		WriteTimeout: cfg.HTTPWriteTimeout,
+	}
+
+	slog.Info("Starting HTTP server", "addr", addr)
+
+	// Run server in goroutine
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal("HTTP server error", err)
+		}
+	}()
+
+	// Wait for context cancellation
+	<-ctx.Done()
+
+	// Shutdown server
+	slog.Info("Shutting down HTTP server...")
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer shutdownCancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("HTTP server shutdown error", "error", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// setWebhookCommand registers the webhook URL with Telegram
+func setWebhookCommand(fs *flag.FlagSet) runFunc {
+	url := fs.String("url", "", "webhook URL (default WEBHOOK_URL)")
+
+	return func(ctx context.Context, a *app, _ []string) error {
+		if *url == "" {
+			*url = a.cfg.WebhookURL
+		}
+		if *url == "" {
+			return fmt.Errorf("--url or WEBHOOK_URL is required")
+		}
+
+		botAPI, err := a.telegram()
+		if err != nil {
+			return err
+		}
+
+		return setWebhook(botAPI, *url)
+	}
+}
+
+// deleteWebhookCommand removes the webhook so that the bot can poll
+func deleteWebhookCommand(fs *flag.FlagSet) runFunc {
+	dropPending := fs.Bool("drop-pending-updates", false, "discard updates Telegram has queued for the webhook")
+
+	return func(ctx context.Context, a *app, _ []string) error {
+		botAPI, err := a.telegram()
+		if err != nil {
+			return err
+		}
+
+		if _, err := botAPI.Request(tgbotapi.DeleteWebhookConfig{DropPendingUpdates: *dropPending}); err != nil {
+			return fmt.Errorf("failed to delete webhook: %w", err)
+		}
+
+		slog.Info("Webhook deleted", "drop_pending_updates", *dropPending)
+		return nil
+	}
+}
+
+// webhookInfoCommand prints the webhook status reported by Telegram
+func webhookInfoCommand(_ *flag.FlagSet) runFunc {
+	return func(ctx context.Context, a *app, _ []string) error {
+		botAPI, err := a.telegram()
+		if err != nil {
+			return err
+		}
+
+		info, err := botAPI.GetWebhookInfo()
+		if err != nil {
+			return fmt.Errorf("failed to get webhook info: %w", err)
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+}
+
+// setWebhook registers url as the bot's webhook and logs the last delivery error, if any
+func setWebhook(botAPI *tgbotapi.BotAPI, url string) error {
+	webhookConfig, err := tgbotapi.NewWebhook(url)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook config: %w", err)
+	}
+
+	// Note: SecretToken is available in newer versions of telegram-bot-api.
+	// If needed, upgrade to v6+ for webhook secret token support.
+
+	if _, err := botAPI.Request(webhookConfig); err != nil {
+		return fmt.Errorf("failed to set webhook: %w", err)
+	}
+
+	info, err := botAPI.GetWebhookInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get webhook info: %w", err)
+	}
+
+	if info.LastErrorDate != 0 {
+		slog.Warn("Webhook last error", "message", info.LastErrorMessage)
+	}
+
+	slog.Info("Webhook set", "url", url)
+	return nil
+}
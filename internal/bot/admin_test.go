@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/maintenance"
+)
+
+func TestHandler_Cleanup(t *testing.T) {
+	tests := []struct {
+		name       string
+		admins     []int64
+		wantPrefix string
+	}{
+		{name: "admin", admins: []int64{12345}, wantPrefix: "Cleanup "},
+		{name: "not admin", admins: []int64{1}, wantPrefix: "Unknown command"},
+		{name: "no admins", wantPrefix: "Unknown command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _, userManager := setupTestHandler(t)
+			bot, telegram := newTestBot(t)
+			handler.bot = bot
+			svc := maintenance.NewService(userManager, nil, time.Hour)
+			handler.EnableAdmin(tt.admins, svc)
+
+			if err := handler.HandleUpdate(context.Background(), commandUpdate("/cleanup")); err != nil {
+				t.Fatalf("HandleUpdate(/cleanup) error = %v", err)
+			}
+
+			calls := telegram.requests()
+			text := calls[len(calls)-1].params["text"]
+			if !strings.HasPrefix(text, tt.wantPrefix) {
+				t.Errorf("reply = %q, want prefix %q", text, tt.wantPrefix)
+			}
+			if tt.wantPrefix == "Cleanup " && !strings.HasSuffix(text, ": 0 users, 0 searches, 0 errors") {
+				t.Errorf("reply = %q, want the counts", text)
+			}
+			if ran := svc.LastReport() != nil; ran != (tt.wantPrefix == "Cleanup ") {
+				t.Errorf("maintenance ran = %v", ran)
+			}
+		})
+	}
+}
+
+func TestHandler_ReloadTranslations(t *testing.T) {
+	tests := []struct {
+		name     string
+		admins   []int64
+		wantText string
+	}{
+		{name: "admin", admins: []int64{12345}, wantText: "Reloaded en"},
+		{name: "not admin", admins: []int64{1}, wantText: "Unknown command"},
+		{name: "no admins", wantText: "Unknown command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _, userManager := setupTestHandler(t)
+			bot, telegram := newTestBot(t)
+			handler.bot = bot
+			handler.EnableAdmin(tt.admins, maintenance.NewService(userManager, nil, time.Hour))
+
+			if err := handler.HandleUpdate(context.Background(), commandUpdate("/reload_translations")); err != nil {
+				t.Fatalf("HandleUpdate(/reload_translations) error = %v", err)
+			}
+
+			calls := telegram.requests()
+			if text := calls[len(calls)-1].params["text"]; !strings.HasPrefix(text, tt.wantText) {
+				t.Errorf("reply = %q, want prefix %q", text, tt.wantText)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/format"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// maxCommandDescription is Telegram's limit for command descriptions
+const maxCommandDescription = 256
+
+// botCommand describes a command: how it is handled and to whom it is
+// offered. Its description is the "command_<name>" message.
+type botCommand struct {
+	name   string
+	handle func(h *Handler, ctx context.Context, message *tgbotapi.Message, user *models.User) error
+	// enabled reports whether the feature behind the command is set up;
+	// disabled commands are left out of the menu and /help
+	enabled func(h *Handler) bool
+	// admin commands are only run for, and only offered to, admins
+	admin bool
+}
+
+// commands lists every command in menu and /help order. It drives routing,
+// the command menu and the list in /help.
+var commands []botCommand
+
+// init fills in commands, as /help lists commands and so cannot be part of
+// its initializer
+func init() {
+	commands = []botCommand{
+		{name: "start", handle: (*Handler).handleStart},
+		{name: "kindle", handle: (*Handler).handleKindle},
+		{name: "whitelist", handle: (*Handler).handleWhitelist},
+		{name: "verify", handle: (*Handler).handleVerify, enabled: func(h *Handler) bool { return h.delivery != nil }},
+		{name: "language", handle: (*Handler).handleLanguage},
+		{name: "settings", handle: (*Handler).handleSettings},
+		{name: "list", handle: (*Handler).handleList, enabled: func(h *Handler) bool { return h.readingList != nil }},
+		{name: "follow", handle: (*Handler).handleFollow, enabled: func(h *Handler) bool { return h.follows != nil }},
+		{name: "export_my_data", handle: (*Handler).handleExportMyData},
+		{name: "delete_me", handle: (*Handler).handleDeleteMe},
+		{name: "cancel", handle: (*Handler).handleCancel},
+		{name: "help", handle: (*Handler).handleHelp},
+
+		{name: "cleanup", handle: (*Handler).handleCleanup, enabled: func(h *Handler) bool { return h.maintenance != nil }, admin: true},
+		{name: "reload_translations", handle: (*Handler).handleReloadTranslations, admin: true},
+	}
+}
+
+// findCommand returns the registered command with the given name
+func findCommand(name string) (*botCommand, bool) {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i], true
+		}
+	}
+	return nil, false
+}
+
+// isEnabled reports whether the command's feature is set up
+func (c *botCommand) isEnabled(h *Handler) bool {
+	return c.enabled == nil || c.enabled(h)
+}
+
+// availableCommands returns the enabled commands, with admin commands only
+// when admin is set
+func (h *Handler) availableCommands(admin bool) []*botCommand {
+	var available []*botCommand
+	for i := range commands {
+		if c := &commands[i]; c.isEnabled(h) && (admin || !c.admin) {
+			available = append(available, c)
+		}
+	}
+	return available
+}
+
+// commandList lists the commands available to user for /help, in HTML like
+// the descriptions it is made of
+func (h *Handler) commandList(user *models.User) htmlText {
+	var lines []string
+	for _, c := range h.availableCommands(h.admins[user.TelegramID]) {
+		lines = append(lines, "/"+c.name+" - "+h.i18n.T(user.Language, "command_"+c.name))
+	}
+	return htmlText(strings.Join(lines, "\n"))
+}
+
+// menu returns the command menu in lang. Telegram shows the descriptions
+// as plain text, so their HTML is removed.
+func (h *Handler) menu(lang string, admin bool) []tgbotapi.BotCommand {
+	var menu []tgbotapi.BotCommand
+	for _, c := range h.availableCommands(admin) {
+		menu = append(menu, tgbotapi.BotCommand{
+			Command:     c.name,
+			Description: truncate(format.PlainText(h.i18n.T(lang, "command_"+c.name)), maxCommandDescription),
+		})
+	}
+	return menu
+}
+
+// RegisterCommands publishes the command menu to Telegram: in the default
+// language for everyone, in each other language for users with that
+// language, and likewise with the admin commands in the private chats of
+// admins.
+func (h *Handler) RegisterCommands(ctx context.Context) error {
+	defaultLang := h.i18n.DefaultLanguage()
+
+	configs := []tgbotapi.SetMyCommandsConfig{tgbotapi.NewSetMyCommands(h.menu(defaultLang, false)...)}
+	languages := h.i18n.GetSupportedLanguages()
+	for _, lang := range languages {
+		config := tgbotapi.NewSetMyCommands(h.menu(lang, false)...)
+		config.LanguageCode = lang
+		configs = append(configs, config)
+	}
+	for _, config := range configs {
+		if _, err := h.bot.Request(config); err != nil {
+			return fmt.Errorf("failed to register commands for language %q: %w", config.LanguageCode, err)
+		}
+	}
+
+	// Admins who never started a chat with the bot cannot get a menu yet
+	for id := range h.admins {
+		scope := tgbotapi.NewBotCommandScopeChat(id)
+		configs := []tgbotapi.SetMyCommandsConfig{tgbotapi.NewSetMyCommandsWithScope(scope, h.menu(defaultLang, true)...)}
+		for _, lang := range languages {
+			configs = append(configs, tgbotapi.NewSetMyCommandsWithScopeAndLanguage(scope, lang, h.menu(lang, true)...))
+		}
+		for _, config := range configs {
+			if _, err := h.bot.Request(config); err != nil {
+				logging.FromContext(ctx).Warn("Failed to register admin commands", "admin_id", id, "language", config.LanguageCode, "error", err)
+				break
+			}
+		}
+	}
+
+	logging.FromContext(ctx).Info("Registered bot commands", "languages", languages, "admins", len(h.admins))
+	return nil
+}
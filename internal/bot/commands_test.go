@@ -0,0 +1,171 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/i18n"
+)
+
+func TestCommands_Described(t *testing.T) {
+	translations, err := i18n.NewEmbedded("", "en")
+	if err != nil {
+		t.Fatalf("NewEmbedded() error = %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range commands {
+		if seen[c.name] {
+			t.Errorf("command %s is registered twice", c.name)
+		}
+		seen[c.name] = true
+
+		key := "command_" + c.name
+		for _, lang := range translations.GetSupportedLanguages() {
+			if description := translations.T(lang, key); description == key || len(description) > maxCommandDescription {
+				t.Errorf("%s: description of /%s = %q", lang, c.name, description)
+			}
+		}
+	}
+}
+
+func TestHandler_RegisterCommands(t *testing.T) {
+	handler, _, _ := setupTestHandler(t)
+	bot, telegram := newTestBot(t)
+	handler.bot = bot
+	translations, err := i18n.NewEmbedded("", "en")
+	if err != nil {
+		t.Fatalf("NewEmbedded() error = %v", err)
+	}
+	handler.i18n = translations
+	handler.EnableAdmin([]int64{12345}, nil)
+
+	if err := handler.RegisterCommands(context.Background()); err != nil {
+		t.Fatalf("RegisterCommands() error = %v", err)
+	}
+
+	menus := make(map[string]string)
+	for _, call := range telegram.requests() {
+		if call.method != "setMyCommands" {
+			continue
+		}
+		scope := call.params["language_code"]
+		if call.params["scope"] != "" {
+			scope = "admin " + scope
+		}
+		menus[scope] = call.params["commands"]
+	}
+
+	// A default menu and one per language, for everyone and for the admin
+	if want := 2 * (len(translations.GetSupportedLanguages()) + 1); len(menus) != want {
+		t.Fatalf("registered %d menus, want %d", len(menus), want)
+	}
+
+	var commands []struct{ Command, Description string }
+	if err := json.Unmarshal([]byte(menus["ru"]), &commands); err != nil {
+		t.Fatalf("commands = %s: %v", menus["ru"], err)
+	}
+	if len(commands) == 0 || commands[0].Command != "start" || commands[0].Description != "Запустить бота" {
+		t.Errorf("ru commands = %+v", commands)
+	}
+	if !strings.Contains(menus["admin ru"], "Запустить бота") {
+		t.Errorf("ru admin commands = %s, want them in Russian", menus["admin ru"])
+	}
+
+	tests := []struct {
+		command string
+		menu    string
+		want    bool
+	}{
+		{command: "help", menu: "", want: true},
+		{command: "follow", menu: "", want: false}, // Follows are not enabled
+		{command: "reload_translations", menu: "", want: false},
+		{command: "reload_translations", menu: "admin ", want: true},
+		{command: "reload_translations", menu: "admin ru", want: true},
+		{command: "cleanup", menu: "admin ", want: false}, // No maintenance service
+	}
+	for _, tt := range tests {
+		if got := strings.Contains(menus[tt.menu], `"command":"`+tt.command+`"`); got != tt.want {
+			t.Errorf("menu %q has /%s = %v, want %v", tt.menu, tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_Help(t *testing.T) {
+	tests := []struct {
+		name      string
+		admins    []int64
+		wantAdmin bool
+	}{
+		{name: "user"},
+		{name: "admin", admins: []int64{12345}, wantAdmin: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _, _ := setupTestHandler(t)
+			bot, telegram := newTestBot(t)
+			handler.bot = bot
+			translations, err := i18n.NewEmbedded("", "en")
+			if err != nil {
+				t.Fatalf("NewEmbedded() error = %v", err)
+			}
+			handler.i18n = translations
+			handler.EnableAdmin(tt.admins, nil)
+
+			if err := handler.HandleUpdate(context.Background(), commandUpdate("/help")); err != nil {
+				t.Fatalf("HandleUpdate(/help) error = %v", err)
+			}
+
+			text := lastText(telegram)
+			if !strings.Contains(text, "/kindle - Set Kindle email\n") {
+				t.Errorf("/help = %q, want the command list", text)
+			}
+			if strings.Contains(text, "{commands}") || strings.Contains(text, "/list - ") {
+				t.Errorf("/help = %q, want only enabled commands", text)
+			}
+			if got := strings.Contains(text, "/reload_translations - "); got != tt.wantAdmin {
+				t.Errorf("/help lists admin commands = %v, want %v", got, tt.wantAdmin)
+			}
+		})
+	}
+}
+
+func TestHandler_Help_NotEscapedTwice(t *testing.T) {
+	handler, _, _ := setupTestHandler(t)
+	bot, telegram := newTestBot(t)
+	handler.bot = bot
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "en.json"), []byte(`{"command_help": "Help &amp; <i>tips</i>"}`), 0644)
+	translations, err := i18n.NewEmbedded(dir, "en")
+	if err != nil {
+		t.Fatalf("NewEmbedded() error = %v", err)
+	}
+	handler.i18n = translations
+
+	if err := handler.HandleUpdate(context.Background(), commandUpdate("/help")); err != nil {
+		t.Fatalf("HandleUpdate(/help) error = %v", err)
+	}
+	if text := lastText(telegram); !strings.Contains(text, "/help - Help &amp; <i>tips</i>\n") {
+		t.Errorf("/help = %q, want the description as written", text)
+	}
+
+	// The menu shows no formatting
+	if err := handler.RegisterCommands(context.Background()); err != nil {
+		t.Fatalf("RegisterCommands() error = %v", err)
+	}
+	var menu string
+	for _, call := range telegram.requests() {
+		if call.method == "setMyCommands" && call.params["language_code"] == "en" && call.params["scope"] == "" {
+			menu = call.params["commands"]
+		}
+	}
+	if !strings.Contains(menu, `"description":"Help \u0026 tips"`) {
+		t.Errorf("commands = %s, want the description as plain text", menu)
+	}
+}
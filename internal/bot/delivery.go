@@ -0,0 +1,167 @@
+package bot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/callback"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/delivery"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/downloader"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/kindle"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+	usermanager "github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// EnableDelivery lets users send books to their Kindles or chats and test
+// documents to their Kindles. Book sends run in the background through
+// queue.
+func (h *Handler) EnableDelivery(service *delivery.Service, queue *delivery.Queue) {
+	h.delivery = service
+	h.queue = queue
+}
+
+// handleVerify handles /verify command by emailing a test document to the
+// user's Kindle and asking whether it arrived.
+func (h *Handler) handleVerify(ctx context.Context, message *tgbotapi.Message, user *models.User) error {
+	if !user.HasKindleEmail() {
+		return h.sendMessage(message.Chat.ID, user.Language, "kindle_email_required")
+	}
+	if h.delivery == nil {
+		return h.sendMessage(message.Chat.ID, user.Language, "feature_coming_soon")
+	}
+
+	doc := kindle.TestDocument(h.i18n.T(user.Language, "verify_document"))
+	if err := h.delivery.SendTestDocument(ctx, user.TelegramID, doc); err != nil {
+		logging.FromContext(ctx).Error("Failed to send test document", "error", err)
+		return h.sendMessage(message.Chat.ID, user.Language, "book_send_failed")
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			h.button(h.i18n.T(user.Language, "verify_arrived_button"), "verify", "yes", emailTag(user.KindleEmail)),
+			h.button(h.i18n.T(user.Language, "verify_missing_button"), "verify", "no", emailTag(user.KindleEmail)),
+		),
+	)
+
+	_, err := h.send(message.Chat.ID, h.text(user.Language, "verify_sent", user.KindleEmail), keyboard)
+	return err
+}
+
+// handleVerifyCallback handles the answer to whether the test document arrived.
+func (h *Handler) handleVerifyCallback(ctx context.Context, query *tgbotapi.CallbackQuery, data callback.Data, user *models.User) error {
+	if _, err := h.bot.Request(tgbotapi.NewCallback(query.ID, "")); err != nil {
+		return err
+	}
+
+	// The answer is about the address the test document went to
+	changed := h.text(user.Language, "verify_email_changed")
+	if !user.HasKindleEmail() || data.Arg(1) != emailTag(user.KindleEmail) {
+		return h.edit(query.Message.Chat.ID, query.Message.MessageID, changed, nil)
+	}
+
+	text := h.text(user.Language, "verify_failed") + "\n\n" + h.text(user.Language, "whitelist_instructions", h.senderEmail)
+	if data.Arg(0) == "yes" {
+		err := h.userManager.MarkKindleVerified(ctx, user.TelegramID, user.KindleEmail)
+		switch {
+		case errors.Is(err, usermanager.ErrKindleEmailChanged):
+			text = changed
+		case err != nil:
+			return err
+		default:
+			text = h.text(user.Language, "verify_done")
+		}
+	}
+
+	return h.edit(query.Message.Chat.ID, query.Message.MessageID, text, nil)
+}
+
+// emailTag returns a short hash of a Kindle email, so verify buttons name
+// the address they were sent for without revealing it
+func emailTag(email string) string {
+	sum := sha256.Sum256([]byte(email))
+	return hex.EncodeToString(sum[:4])
+}
+
+// handleBookCallback handles a book button by sending the book to the
+// user's e-reader ("book <id> [<format>]") or into the chat as a document
+// ("doc <id> [<format>]"). Without a format the target's preferred format is
+// sent.
+func (h *Handler) handleBookCallback(ctx context.Context, query *tgbotapi.CallbackQuery, data callback.Data, user *models.User) error {
+	if h.queue == nil {
+		answer := tgbotapi.NewCallback(query.ID, h.i18n.T(user.Language, "feature_coming_soon"))
+		_, err := h.bot.Request(answer)
+		return err
+	}
+
+	if _, err := h.bot.Request(tgbotapi.NewCallback(query.ID, "")); err != nil {
+		return err
+	}
+
+	chatID := callbackChatID(query)
+	target, tooLarge := delivery.TargetEmail, "book_too_large"
+	if data.Action == "doc" {
+		target, tooLarge = delivery.TargetTelegram, "book_too_large_telegram"
+	} else {
+		if !user.HasKindleEmail() {
+			return h.sendMessage(chatID, user.Language, "kindle_email_required")
+		}
+
+		// Gently point new users at /verify before their first delivery
+		if !user.KindleVerified && user.BooksSent == 0 {
+			if err := h.sendMessage(chatID, user.Language, "verify_reminder"); err != nil {
+				return err
+			}
+		}
+	}
+
+	bookID, format := data.Arg(0), data.Arg(1)
+
+	// Deliveries can outlast a webhook request, so they run in the
+	// background and report back when done
+	job := delivery.Job{
+		TelegramID: user.TelegramID,
+		BookID:     bookID,
+		Format:     format,
+		Target:     target,
+		Done: func(ctx context.Context, err error) {
+			if err := h.reportDelivery(ctx, chatID, user, bookID, format, target, tooLarge, err); err != nil {
+				logging.FromContext(ctx).Warn("Failed to report queued delivery", "error", err)
+			}
+		},
+	}
+	if err := h.queue.Enqueue(job); err != nil {
+		if !errors.Is(err, delivery.ErrQueueFull) {
+			return err
+		}
+		logging.FromContext(ctx).Warn("Delivery queue full", "book_id", bookID)
+		return h.sendMessage(chatID, user.Language, "delivery_busy")
+	}
+
+	return h.sendMessage(chatID, user.Language, "book_queued")
+}
+
+// reportDelivery tells the user how a book delivery went
+func (h *Handler) reportDelivery(ctx context.Context, chatID int64, user *models.User, bookID, format, target, tooLarge string, err error) error {
+	switch {
+	case err == nil && target == delivery.TargetTelegram:
+		return nil // The document itself is the reply
+	case err == nil:
+		return h.sendMessage(chatID, user.Language, "book_sent", user.KindleEmail)
+	case errors.Is(err, delivery.ErrTargetDisabled):
+		return h.sendMessage(chatID, user.Language, "feature_coming_soon")
+	case errors.Is(err, downloader.ErrBookNotFound), errors.Is(err, downloader.ErrInvalidBookID):
+		return h.sendMessage(chatID, user.Language, "book_not_found")
+	case errors.Is(err, downloader.ErrUnsupportedFormat), errors.Is(err, delivery.ErrFormatNotAccepted):
+		return h.sendMessage(chatID, user.Language, "format_not_supported", format)
+	case errors.Is(err, downloader.ErrFileTooLarge), errors.Is(err, kindle.ErrAttachmentTooLarge), errors.Is(err, delivery.ErrTooLarge):
+		return h.sendMessage(chatID, user.Language, tooLarge)
+	default:
+		logging.FromContext(ctx).Error("Failed to deliver book", "book_id", bookID, "format", format, "target", target, "error", err)
+		return h.sendMessage(chatID, user.Language, "book_send_failed")
+	}
+}
@@ -0,0 +1,297 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/delivery"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/downloader"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/kindle"
+)
+
+// fakeEmailClient records sent emails
+type fakeEmailClient struct {
+	sent []*kindle.Email
+}
+
+func (c *fakeEmailClient) Send(ctx context.Context, email *kindle.Email) error {
+	c.sent = append(c.sent, email)
+	return nil
+}
+
+// setupDeliveryHandler returns a handler delivering books from a fake
+// Flibusta serving book 42 as epub, through a queue run by one worker
+func setupDeliveryHandler(t *testing.T) (*Handler, *fakeTelegram, *fakeEmailClient) {
+	t.Helper()
+
+	flibusta := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/b/42/epub" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/epub+zip")
+		_, _ = w.Write([]byte("epub-data"))
+	}))
+	t.Cleanup(flibusta.Close)
+
+	handler, _, userManager := setupTestHandler(t)
+	bot, telegram := newTestBot(t)
+	handler.bot = bot
+	handler.senderEmail = "bot@example.com"
+
+	email := &fakeEmailClient{}
+	service := delivery.NewService(
+		userManager,
+		downloader.NewDownloader(flibusta.URL, flibusta.Client()),
+		kindle.NewSender(email),
+		nil,
+	)
+	queue := delivery.NewQueue(service, 10)
+	handler.EnableDelivery(service, queue)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		queue.Start(ctx, 1)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	return handler, telegram, email
+}
+
+// waitForRequest waits until a request matching match is sent after the
+// first skip requests, and returns it
+func waitForRequest(t *testing.T, telegram *fakeTelegram, skip int, match func(telegramCall) bool) telegramCall {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, call := range telegram.requests()[skip:] {
+			if match(call) {
+				return call
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no matching request was sent")
+	return telegramCall{}
+}
+
+// isText matches messages with text
+func isText(text string) func(telegramCall) bool {
+	return func(call telegramCall) bool {
+		return call.params["text"] == text
+	}
+}
+
+// lastText returns the text of the last message sent or edited
+func lastText(telegram *fakeTelegram) string {
+	calls := telegram.requests()
+	return calls[len(calls)-1].params["text"]
+}
+
+func TestHandler_Whitelist_ShowsSenderEmail(t *testing.T) {
+	handler, telegram, _ := setupDeliveryHandler(t)
+
+	if err := handler.HandleUpdate(context.Background(), commandUpdate("/whitelist")); err != nil {
+		t.Fatalf("HandleUpdate(/whitelist) error = %v", err)
+	}
+	if text := lastText(telegram); text != "Whitelist bot@example.com" {
+		t.Errorf("reply = %q, want sender email", text)
+	}
+}
+
+func TestHandler_Verify(t *testing.T) {
+	tests := []struct {
+		name         string
+		answer       string
+		newEmail     string
+		wantVerified bool
+		wantText     string
+	}{
+		{name: "arrived", answer: "yes", wantVerified: true, wantText: "Verified"},
+		{name: "missing", answer: "no", wantVerified: false, wantText: "Not verified\n\nWhitelist bot@example.com"},
+		{name: "email changed", answer: "yes", newEmail: "other@kindle.com", wantVerified: false, wantText: "Email changed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, telegram, email := setupDeliveryHandler(t)
+			ctx := context.Background()
+
+			if err := handler.HandleUpdate(ctx, commandUpdate("/kindle reader@kindle.com")); err != nil {
+				t.Fatalf("HandleUpdate(/kindle) error = %v", err)
+			}
+			if err := handler.HandleUpdate(ctx, commandUpdate("/verify")); err != nil {
+				t.Fatalf("HandleUpdate(/verify) error = %v", err)
+			}
+
+			if len(email.sent) != 1 || email.sent[0].To != "reader@kindle.com" {
+				t.Fatalf("sent = %v, want a test document to reader@kindle.com", email.sent)
+			}
+			if text := lastText(telegram); text != "Test sent to reader@kindle.com" {
+				t.Errorf("reply = %q", text)
+			}
+
+			if tt.newEmail != "" {
+				if err := handler.HandleUpdate(ctx, commandUpdate("/kindle "+tt.newEmail)); err != nil {
+					t.Fatalf("HandleUpdate(/kindle) error = %v", err)
+				}
+			}
+
+			if err := handler.HandleUpdate(ctx, callbackUpdate("verify", tt.answer, emailTag("reader@kindle.com"))); err != nil {
+				t.Fatalf("HandleUpdate(%s) error = %v", tt.answer, err)
+			}
+			if text := lastText(telegram); text != tt.wantText {
+				t.Errorf("reply = %q, want %q", text, tt.wantText)
+			}
+
+			u, _ := handler.userManager.GetUser(ctx, 12345)
+			if u.KindleVerified != tt.wantVerified || u.KindleVerifiedAt.IsZero() == tt.wantVerified {
+				t.Errorf("KindleVerified = %v at %v, want %v", u.KindleVerified, u.KindleVerifiedAt, tt.wantVerified)
+			}
+		})
+	}
+}
+
+func TestHandler_BookCallback(t *testing.T) {
+	handler, telegram, email := setupDeliveryHandler(t)
+	ctx := context.Background()
+
+	if err := handler.HandleUpdate(ctx, commandUpdate("/kindle reader@kindle.com")); err != nil {
+		t.Fatalf("HandleUpdate(/kindle) error = %v", err)
+	}
+
+	// The first delivery to an unverified Kindle comes with a reminder. The
+	// press is answered right away and the result follows from the queue.
+	before := len(telegram.requests())
+	if err := handler.HandleUpdate(ctx, callbackUpdate("book", "42")); err != nil {
+		t.Fatalf("HandleUpdate(book_42) error = %v", err)
+	}
+	if text := lastText(telegram); text != "Book queued" {
+		t.Errorf("reply = %q, want Book queued", text)
+	}
+	sent := isText("Book sent to reader@kindle.com")
+	waitForRequest(t, telegram, before, sent)
+	var texts []string
+	for _, call := range telegram.requests()[before:] {
+		if call.method == "sendMessage" {
+			texts = append(texts, call.params["text"])
+		}
+	}
+	if strings.Join(texts, "|") != "Consider /verify|Book queued|Book sent to reader@kindle.com" {
+		t.Errorf("replies = %q, want reminder, queued and confirmation", texts)
+	}
+	if len(email.sent) != 1 {
+		t.Errorf("sent %d emails, want 1", len(email.sent))
+	}
+
+	// No reminder once a book was delivered
+	before = len(telegram.requests())
+	if err := handler.HandleUpdate(ctx, callbackUpdate("book", "42", "epub")); err != nil {
+		t.Fatalf("HandleUpdate(book_42_epub) error = %v", err)
+	}
+	waitForRequest(t, telegram, before, sent)
+	for _, call := range telegram.requests()[before:] {
+		if call.params["text"] == "Consider /verify" {
+			t.Error("reminder sent again after the first delivery")
+		}
+	}
+
+	before = len(telegram.requests())
+	if err := handler.HandleUpdate(ctx, callbackUpdate("book", "7")); err != nil {
+		t.Fatalf("HandleUpdate(book_7) error = %v", err)
+	}
+	waitForRequest(t, telegram, before, isText("Book not found"))
+}
+
+func TestHandler_BookCallback_QueueFull(t *testing.T) {
+	handler, telegram, email := setupDeliveryHandler(t)
+	handler.queue = delivery.NewQueue(handler.delivery, 0) // No room and no workers
+	ctx := context.Background()
+
+	if err := handler.HandleUpdate(ctx, commandUpdate("/kindle reader@kindle.com")); err != nil {
+		t.Fatalf("HandleUpdate(/kindle) error = %v", err)
+	}
+	if err := handler.HandleUpdate(ctx, callbackUpdate("book", "42")); err != nil {
+		t.Fatalf("HandleUpdate(book_42) error = %v", err)
+	}
+	if text := lastText(telegram); text != "Busy" {
+		t.Errorf("reply = %q, want Busy", text)
+	}
+	if len(email.sent) != 0 {
+		t.Errorf("sent %d emails, want 0", len(email.sent))
+	}
+}
+
+func TestHandler_DocumentCallback(t *testing.T) {
+	handler, telegram, email := setupDeliveryHandler(t)
+	ctx := context.Background()
+
+	if err := handler.HandleUpdate(ctx, callbackUpdate("doc", "42")); err != nil {
+		t.Fatalf("HandleUpdate(doc_42) error = %v", err)
+	}
+	waitForRequest(t, telegram, 0, isText("Coming soon"))
+
+	handler.delivery.EnableTelegram(NewDocumentSender(handler.bot))
+
+	// No Kindle email is needed to get the book in the chat
+	before := len(telegram.requests())
+	if err := handler.HandleUpdate(ctx, callbackUpdate("doc", "42")); err != nil {
+		t.Fatalf("HandleUpdate(doc_42) error = %v", err)
+	}
+	waitForRequest(t, telegram, before, func(call telegramCall) bool { return call.method == "sendDocument" })
+	var documents []telegramCall
+	for _, call := range telegram.requests()[before:] {
+		switch {
+		case call.method == "sendDocument":
+			documents = append(documents, call)
+		case call.method == "sendMessage" && call.params["text"] != "Book queued":
+			t.Errorf("unexpected message %q", call.params["text"])
+		}
+	}
+	if len(documents) != 1 {
+		t.Fatalf("sent %d documents, want 1", len(documents))
+	}
+	if got := string(documents[0].files["document"]); got != "epub-data" {
+		t.Errorf("document = %q, want epub-data", got)
+	}
+	if got := documents[0].params["caption"]; got != "42.epub" {
+		t.Errorf("caption = %q, want 42.epub", got)
+	}
+	if len(email.sent) != 0 {
+		t.Errorf("sent %d emails, want 0", len(email.sent))
+	}
+
+	before = len(telegram.requests())
+	if err := handler.HandleUpdate(ctx, callbackUpdate("doc", "7")); err != nil {
+		t.Fatalf("HandleUpdate(doc_7) error = %v", err)
+	}
+	waitForRequest(t, telegram, before, isText("Book not found"))
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"Война и мир", 6, "Война…"},
+	}
+
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.n); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/delivery"
+)
+
+// maxCaptionLength is Telegram's limit for document captions, in characters
+const maxCaptionLength = 1024
+
+// DocumentSender uploads delivered books to Telegram chats
+type DocumentSender struct {
+	bot *tgbotapi.BotAPI
+}
+
+// NewDocumentSender creates a delivery.DocumentSender using botAPI
+func NewDocumentSender(botAPI *tgbotapi.BotAPI) *DocumentSender {
+	return &DocumentSender{bot: botAPI}
+}
+
+// SendDocument sends doc to the chat, with its cover as thumbnail
+func (s *DocumentSender) SendDocument(ctx context.Context, chatID int64, doc *delivery.Document) error {
+	msg := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: doc.Name, Bytes: doc.Data})
+	msg.Caption = truncate(doc.Caption, maxCaptionLength)
+	if len(doc.Thumbnail) > 0 {
+		msg.Thumb = tgbotapi.FileBytes{Name: "cover.jpg", Bytes: doc.Thumbnail}
+	}
+
+	if _, err := s.bot.Send(msg); err != nil {
+		return fmt.Errorf("failed to send document: %w", err)
+	}
+	return nil
+}
+
+// truncate shortens s to at most n characters, ending with an ellipsis
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n-1]) + "…"
+}
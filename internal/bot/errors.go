@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"context"
+	"errors"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/tracing"
+)
+
+// errorMessage returns the message key telling users about err, or "" when
+// they are better off not being told
+func errorMessage(err error) string {
+	var apiErr *tgbotapi.Error
+	switch {
+	case errors.As(err, &apiErr):
+		// Telegram refused a reply, so another one would likely fail too
+		return ""
+	case errors.Is(err, context.Canceled):
+		// The bot is shutting down
+		return ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return "error_timeout"
+	default:
+		return "error_occurred"
+	}
+}
+
+// logError logs err with its incident ID, and the stack for panics
+func logError(ctx context.Context, err error, incident string) {
+	msg := "Failed to handle update"
+	var p *logging.PanicError
+	if errors.As(err, &p) {
+		msg = "Panic while handling update"
+	}
+	logging.LogError(ctx, msg, err, incident)
+}
+
+// reportErrors recovers from panics in routes and reports errors: they are
+// logged with an incident ID, and the user gets a localized message with
+// the same ID. Reported errors count as handled.
+func (h *Handler) reportErrors(next route) route {
+	return func(ctx context.Context, req *request) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = logging.NewPanicError(r)
+			}
+			if err != nil {
+				h.reportError(ctx, req, err)
+				err = nil
+			}
+		}()
+		return next(ctx, req)
+	}
+}
+
+// reportError logs err and tells the user who sent req about it
+func (h *Handler) reportError(ctx context.Context, req *request, err error) {
+	incident := logging.NewIncidentID()
+	logError(logging.With(ctx, "action", req.action), err, incident)
+	tracing.RecordError(trace.SpanFromContext(ctx), err)
+
+	key := errorMessage(err)
+	if key == "" || req.from() == nil {
+		return
+	}
+
+	// A button press or inline query may not have been answered yet
+	switch {
+	case req.query != nil:
+		_, _ = h.bot.Request(tgbotapi.NewCallback(req.query.ID, ""))
+	case req.inline != nil:
+		_, _ = h.bot.Request(emptyInlineAnswer(req.inline))
+	}
+
+	lang := h.requestLanguage(req)
+	text := h.text(lang, key) + "\n\n" + h.text(lang, "incident_id", incident)
+	if _, err := h.send(req.chatID(), text, nil); err != nil {
+		logging.FromContext(ctx).Warn("Failed to report error to user", "incident", incident, "error", err)
+	}
+}
+
+// requestLanguage returns the language to answer req in, before or after
+// its user is loaded
+func (h *Handler) requestLanguage(req *request) string {
+	if req.user != nil {
+		return req.user.Language
+	}
+	return h.i18n.DetectLanguage(req.from().LanguageCode)
+}
@@ -0,0 +1,250 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/callback"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/delivery"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/i18n"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/opds"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/subscription"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// EnableFollows lets users follow authors and series.
+func (h *Handler) EnableFollows(follows *subscription.Manager) {
+	h.follows = follows
+}
+
+// handleFollow handles /follow command. With a Flibusta author or series
+// link it follows it, otherwise it lists what the user follows.
+func (h *Handler) handleFollow(ctx context.Context, message *tgbotapi.Message, user *models.User) error {
+	if h.follows == nil {
+		return h.sendMessage(message.Chat.ID, user.Language, "feature_coming_soon")
+	}
+
+	if link := strings.TrimSpace(message.CommandArguments()); link != "" {
+		kind, entityID, err := subscription.ParseLink(link)
+		if err != nil {
+			return h.sendMessage(message.Chat.ID, user.Language, "follow_usage")
+		}
+		return h.sendMessage(message.Chat.ID, user.Language, h.follow(ctx, user, kind, entityID, ""))
+	}
+
+	text, keyboard, err := h.followsView(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	var markup interface{}
+	if keyboard != nil {
+		markup = *keyboard
+	}
+
+	_, err = h.send(message.Chat.ID, text, markup)
+	return err
+}
+
+// follow follows an entity and returns the message key describing the result.
+// The name may be empty; it is then learnt from the first release.
+func (h *Handler) follow(ctx context.Context, user *models.User, kind, entityID, name string) string {
+	err := h.follows.Follow(ctx, user.TelegramID, &models.Follow{Kind: kind, EntityID: entityID, Name: name})
+	switch {
+	case err == nil:
+		return "follow_added"
+	case errors.Is(err, subscription.ErrAlreadyFollowing):
+		return "follow_already"
+	case errors.Is(err, subscription.ErrTooManyFollows):
+		return "follow_limit"
+	default:
+		logging.FromContext(ctx).Error("Failed to follow", "kind", kind, "entity_id", entityID, "error", err)
+		return "error_occurred"
+	}
+}
+
+// followsView renders the user's follows with an automatic delivery toggle
+// and an unfollow button per follow. The keyboard is nil without follows.
+func (h *Handler) followsView(ctx context.Context, user *models.User) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	follows, err := h.follows.List(ctx, user.TelegramID)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(follows) == 0 {
+		return h.text(user.Language, "follow_empty") + "\n\n" + h.text(user.Language, "follow_usage"), nil, nil
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := range follows {
+		f := &follows[i]
+		icon := "🔔 "
+		if f.AutoDeliver {
+			icon = "📤 "
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			h.button(icon+truncate(followLabel(f), maxButtonLabel), "follow_auto", f.Kind, f.EntityID),
+			h.button("❌", "unfollow", f.Kind, f.EntityID),
+		))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return h.text(user.Language, "follow_list_header", i18n.Params{"count": len(follows)}), &keyboard, nil
+}
+
+// followButton creates a button following an author or series
+func (h *Handler) followButton(kind, entityID, name string) tgbotapi.InlineKeyboardButton {
+	label := followLabel(&models.Follow{Kind: kind, EntityID: entityID, Name: name})
+	return h.button("➕ "+truncate(label, maxButtonLabel), "follow", kind, entityID, name)
+}
+
+// handleFollowCallback handles the follow buttons: "follow <kind> <id> <name>"
+// on search results and release notifications, and "follow_auto <kind> <id>"
+// and "unfollow <kind> <id>" on the follow list.
+func (h *Handler) handleFollowCallback(ctx context.Context, query *tgbotapi.CallbackQuery, data callback.Data, user *models.User) error {
+	if h.follows == nil {
+		_, err := h.bot.Request(tgbotapi.NewCallback(query.ID, h.i18n.T(user.Language, "feature_coming_soon")))
+		return err
+	}
+
+	var (
+		key     string
+		refresh bool
+	)
+	kind, entityID := data.Arg(0), data.Arg(1)
+	switch data.Action {
+	case "unfollow":
+		if err := h.follows.Unfollow(ctx, user.TelegramID, kind, entityID); err != nil {
+			return err
+		}
+		key, refresh = "follow_removed", true
+	case "follow_auto":
+		on, err := h.follows.ToggleAutoDeliver(ctx, user.TelegramID, kind, entityID)
+		if err != nil && !errors.Is(err, subscription.ErrNotFollowing) {
+			return err
+		}
+		key, refresh = "follow_auto_off", true
+		if on {
+			key = "follow_auto_on"
+		}
+	default:
+		if kind != models.FollowAuthor && kind != models.FollowSeries {
+			_, err := h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+			return err
+		}
+		key = h.follow(ctx, user, kind, entityID, data.Arg(2))
+	}
+
+	if _, err := h.bot.Request(tgbotapi.NewCallback(query.ID, h.i18n.T(user.Language, key))); err != nil {
+		return err
+	}
+	if !refresh || query.Message == nil {
+		return nil
+	}
+
+	text, keyboard, err := h.followsView(ctx, user)
+	if err != nil {
+		return err
+	}
+	return h.edit(query.Message.Chat.ID, query.Message.MessageID, text, keyboard)
+}
+
+// NotifyRelease tells a user about a new book from an entity they follow,
+// with buttons to send or save it. Follows with automatic delivery send the
+// book to the user's Kindle straight away.
+func (h *Handler) NotifyRelease(ctx context.Context, telegramID int64, follow *models.Follow, release *opds.Release) error {
+	user, err := h.userManager.GetUser(ctx, telegramID)
+	if err != nil {
+		return err
+	}
+
+	book := releaseLabel(release)
+	if follow.AutoDeliver && h.queue != nil && user.HasKindleEmail() {
+		err := h.queue.Enqueue(delivery.Job{
+			TelegramID: telegramID,
+			BookID:     release.BookID,
+			Target:     delivery.TargetEmail,
+			Done: func(ctx context.Context, err error) {
+				key := "release_sent"
+				if err != nil {
+					key = "release_send_failed"
+				}
+				if err := h.sendMessage(telegramID, user.Language, key, book); err != nil {
+					logging.FromContext(ctx).Warn("Failed to report release delivery", "error", err)
+				}
+			},
+		})
+		if err == nil {
+			return h.sendMessage(telegramID, user.Language, "release_auto", followLabel(follow), book)
+		}
+		// Fall back to asking when the queue is busy
+		logging.FromContext(ctx).Warn("Failed to queue release delivery", "error", err)
+	}
+
+	row := tgbotapi.NewInlineKeyboardRow(
+		h.button(h.i18n.T(user.Language, "send_to_kindle"), "book", release.BookID),
+	)
+	if h.readingList != nil {
+		row = append(row, h.button(h.i18n.T(user.Language, "save_to_list"), "save", release.BookID))
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{row}
+
+	// Offer the release's other authors and series to follow too
+	for _, e := range releaseEntities(release) {
+		if len(rows) > maxReleaseFollowButtons || (e.kind == follow.Kind && e.ID == follow.EntityID) {
+			continue
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(h.followButton(e.kind, e.ID, e.Name)))
+	}
+
+	_, err = h.send(telegramID, h.text(user.Language, "release_notification", followLabel(follow), book), tgbotapi.NewInlineKeyboardMarkup(rows...))
+	return err
+}
+
+// maxReleaseFollowButtons limits the follow buttons on a release notification
+const maxReleaseFollowButtons = 3
+
+// releaseEntity is an author or series of a release
+type releaseEntity struct {
+	kind string
+	opds.Entity
+}
+
+// releaseEntities returns the authors, then the series of a release
+func releaseEntities(r *opds.Release) []releaseEntity {
+	var entities []releaseEntity
+	for _, a := range r.Authors {
+		entities = append(entities, releaseEntity{models.FollowAuthor, a})
+	}
+	for _, s := range r.Series {
+		entities = append(entities, releaseEntity{models.FollowSeries, s})
+	}
+	return entities
+}
+
+// followLabel names a followed author or series in messages
+func followLabel(f *models.Follow) string {
+	icon := "✍️ "
+	if f.Kind == models.FollowSeries {
+		icon = "📚 "
+	}
+	if f.Name == "" {
+		return icon + "#" + f.EntityID
+	}
+	return icon + f.Name
+}
+
+// releaseLabel names a new book in messages
+func releaseLabel(r *opds.Release) string {
+	var authors []string
+	for _, a := range r.Authors {
+		authors = append(authors, a.Name)
+	}
+	if len(authors) == 0 {
+		return r.Title
+	}
+	return r.Title + " — " + strings.Join(authors, ", ")
+}
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/opds"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/subscription"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+func TestHandler_Follow(t *testing.T) {
+	handler, telegram, _ := setupReadingListHandler(t)
+	handler.EnableFollows(subscription.NewManager(subscription.NewMemoryRepository(), 2))
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		update string
+		want   string
+	}{
+		{name: "empty list", update: "/follow", want: "No follows\n\nFollow usage"},
+		{name: "bad link", update: "/follow https://example.com/b/1", want: "Follow usage"},
+		{name: "author", update: "/follow https://flibusta.is/a/100", want: "Following"},
+		{name: "again", update: "/follow https://flibusta.is/a/100", want: "Already following"},
+		{name: "series", update: "/follow https://flibusta.is/s/200", want: "Following"},
+		{name: "limit", update: "/follow https://flibusta.is/a/300", want: "Too many follows"},
+		{name: "list", update: "/follow", want: "Follows (2):"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := handler.HandleUpdate(ctx, commandUpdate(tt.update)); err != nil {
+				t.Fatalf("HandleUpdate() error = %v", err)
+			}
+			if text := lastText(telegram); text != tt.want {
+				t.Errorf("reply = %q, want %q", text, tt.want)
+			}
+		})
+	}
+
+	calls := telegram.requests()
+	if markup := calls[len(calls)-1].params["reply_markup"]; !strings.Contains(markup, callbackData("follow_auto", "author", "100")) || !strings.Contains(markup, callbackData("unfollow", "series", "200")) {
+		t.Errorf("keyboard = %s, want toggle and unfollow buttons", markup)
+	}
+
+	if err := handler.HandleUpdate(ctx, callbackUpdate("follow_auto", "author", "100")); err != nil {
+		t.Fatalf("HandleUpdate(follow_auto) error = %v", err)
+	}
+	follows, _ := handler.follows.List(ctx, 12345)
+	for _, f := range follows {
+		if f.EntityID == "100" && !f.AutoDeliver {
+			t.Error("automatic delivery was not switched on")
+		}
+	}
+
+	if err := handler.HandleUpdate(ctx, callbackUpdate("unfollow", "series", "200")); err != nil {
+		t.Fatalf("HandleUpdate(unfollow) error = %v", err)
+	}
+	if text := lastText(telegram); text != "Follows (1):" {
+		t.Errorf("list after unfollow = %q, want 1 follow", text)
+	}
+}
+
+func TestHandler_NotifyRelease(t *testing.T) {
+	handler, telegram, email := setupReadingListHandler(t)
+	ctx := context.Background()
+
+	release := &opds.Release{BookID: "42", Title: "Dune", Authors: []opds.Entity{{ID: "100", Name: "Frank Herbert"}}}
+	follow := &models.Follow{Kind: models.FollowAuthor, EntityID: "100", Name: "Frank Herbert"}
+
+	if err := handler.HandleUpdate(ctx, commandUpdate("/start")); err != nil {
+		t.Fatalf("HandleUpdate(/start) error = %v", err)
+	}
+	if err := handler.NotifyRelease(ctx, 12345, follow, release); err != nil {
+		t.Fatalf("NotifyRelease() error = %v", err)
+	}
+	if text := lastText(telegram); text != "New from ✍️ Frank Herbert: Dune — Frank Herbert" {
+		t.Errorf("notification = %q", text)
+	}
+	calls := telegram.requests()
+	if markup := calls[len(calls)-1].params["reply_markup"]; !strings.Contains(markup, callbackData("book", "42")) || !strings.Contains(markup, callbackData("save", "42")) {
+		t.Errorf("keyboard = %s, want send and save buttons", markup)
+	}
+
+	// Automatic delivery needs a Kindle email
+	follow.AutoDeliver = true
+	if err := handler.HandleUpdate(ctx, commandUpdate("/kindle reader@kindle.com")); err != nil {
+		t.Fatalf("HandleUpdate(/kindle) error = %v", err)
+	}
+	if err := handler.NotifyRelease(ctx, 12345, follow, release); err != nil {
+		t.Fatalf("NotifyRelease() error = %v", err)
+	}
+	waitForText(t, telegram, "New from ✍️ Frank Herbert: Dune — Frank Herbert, sending")
+	waitForText(t, telegram, "Release sent Dune — Frank Herbert")
+
+	if len(email.sent) != 1 {
+		t.Errorf("sent %d emails, want 1", len(email.sent))
+	}
+}
+
+func TestHandler_FollowButtons(t *testing.T) {
+	handler, telegram, _ := setupReadingListHandler(t)
+	handler.EnableFollows(subscription.NewManager(subscription.NewMemoryRepository(), 10))
+	ctx := context.Background()
+
+	release := &opds.Release{
+		BookID:  "42",
+		Title:   "Dune Messiah",
+		Authors: []opds.Entity{{ID: "100", Name: "Frank Herbert"}},
+		Series:  []opds.Entity{{ID: "200", Name: "Dune"}},
+	}
+	follow := &models.Follow{Kind: models.FollowAuthor, EntityID: "100", Name: "Frank Herbert"}
+
+	if err := handler.HandleUpdate(ctx, commandUpdate("/start")); err != nil {
+		t.Fatalf("HandleUpdate(/start) error = %v", err)
+	}
+	if err := handler.NotifyRelease(ctx, 12345, follow, release); err != nil {
+		t.Fatalf("NotifyRelease() error = %v", err)
+	}
+
+	// The series can be followed, the author already is
+	calls := telegram.requests()
+	markup := calls[len(calls)-1].params["reply_markup"]
+	if !strings.Contains(markup, callbackData("follow", "series", "200", "Dune")) || strings.Contains(markup, callbackData("follow", "author", "100", "Frank Herbert")) {
+		t.Errorf("keyboard = %s, want a follow button for the series only", markup)
+	}
+
+	if err := handler.HandleUpdate(ctx, callbackUpdate("follow", "series", "200", "Dune")); err != nil {
+		t.Fatalf("HandleUpdate(follow) error = %v", err)
+	}
+	follows, _ := handler.follows.List(ctx, 12345)
+	if len(follows) != 1 || follows[0].EntityID != "200" || follows[0].Name != "Dune" {
+		t.Errorf("follows = %+v, want the Dune series", follows)
+	}
+
+	// Search results offer their author and series
+	article := handler.inlineResult("en", &models.Book{ID: "42", Title: "Dune Messiah", Author: "Frank Herbert, Brian Herbert", AuthorID: "100", Series: "Dune", SeriesID: "200"})
+	rows := article.ReplyMarkup.InlineKeyboard
+	if len(rows) != 2 || *rows[1][0].CallbackData != callbackData("follow", "author", "100", "Frank Herbert") || *rows[1][1].CallbackData != callbackData("follow", "series", "200", "Dune") {
+		t.Errorf("keyboard = %+v, want follow author and series buttons", rows)
+	}
+}
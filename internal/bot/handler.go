@@ -2,12 +2,26 @@ package bot
 
 import (
 	"context"
-	"log"
+	"encoding/json"
 	"strings"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/callback"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/delivery"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/format"
 	"github.com/stpatrick2016/flibusta_kindle_bot/internal/i18n"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/maintenance"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/metrics"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/readinglist"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/search"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/subscription"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/tracing"
 	usermanager "github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
 	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
 )
@@ -17,114 +31,127 @@ type Handler struct {
 	bot         *tgbotapi.BotAPI
 	i18n        *i18n.I18n
 	userManager *usermanager.Manager
+	metrics     *metrics.Metrics
+	senderEmail string // Address users must whitelist in their Amazon account
+
+	delivery    *delivery.Service
+	searcher    search.Searcher
+	contexts    *search.ContextStore
+	readingList *readinglist.Manager
+	queue       *delivery.Queue
+	sending     sendingBooks // Reading list books queued for delivery
+	follows     *subscription.Manager
+	admins      map[int64]bool
+	maintenance *maintenance.Service
+	limiter     *rateLimiter
+	inlineLimit *rateLimiter // Inline queries, sent as the user types
+
+	codecOnce sync.Once
+	callbacks *callback.Codec
+
+	routerOnce sync.Once
+	router     *router
 }
 
 // NewHandler creates a new bot handler.
-func NewHandler(bot *tgbotapi.BotAPI, i18n *i18n.I18n, userManager *usermanager.Manager) *Handler {
+func NewHandler(bot *tgbotapi.BotAPI, i18n *i18n.I18n, userManager *usermanager.Manager, metrics *metrics.Metrics, senderEmail string) *Handler {
 	return &Handler{
 		bot:         bot,
 		i18n:        i18n,
 		userManager: userManager,
+		metrics:     metrics,
+		senderEmail: senderEmail,
 	}
 }
 
-// HandleUpdate processes incoming Telegram updates.
-func (h *Handler) HandleUpdate(ctx context.Context, update *tgbotapi.Update) error {
-	// Handle callback queries (inline keyboard button clicks)
-	if update.CallbackQuery != nil {
-		return h.handleCallbackQuery(ctx, update.CallbackQuery)
+// EnableAdmin lets the given users run admin commands such as /cleanup.
+func (h *Handler) EnableAdmin(adminIDs []int64, maintenance *maintenance.Service) {
+	h.admins = make(map[int64]bool, len(adminIDs))
+	for _, id := range adminIDs {
+		h.admins[id] = true
 	}
+	h.maintenance = maintenance
+}
 
-	// Handle messages
-	if update.Message != nil {
-		return h.handleMessage(ctx, update.Message)
-	}
+// EnableRateLimit limits each user to perMinute messages and button presses
+// per minute. Inline queries have a separate, larger allowance.
+func (h *Handler) EnableRateLimit(perMinute int) {
+	h.limiter = newRateLimiter(perMinute)
+	h.inlineLimit = newRateLimiter(perMinute * inlineRateFactor)
+}
 
-	return nil
+// EnableCallbackSigning signs the data of every button the bot sends with
+// key, so button presses with data the bot did not send are ignored.
+func (h *Handler) EnableCallbackSigning(key string) {
+	h.callbacks = callback.NewCodec([]byte(key))
 }
 
-// handleMessage processes incoming messages.
-func (h *Handler) handleMessage(ctx context.Context, message *tgbotapi.Message) error {
-	// Get or create user
-	user, err := h.userManager.GetOrCreateUser(ctx, message.From.ID, message.From.FirstName, message.From.LastName, message.From.UserName, message.From.LanguageCode)
-	if err != nil {
-		log.Printf("Failed to get/create user: %v", err)
+// HandleUpdate processes incoming Telegram updates.
+func (h *Handler) HandleUpdate(ctx context.Context, update *tgbotapi.Update) (err error) {
+	ctx, span := tracing.Start(ctx, "telegram.update",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.Int("telegram.update_id", update.UpdateID),
+			attribute.String("telegram.update_type", updateType(update)),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
 
-		return err
-	}
+	// Attach update fields to every log line produced while handling it
+	ctx = logging.With(ctx, updateLogFields(update)...)
+
+	// Routes report their own errors; this catches panics outside them so
+	// one update cannot take the bot down
+	defer func() {
+		if r := recover(); r != nil {
+			err = logging.NewPanicError(r)
+			logError(ctx, err, logging.NewIncidentID())
+		}
+	}()
+	h.metrics.ObserveUpdate(updateType(update))
 
-	// Check if it's a command
-	if message.IsCommand() {
-		return h.handleCommand(ctx, message, user)
+	// Handle callback queries (inline keyboard button clicks)
+	if update.CallbackQuery != nil {
+		return h.routes().dispatch(ctx, &request{query: update.CallbackQuery})
 	}
 
-	// Otherwise, treat as search query
-	return h.handleSearchQuery(ctx, message, user)
-}
+	// Handle inline queries ("@bot tolkien")
+	if update.InlineQuery != nil {
+		return h.routes().dispatch(ctx, &request{inline: update.InlineQuery})
+	}
 
-// handleCommand processes bot commands.
-func (h *Handler) handleCommand(ctx context.Context, message *tgbotapi.Message, user *models.User) error {
-	command := message.Command()
-
-	switch command {
-	case "start":
-		return h.handleStart(message, user)
-	case "help":
-		return h.handleHelp(message, user)
-	case "kindle":
-		return h.handleKindle(ctx, message, user)
-	case "language":
-		return h.handleLanguage(ctx, message, user)
-	case "whitelist":
-		return h.handleWhitelist(message, user)
-	case "settings":
-		return h.handleSettings(message, user)
-	case "cancel":
-		return h.handleCancel(message, user)
-	default:
-		return h.sendMessage(message.Chat.ID, user.Language, "unknown_command", nil)
+	// Handle commands and search queries
+	if update.Message != nil {
+		return h.routes().dispatch(ctx, &request{message: update.Message})
 	}
+
+	return nil
 }
 
 // handleStart handles /start command.
-func (h *Handler) handleStart(message *tgbotapi.Message, user *models.User) error {
+func (h *Handler) handleStart(ctx context.Context, message *tgbotapi.Message, user *models.User) error {
 	// Send welcome message
-	welcomeMsg := h.i18n.T(user.Language, "welcome", user.FirstName)
-	msg := tgbotapi.NewMessage(message.Chat.ID, welcomeMsg)
-	if _, err := h.bot.Send(msg); err != nil {
+	if err := h.sendMessage(message.Chat.ID, user.Language, "welcome", i18n.Params{"name": user.FirstName}); err != nil {
 		return err
 	}
 
 	// Send whitelist instructions if Kindle email not set
 	if !user.HasKindleEmail() {
-		whitelistMsg := h.i18n.T(user.Language, "whitelist_instructions")
-		msg := tgbotapi.NewMessage(message.Chat.ID, whitelistMsg)
-		msg.ParseMode = "Markdown"
-		if _, err := h.bot.Send(msg); err != nil {
+		if err := h.sendMessage(message.Chat.ID, user.Language, "whitelist_instructions", h.senderEmail); err != nil {
 			return err
 		}
 
 		// Prompt for Kindle email
-		promptMsg := h.i18n.T(user.Language, "kindle_email_prompt")
-		msg = tgbotapi.NewMessage(message.Chat.ID, promptMsg)
-		if _, err := h.bot.Send(msg); err != nil {
-			return err
-		}
-	} else {
-		// User already has Kindle email set
-		searchMsg := h.i18n.T(user.Language, "search_prompt")
-		msg := tgbotapi.NewMessage(message.Chat.ID, searchMsg)
-		if _, err := h.bot.Send(msg); err != nil {
-			return err
-		}
+		return h.sendMessage(message.Chat.ID, user.Language, "kindle_email_prompt")
 	}
 
-	return nil
+	// User already has Kindle email set
+	return h.sendMessage(message.Chat.ID, user.Language, "search_prompt")
 }
 
 // handleHelp handles /help command.
-func (h *Handler) handleHelp(message *tgbotapi.Message, user *models.User) error {
-	return h.sendMessage(message.Chat.ID, user.Language, "help_message", nil)
+func (h *Handler) handleHelp(ctx context.Context, message *tgbotapi.Message, user *models.User) error {
+	return h.sendMessage(message.Chat.ID, user.Language, "help_message", i18n.Params{"commands": h.commandList(user)})
 }
 
 // handleKindle handles /kindle command (set Kindle email).
@@ -136,56 +163,51 @@ func (h *Handler) handleKindle(ctx context.Context, message *tgbotapi.Message, u
 		if user.HasKindleEmail() {
 			return h.sendMessage(message.Chat.ID, user.Language, "kindle_email_current", user.KindleEmail)
 		}
-		return h.sendMessage(message.Chat.ID, user.Language, "kindle_email_prompt", nil)
+		return h.sendMessage(message.Chat.ID, user.Language, "kindle_email_prompt")
 	}
 
 	// Set Kindle email
 	email := strings.TrimSpace(args)
 	if err := h.userManager.SetKindleEmail(ctx, user.TelegramID, email); err != nil {
 		if err == usermanager.ErrInvalidEmail {
-			return h.sendMessage(message.Chat.ID, user.Language, "kindle_email_invalid", nil)
+			return h.sendMessage(message.Chat.ID, user.Language, "kindle_email_invalid")
 		}
 		return err
 	}
 
 	// Send confirmation
-	confirmMsg := h.i18n.T(user.Language, "kindle_email_set", email)
-	msg := tgbotapi.NewMessage(message.Chat.ID, confirmMsg)
-	if _, err := h.bot.Send(msg); err != nil {
+	if err := h.sendMessage(message.Chat.ID, user.Language, "kindle_email_set", email); err != nil {
 		return err
 	}
 
 	// Send whitelist reminder
-	return h.sendMessage(message.Chat.ID, user.Language, "whitelist_reminder", nil)
+	return h.sendMessage(message.Chat.ID, user.Language, "whitelist_reminder")
 }
 
 // handleLanguage handles /language command.
 func (h *Handler) handleLanguage(ctx context.Context, message *tgbotapi.Message, user *models.User) error {
-	// Create inline keyboard with language options
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🇬🇧 English", "lang_en"),
-			tgbotapi.NewInlineKeyboardButtonData("🇷🇺 Русский", "lang_ru"),
-		),
-	)
-
-	msg := tgbotapi.NewMessage(message.Chat.ID, h.i18n.T(user.Language, "language_prompt"))
-	msg.ReplyMarkup = keyboard
+	// Offer every loaded language, two per row, each named in itself
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for n, lang := range h.i18n.GetSupportedLanguages() {
+		button := h.button(h.i18n.T(lang, "language_name"), "lang", lang)
+		if n%2 == 0 {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+		} else {
+			rows[len(rows)-1] = append(rows[len(rows)-1], button)
+		}
+	}
 
-	_, err := h.bot.Send(msg)
+	_, err := h.send(message.Chat.ID, h.text(user.Language, "language_prompt"), tgbotapi.NewInlineKeyboardMarkup(rows...))
 	return err
 }
 
 // handleWhitelist handles /whitelist command.
-func (h *Handler) handleWhitelist(message *tgbotapi.Message, user *models.User) error {
-	msg := tgbotapi.NewMessage(message.Chat.ID, h.i18n.T(user.Language, "whitelist_instructions"))
-	msg.ParseMode = "Markdown"
-	_, err := h.bot.Send(msg)
-	return err
+func (h *Handler) handleWhitelist(ctx context.Context, message *tgbotapi.Message, user *models.User) error {
+	return h.sendMessage(message.Chat.ID, user.Language, "whitelist_instructions", h.senderEmail)
 }
 
 // handleSettings handles /settings command.
-func (h *Handler) handleSettings(message *tgbotapi.Message, user *models.User) error {
+func (h *Handler) handleSettings(ctx context.Context, message *tgbotapi.Message, user *models.User) error {
 	// Display current settings
 	kindleEmail := user.KindleEmail
 	if kindleEmail == "" {
@@ -193,20 +215,111 @@ func (h *Handler) handleSettings(message *tgbotapi.Message, user *models.User) e
 	}
 
 	language := user.Language
-	if language == "en" {
-		language = "English"
-	} else if language == "ru" {
-		language = "Русский"
+	if h.i18n.IsSupported(language) {
+		language = h.i18n.T(language, "language_name")
 	}
 
-	return h.sendMessage(message.Chat.ID, user.Language, "settings_display", kindleEmail, language, user.BooksSent)
+	return h.sendMessage(message.Chat.ID, user.Language, "settings_display", i18n.Params{
+		"email":    kindleEmail,
+		"language": language,
+		"count":    user.BooksSent,
+	})
 }
 
 // handleCancel handles /cancel command.
-func (h *Handler) handleCancel(message *tgbotapi.Message, user *models.User) error {
+func (h *Handler) handleCancel(ctx context.Context, message *tgbotapi.Message, user *models.User) error {
 	// Cancel any active search context
 	// This will be implemented when we add search functionality
-	return h.sendMessage(message.Chat.ID, user.Language, "operation_cancelled", nil)
+	return h.sendMessage(message.Chat.ID, user.Language, "operation_cancelled")
+}
+
+// handleExportMyData handles /export_my_data command by sending everything
+// stored about the user as a JSON document.
+func (h *Handler) handleExportMyData(ctx context.Context, message *tgbotapi.Message, user *models.User) error {
+	data, err := h.userManager.ExportUserData(ctx, user.TelegramID)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	doc := tgbotapi.NewDocument(message.Chat.ID, tgbotapi.FileBytes{Name: "my_data.json", Bytes: content})
+	doc.Caption = h.text(user.Language, "export_my_data_caption")
+	doc.ParseMode = format.ModeHTML
+	_, err = h.bot.Send(doc)
+	return err
+}
+
+// handleDeleteMe handles /delete_me command by asking for confirmation.
+func (h *Handler) handleDeleteMe(ctx context.Context, message *tgbotapi.Message, user *models.User) error {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			h.button(h.i18n.T(user.Language, "delete_me_button"), "delete_me", "confirm"),
+			h.button(h.i18n.T(user.Language, "cancel"), "delete_me", "cancel"),
+		),
+	)
+
+	_, err := h.send(message.Chat.ID, h.text(user.Language, "delete_me_prompt"), keyboard)
+	return err
+}
+
+// handleDeleteMeCallback handles the /delete_me confirmation buttons.
+func (h *Handler) handleDeleteMeCallback(ctx context.Context, query *tgbotapi.CallbackQuery, data callback.Data, user *models.User) error {
+	key := "operation_cancelled"
+	if data.Arg(0) == "confirm" {
+		if err := h.userManager.DeleteUserData(ctx, user.TelegramID); err != nil {
+			return err
+		}
+		logging.FromContext(ctx).Info("Deleted user data on request")
+		key = "delete_me_done"
+	}
+
+	if _, err := h.bot.Request(tgbotapi.NewCallback(query.ID, "")); err != nil {
+		return err
+	}
+
+	// Replacing the text also removes the confirmation buttons
+	return h.edit(query.Message.Chat.ID, query.Message.MessageID, h.text(user.Language, key), nil)
+}
+
+// handleCleanup handles the admin /cleanup command by running maintenance now.
+func (h *Handler) handleCleanup(ctx context.Context, message *tgbotapi.Message, user *models.User) error {
+	logging.FromContext(ctx).Info("Maintenance triggered by admin")
+	report := h.maintenance.Run(ctx, "admin")
+
+	count := func(key string, n int) htmlText {
+		return htmlText(h.text(user.Language, key, i18n.Params{"count": n}))
+	}
+	text := h.text(user.Language, "cleanup_report", i18n.Params{
+		"duration": report.Duration.Round(time.Millisecond).String(),
+		"users":    count("cleanup_users", report.UsersDeactivated),
+		"sessions": count("cleanup_sessions", report.SearchContextsPurged),
+		"errors":   count("cleanup_errors", len(report.Errors)),
+	})
+	for _, e := range report.Errors {
+		text += "\n• " + format.HTML(e)
+	}
+
+	_, err := h.send(message.Chat.ID, text, nil)
+	return err
+}
+
+// handleReloadTranslations handles the admin /reload_translations command.
+// Broken translation files are reported and the current ones kept.
+func (h *Handler) handleReloadTranslations(ctx context.Context, message *tgbotapi.Message, user *models.User) error {
+	if err := h.i18n.Reload(); err != nil {
+		logging.FromContext(ctx).Error("Failed to reload translations", "error", err)
+		return h.sendMessage(message.Chat.ID, user.Language, "translations_reload_failed", err.Error())
+	}
+
+	logging.FromContext(ctx).Info("Translations reloaded by admin")
+	if err := h.RegisterCommands(ctx); err != nil {
+		logging.FromContext(ctx).Warn("Failed to register bot commands", "error", err)
+	}
+	return h.sendMessage(message.Chat.ID, user.Language, "translations_reloaded", strings.Join(h.i18n.GetSupportedLanguages(), ", "))
 }
 
 // handleSearchQuery handles text messages as book search queries.
@@ -216,30 +329,26 @@ func (h *Handler) handleSearchQuery(ctx context.Context, message *tgbotapi.Messa
 	// Check if user has Kindle email set
 	if !user.HasKindleEmail() {
 		// Try to parse the message as a Kindle email
-		if strings.Contains(query, "@kindle.com") {
+		if strings.Contains(query, "@") && !strings.ContainsAny(query, " \t\n") {
 			if err := h.userManager.SetKindleEmail(ctx, user.TelegramID, query); err != nil {
-				return h.sendMessage(message.Chat.ID, user.Language, "kindle_email_invalid", nil)
+				return h.sendMessage(message.Chat.ID, user.Language, "kindle_email_invalid")
 			}
 
 			// Send confirmation
-			confirmMsg := h.i18n.T(user.Language, "kindle_email_set", query)
-			msg := tgbotapi.NewMessage(message.Chat.ID, confirmMsg)
-			if _, err := h.bot.Send(msg); err != nil {
+			if err := h.sendMessage(message.Chat.ID, user.Language, "kindle_email_set", query); err != nil {
 				return err
 			}
 
 			// Send whitelist reminder
-			return h.sendMessage(message.Chat.ID, user.Language, "whitelist_reminder", nil)
+			return h.sendMessage(message.Chat.ID, user.Language, "whitelist_reminder")
 		}
 
 		// User needs to set Kindle email first
-		return h.sendMessage(message.Chat.ID, user.Language, "kindle_email_required", nil)
+		return h.sendMessage(message.Chat.ID, user.Language, "kindle_email_required")
 	}
 
 	// Send "searching..." message
-	searchingMsg := h.i18n.T(user.Language, "searching", query)
-	statusMsg := tgbotapi.NewMessage(message.Chat.ID, searchingMsg)
-	sentMsg, err := h.bot.Send(statusMsg)
+	sentMsg, err := h.send(message.Chat.ID, h.text(user.Language, "searching", query), nil)
 	if err != nil {
 		return err
 	}
@@ -248,64 +357,143 @@ func (h *Handler) handleSearchQuery(ctx context.Context, message *tgbotapi.Messa
 	// For now, just send a placeholder message
 	_ = sentMsg // Will use this to update the message later
 
-	return h.sendMessage(message.Chat.ID, user.Language, "search_not_implemented", nil)
+	return h.sendMessage(message.Chat.ID, user.Language, "search_not_implemented")
 }
 
-// handleCallbackQuery handles inline keyboard button clicks.
-func (h *Handler) handleCallbackQuery(ctx context.Context, query *tgbotapi.CallbackQuery) error {
-	// Get user
-	user, err := h.userManager.GetOrCreateUser(ctx, query.From.ID, query.From.FirstName, query.From.LastName, query.From.UserName, query.From.LanguageCode)
-	if err != nil {
-		log.Printf("Failed to get/create user: %v", err)
+// handleLanguageCallback handles the /language buttons ("lang <code>").
+func (h *Handler) handleLanguageCallback(ctx context.Context, query *tgbotapi.CallbackQuery, data callback.Data, user *models.User) error {
+	lang := data.Arg(0)
+	if !h.i18n.IsSupported(lang) {
+		_, err := h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
 		return err
 	}
 
-	// Parse callback data
-	data := query.Data
+	// Update user language
+	if err := h.userManager.SetLanguage(ctx, user.TelegramID, lang); err != nil {
+		return err
+	}
 
-	// Handle language selection
-	if strings.HasPrefix(data, "lang_") {
-		lang := strings.TrimPrefix(data, "lang_")
+	// Send confirmation
+	answer := tgbotapi.NewCallback(query.ID, h.i18n.T(lang, "language_changed"))
+	if _, err := h.bot.Request(answer); err != nil {
+		return err
+	}
 
-		// Update user language
-		if err := h.userManager.SetLanguage(ctx, user.TelegramID, lang); err != nil {
-			return err
+	// Edit the original message
+	return h.edit(query.Message.Chat.ID, query.Message.MessageID, h.text(lang, "language_changed"), nil)
+}
+
+// sendMessage is a helper to send localized messages.
+func (h *Handler) sendMessage(chatID int64, language, key string, args ...interface{}) error {
+	_, err := h.send(chatID, h.text(language, key, args...), nil)
+	return err
+}
+
+// text translates a message. Translations are HTML, so the values put into
+// them are escaped.
+func (h *Handler) text(language, key string, args ...interface{}) string {
+	return h.i18n.T(language, key, escapeArgs(args)...)
+}
+
+// send sends HTML text to a chat, split into as many messages as Telegram
+// needs, with the reply markup on the last one. It returns the last message.
+func (h *Handler) send(chatID int64, text string, markup interface{}) (tgbotapi.Message, error) {
+	var sent tgbotapi.Message
+	parts := format.Split(text, format.MaxMessageLength)
+	for n, part := range parts {
+		msg := tgbotapi.NewMessage(chatID, part)
+		msg.ParseMode = format.ModeHTML
+		if n == len(parts)-1 && markup != nil {
+			msg.ReplyMarkup = markup
 		}
 
-		// Send confirmation
-		callback := tgbotapi.NewCallback(query.ID, h.i18n.T(lang, "language_changed"))
-		if _, err := h.bot.Request(callback); err != nil {
-			return err
+		var err error
+		if sent, err = h.bot.Send(msg); err != nil {
+			return sent, err
 		}
+	}
+	return sent, nil
+}
 
-		// Edit the original message
-		editMsg := tgbotapi.NewEditMessageText(
-			query.Message.Chat.ID,
-			query.Message.MessageID,
-			h.i18n.T(lang, "language_changed"),
-		)
-		_, err := h.bot.Send(editMsg)
-		return err
+// edit replaces the HTML text and keyboard of a message. A message cannot be
+// edited into several, so text beyond Telegram's limit is dropped.
+func (h *Handler) edit(chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, format.Split(text, format.MaxMessageLength)[0])
+	edit.ParseMode = format.ModeHTML
+	edit.ReplyMarkup = keyboard
+
+	_, err := h.bot.Send(edit)
+	return err
+}
+
+// htmlText is a message argument that is HTML already, such as text built
+// from other messages, and is not escaped again
+type htmlText string
+
+// escapeArgs escapes the text among message arguments for HTML
+func escapeArgs(args []interface{}) []interface{} {
+	escaped := make([]interface{}, len(args))
+	for n, arg := range args {
+		switch v := arg.(type) {
+		case i18n.Params:
+			params := make(i18n.Params, len(v))
+			for name, value := range v {
+				params[name] = escapeArg(value)
+			}
+			escaped[n] = params
+		default:
+			escaped[n] = escapeArg(arg)
+		}
 	}
+	return escaped
+}
 
-	// Handle book selection (will implement with search)
-	if strings.HasPrefix(data, "book_") {
-		// TODO: Implement book selection and download
-		callback := tgbotapi.NewCallback(query.ID, h.i18n.T(user.Language, "feature_coming_soon"))
-		_, err := h.bot.Request(callback)
-		return err
+// escapeArg escapes a single text argument for HTML
+func escapeArg(arg interface{}) interface{} {
+	switch v := arg.(type) {
+	case string:
+		return format.HTML(v)
+	case htmlText:
+		return string(v)
+	default:
+		return arg
 	}
+}
 
-	// Unknown callback
-	callback := tgbotapi.NewCallback(query.ID, "")
-	_, err = h.bot.Request(callback)
-	return err
+// updateLogFields returns the log fields identifying an update.
+func updateLogFields(update *tgbotapi.Update) []any {
+	fields := []any{"update_id", update.UpdateID}
+
+	// FromChat panics for presses on inline results, which have no chat
+	if update.CallbackQuery == nil || update.CallbackQuery.Message != nil {
+		if chat := update.FromChat(); chat != nil {
+			fields = append(fields, "chat_id", chat.ID)
+		}
+	}
+	if from := update.SentFrom(); from != nil {
+		fields = append(fields, "user_id", from.ID)
+	}
+	if update.Message != nil && update.Message.IsCommand() {
+		fields = append(fields, "command", update.Message.Command())
+	}
+
+	return fields
 }
 
-// sendMessage is a helper to send localized messages.
-func (h *Handler) sendMessage(chatID int64, language, key string, args ...interface{}) error {
-	text := h.i18n.T(language, key, args...)
-	msg := tgbotapi.NewMessage(chatID, text)
-	_, err := h.bot.Send(msg)
-	return err
+// updateType returns the kind of an update for metrics.
+func updateType(update *tgbotapi.Update) string {
+	switch {
+	case update.Message != nil:
+		return "message"
+	case update.EditedMessage != nil:
+		return "edited_message"
+	case update.CallbackQuery != nil:
+		return "callback_query"
+	case update.InlineQuery != nil:
+		return "inline_query"
+	case update.ChosenInlineResult != nil:
+		return "chosen_inline_result"
+	default:
+		return "other"
+	}
 }
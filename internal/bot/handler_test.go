@@ -4,10 +4,12 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/format"
 	"github.com/stpatrick2016/flibusta_kindle_bot/internal/i18n"
 	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
 )
@@ -39,26 +41,85 @@ func setupTestHandler(t *testing.T) (*Handler, *mockBotAPI, *user.Manager) {
 
 	// Create minimal test locale files
 	enContent := `{
-		"welcome": "Welcome, %s!",
+		"welcome": "Welcome, {name}!",
 		"help_message": "Help text",
 		"kindle_email_prompt": "Send your Kindle email",
 		"kindle_email_set": "Email set to %s",
 		"kindle_email_invalid": "Invalid email",
 		"kindle_email_current": "Current email: %s",
-		"whitelist_instructions": "Whitelist instructions",
+		"whitelist_instructions": "Whitelist %s",
 		"whitelist_reminder": "Remember to whitelist",
 		"language_prompt": "Select language",
+		"language_name": "English",
+		"translations_reloaded": "Reloaded %s",
+		"translations_reload_failed": "Reload failed: %s",
 		"language_changed": "Language changed",
-		"settings_display": "Email: %s, Language: %s, Books: %d",
+		"settings_display": {"one": "Email: {email}, Language: {language}, {count} book", "other": "Email: {email}, Language: {language}, {count} books"},
 		"operation_cancelled": "Cancelled",
 		"unknown_command": "Unknown command",
+		"rate_limited": "Slow down",
+		"button_expired": "Button expired",
 		"error_occurred": "Error occurred",
+		"error_timeout": "Timed out",
+		"incident_id": "ID %s",
 		"kindle_email_required": "Email required",
 		"searching": "Searching for %s",
 		"search_not_implemented": "Coming soon",
 		"search_prompt": "Type to search",
 		"feature_coming_soon": "Coming soon",
-		"not_set": "not set"
+		"not_set": "not set",
+		"cancel": "Cancel",
+		"export_my_data_caption": "Your data",
+		"delete_me_prompt": "Are you sure?",
+		"delete_me_button": "Delete",
+		"delete_me_done": "Deleted",
+		"verify_document": "Test document",
+		"verify_sent": "Test sent to %s",
+		"verify_arrived_button": "Arrived",
+		"verify_missing_button": "Missing",
+		"verify_done": "Verified",
+		"verify_email_changed": "Email changed",
+		"verify_failed": "Not verified",
+		"verify_reminder": "Consider /verify",
+		"book_queued": "Book queued",
+		"book_sent": "Book sent to %s",
+		"book_not_found": "Book not found",
+		"book_send_failed": "Send failed",
+		"book_too_large": "Too large",
+		"book_too_large_telegram": "Too large for Telegram",
+		"format_not_supported": "Format %s not supported",
+		"send_to_kindle": "Send to Kindle",
+		"inline_result": "%s by %s (%s)",
+		"save_to_list": "Save",
+		"list_empty": "List empty",
+		"list_header": "List ({count}):",
+		"list_send_all_button": "Send all",
+		"list_added": "Saved",
+		"list_already_saved": "Already saved",
+		"list_full": "List full",
+		"list_removed": "Removed",
+		"list_queued": {"one": "Queued {queued} of {count} book", "other": "Queued {queued} of {count} books"},
+		"list_already_queued": "Already queued",
+		"list_item_sent": "Sent %s",
+		"list_item_failed": "Failed %s",
+		"delivery_busy": "Busy",
+		"follow_usage": "Follow usage",
+		"follow_added": "Following",
+		"follow_already": "Already following",
+		"follow_limit": "Too many follows",
+		"follow_empty": "No follows",
+		"follow_list_header": "Follows ({count}):",
+		"follow_removed": "Unfollowed",
+		"follow_auto_on": "Auto on",
+		"follow_auto_off": "Auto off",
+		"release_notification": "New from %s: %s",
+		"release_auto": "New from %s: %s, sending",
+		"release_sent": "Release sent %s",
+		"release_send_failed": "Release failed %s",
+		"cleanup_report": "Cleanup {duration}: {users}, {sessions}, {errors}",
+		"cleanup_users": {"one": "{count} user", "other": "{count} users"},
+		"cleanup_sessions": {"one": "{count} search", "other": "{count} searches"},
+		"cleanup_errors": {"one": "{count} error", "other": "{count} errors"}
 	}`
 
 	// Write test locale file
@@ -330,3 +391,149 @@ func TestHandler_BookSentCounter(t *testing.T) {
 		t.Errorf("BooksSent = %v, want %v", updatedUser.BooksSent, 3)
 	}
 }
+
+func TestHandler_MessagesWithoutArguments(t *testing.T) {
+	handler, telegram, _ := setupDeliveryHandler(t)
+	ctx := context.Background()
+
+	// Keys without verbs used to get a nil argument and "%!(EXTRA <nil>)"
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{command: "/help", want: "Help text"},
+		{command: "/nonexistent", want: "Unknown command"},
+		{command: "/kindle", want: "Send your Kindle email"},
+		{command: "/kindle not-an-email", want: "Invalid email"},
+	}
+	for _, tt := range tests {
+		if err := handler.HandleUpdate(ctx, commandUpdate(tt.command)); err != nil {
+			t.Fatalf("HandleUpdate(%s) error = %v", tt.command, err)
+		}
+		if text := lastText(telegram); text != tt.want {
+			t.Errorf("%s reply = %q, want %q", tt.command, text, tt.want)
+		}
+	}
+}
+
+func TestHandler_Language(t *testing.T) {
+	handler, telegram, _ := setupDeliveryHandler(t)
+	ctx := context.Background()
+
+	// The keyboard lists every embedded language
+	translations, err := i18n.NewEmbedded("", "en")
+	if err != nil {
+		t.Fatalf("NewEmbedded() error = %v", err)
+	}
+	handler.i18n = translations
+
+	if err := handler.HandleUpdate(ctx, commandUpdate("/language")); err != nil {
+		t.Fatalf("HandleUpdate(/language) error = %v", err)
+	}
+	calls := telegram.requests()
+	markup := calls[len(calls)-1].params["reply_markup"]
+	for _, lang := range translations.GetSupportedLanguages() {
+		if !strings.Contains(markup, `"`+callbackData("lang", lang)+`"`) {
+			t.Errorf("keyboard = %s, want a button for %s", markup, lang)
+		}
+	}
+
+	// Unknown languages are ignored
+	if err := handler.HandleUpdate(ctx, callbackUpdate("lang", "xx")); err != nil {
+		t.Fatalf("HandleUpdate(lang_xx) error = %v", err)
+	}
+	if user, _ := handler.userManager.GetUser(ctx, 12345); user.Language != "en" {
+		t.Errorf("Language = %q after unknown language, want en", user.Language)
+	}
+
+	if err := handler.HandleUpdate(ctx, callbackUpdate("lang", "uk")); err != nil {
+		t.Fatalf("HandleUpdate(lang_uk) error = %v", err)
+	}
+	if text := lastText(telegram); text != "✅ Мову змінено на українську" {
+		t.Errorf("confirmation = %q", text)
+	}
+
+	if err := handler.HandleUpdate(ctx, commandUpdate("/settings")); err != nil {
+		t.Fatalf("HandleUpdate(/settings) error = %v", err)
+	}
+	if text := lastText(telegram); !strings.Contains(text, "🇺🇦 Українська") || !strings.Contains(text, "Надіслано 0 книг\n") {
+		t.Errorf("/settings = %q, want the language name and books sent", text)
+	}
+}
+
+func TestHandler_Start_EmbeddedLocales(t *testing.T) {
+	handler, telegram, _ := setupDeliveryHandler(t)
+	ctx := context.Background()
+	translations, err := i18n.NewEmbedded("", "en")
+	if err != nil {
+		t.Fatalf("NewEmbedded() error = %v", err)
+	}
+	handler.i18n = translations
+	if _, err := handler.userManager.GetOrCreateUser(ctx, 12345, "testuser", "Test", "User", "en"); err != nil {
+		t.Fatalf("GetOrCreateUser() error = %v", err)
+	}
+
+	for _, lang := range translations.GetSupportedLanguages() {
+		if err := handler.userManager.SetLanguage(ctx, 12345, lang); err != nil {
+			t.Fatalf("SetLanguage(%s) error = %v", lang, err)
+		}
+		sent := len(telegram.requests())
+		if err := handler.HandleUpdate(ctx, commandUpdate("/start")); err != nil {
+			t.Fatalf("%s: HandleUpdate(/start) error = %v", lang, err)
+		}
+		welcome := telegram.requests()[sent].params["text"]
+		if !strings.Contains(welcome, "Test") || strings.Contains(welcome, "%!") {
+			t.Errorf("%s: welcome = %q, want the first name", lang, welcome)
+		}
+	}
+}
+
+func TestHandler_SendMessage_Formatting(t *testing.T) {
+	handler, _, _ := setupTestHandler(t)
+	bot, telegram := newTestBot(t)
+	handler.bot = bot
+
+	// Values from users are shown literally
+	if err := handler.sendMessage(12345, "en", "kindle_email_current", "<b>me</b>&co@kindle.com"); err != nil {
+		t.Fatalf("sendMessage() error = %v", err)
+	}
+	calls := telegram.requests()
+	last := calls[len(calls)-1]
+	if text := last.params["text"]; text != "Current email: &lt;b&gt;me&lt;/b&gt;&amp;co@kindle.com" {
+		t.Errorf("text = %q", text)
+	}
+	if mode := last.params["parse_mode"]; mode != format.ModeHTML {
+		t.Errorf("parse_mode = %q, want %s", mode, format.ModeHTML)
+	}
+
+	// Long texts are split, with the keyboard on the last part
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("OK", "ok")))
+	text := strings.Repeat("📚 A rather long line about a book\n", 200)
+	if _, err := handler.send(12345, text, keyboard); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	parts := telegram.requests()[len(calls):]
+	if len(parts) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(parts))
+	}
+	for n, part := range parts {
+		if length := format.Length(part.params["text"]); length > format.MaxMessageLength {
+			t.Errorf("part %d is %d long", n, length)
+		}
+		if hasKeyboard := part.params["reply_markup"] != ""; hasKeyboard != (n == len(parts)-1) {
+			t.Errorf("part %d has keyboard = %v", n, hasKeyboard)
+		}
+	}
+}
+
+func TestEscapeArgs(t *testing.T) {
+	args := escapeArgs([]interface{}{"a<b", 3, i18n.Params{"query": "x&y", "count": 2}})
+
+	if args[0] != "a&lt;b" || args[1] != 3 {
+		t.Errorf("escapeArgs() = %v", args)
+	}
+	params := args[2].(i18n.Params)
+	if params["query"] != "x&amp;y" || params["count"] != 2 {
+		t.Errorf("escaped params = %v", params)
+	}
+}
@@ -0,0 +1,166 @@
+package bot
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/format"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/search"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/tracing"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+const (
+	// inlinePageSize is the number of inline results per page; Telegram allows 50
+	inlinePageSize = 20
+	// inlineCacheTime is how long Telegram may cache an inline answer, in seconds
+	inlineCacheTime = 300
+)
+
+// EnableSearch lets users search books inline ("@bot tolkien"). Results are
+// kept in contexts so books saved from them can be listed by title.
+func (h *Handler) EnableSearch(searcher search.Searcher, contexts *search.ContextStore) {
+	h.searcher = searcher
+	h.contexts = contexts
+}
+
+// search searches the catalog for query
+func (h *Handler) search(ctx context.Context, query string) (books []models.Book, err error) {
+	ctx, span := tracing.Start(ctx, "bot.search")
+	defer func() { tracing.End(span, err) }()
+
+	books, err = h.searcher.Search(ctx, query)
+	span.SetAttributes(attribute.Int("search.results", len(books)))
+	return books, err
+}
+
+// handleInlineQuery answers an inline query with a page of matching books.
+// The offset Telegram sends back for the next page is the index of its first
+// result.
+func (h *Handler) handleInlineQuery(ctx context.Context, query *tgbotapi.InlineQuery, user *models.User) (err error) {
+	ctx, span := tracing.Start(ctx, "bot.inline_search")
+	defer func() { tracing.End(span, err) }()
+
+	answer := emptyInlineAnswer(query)
+	answer.CacheTime = inlineCacheTime
+
+	text := strings.TrimSpace(query.Query)
+	if h.searcher == nil || text == "" {
+		_, err := h.bot.Request(answer)
+		return err
+	}
+
+	offset, err := strconv.Atoi(query.Offset)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	span.SetAttributes(attribute.Int("search.offset", offset))
+
+	start := time.Now()
+	books, err := h.search(ctx, text)
+	if offset == 0 {
+		h.metrics.ObserveSearch(time.Since(start), len(books), err)
+		if err == nil && h.contexts != nil {
+			h.contexts.Set(user.TelegramID, text, books)
+		}
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("Inline search failed", "error", err)
+		answer.CacheTime = 0
+		_, err := h.bot.Request(answer)
+		return err
+	}
+
+	if offset < len(books) {
+		end := min(offset+inlinePageSize, len(books))
+		for _, book := range books[offset:end] {
+			answer.Results = append(answer.Results, h.inlineResult(user.Language, &book))
+		}
+		if end < len(books) {
+			answer.NextOffset = strconv.Itoa(end)
+		}
+	}
+
+	_, err = h.bot.Request(answer)
+	return err
+}
+
+// emptyInlineAnswer returns an answer to query without results, not to be
+// cached. Result messages are in the user's language, so answers are
+// personal.
+func emptyInlineAnswer(query *tgbotapi.InlineQuery) tgbotapi.InlineConfig {
+	return tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		IsPersonal:    true,
+		Results:       []interface{}{},
+	}
+}
+
+// inlineResult returns the article shared for a book, with buttons sending it
+// to the Kindle of whoever presses them, saving it to their reading list or
+// following its author and series.
+func (h *Handler) inlineResult(language string, book *models.Book) tgbotapi.InlineQueryResultArticle {
+	id := book.ID
+	if book.Format != "" {
+		id += "_" + book.Format
+	}
+
+	bookFormat := strings.ToUpper(book.Format)
+	var details []string
+	for _, detail := range []string{book.Author, bookFormat} {
+		if detail != "" {
+			details = append(details, detail)
+		}
+	}
+
+	article := tgbotapi.NewInlineQueryResultArticle(id, book.Title, "")
+	article.InputMessageContent = tgbotapi.InputTextMessageContent{
+		Text:      h.text(language, "inline_result", book.Title, book.Author, bookFormat),
+		ParseMode: format.ModeHTML,
+	}
+	article.Description = strings.Join(details, " · ")
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			h.button(h.i18n.T(language, "send_to_kindle"), "book", book.ID, book.Format),
+			h.button(h.i18n.T(language, "save_to_list"), "save", book.ID, book.Format),
+		),
+	}
+	if h.follows != nil {
+		var follow []tgbotapi.InlineKeyboardButton
+		if book.AuthorID != "" {
+			follow = append(follow, h.followButton(models.FollowAuthor, book.AuthorID, firstAuthor(book.Author)))
+		}
+		if book.SeriesID != "" {
+			follow = append(follow, h.followButton(models.FollowSeries, book.SeriesID, book.Series))
+		}
+		if len(follow) > 0 {
+			rows = append(rows, follow)
+		}
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	article.ReplyMarkup = &keyboard
+
+	return article
+}
+
+// callbackChatID returns the chat to reply to a button press in. Buttons on
+// shared inline results are not in a chat with the bot, so those presses are
+// answered privately.
+func callbackChatID(query *tgbotapi.CallbackQuery) int64 {
+	if query.Message != nil {
+		return query.Message.Chat.ID
+	}
+	return query.From.ID
+}
+
+// firstAuthor returns the first name in a book's comma separated authors
+func firstAuthor(authors string) string {
+	first, _, _ := strings.Cut(authors, ", ")
+	return first
+}
@@ -0,0 +1,169 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// fakeSearcher returns books titled "Book N" by Tolkien
+type fakeSearcher struct {
+	count int
+	err   error
+}
+
+func (s *fakeSearcher) Search(ctx context.Context, query string) ([]models.Book, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	books := make([]models.Book, s.count)
+	for i := range books {
+		books[i] = models.Book{ID: strconv.Itoa(i + 1), Title: "Book " + strconv.Itoa(i+1), Author: "Tolkien", Format: "epub"}
+	}
+	return books, nil
+}
+
+// panickingSearcher panics when searching, for requests that must not search
+type panickingSearcher struct{}
+
+func (panickingSearcher) Search(ctx context.Context, query string) ([]models.Book, error) {
+	panic("searched")
+}
+
+// inlineUpdate returns an inline query from user 12345
+func inlineUpdate(query, offset string) *tgbotapi.Update {
+	return &tgbotapi.Update{
+		UpdateID: 3,
+		InlineQuery: &tgbotapi.InlineQuery{
+			ID:     "iq",
+			From:   &tgbotapi.User{ID: 12345, UserName: "testuser", FirstName: "Test", LastName: "User", LanguageCode: "en"},
+			Query:  query,
+			Offset: offset,
+		},
+	}
+}
+
+// inlineArticle is the part of an inline result checked by the tests
+type inlineArticle struct {
+	ID                  string `json:"id"`
+	Title               string `json:"title"`
+	Description         string `json:"description"`
+	InputMessageContent struct {
+		Text string `json:"message_text"`
+	} `json:"input_message_content"`
+	ReplyMarkup tgbotapi.InlineKeyboardMarkup `json:"reply_markup"`
+}
+
+// lastInlineAnswer returns the last answerInlineQuery call and its results
+func lastInlineAnswer(t *testing.T, telegram *fakeTelegram) (telegramCall, []inlineArticle) {
+	t.Helper()
+
+	calls := telegram.requests()
+	call := calls[len(calls)-1]
+	if call.method != "answerInlineQuery" {
+		t.Fatalf("last call = %s, want answerInlineQuery", call.method)
+	}
+	var results []inlineArticle
+	if err := json.Unmarshal([]byte(call.params["results"]), &results); err != nil {
+		t.Fatalf("results = %q: %v", call.params["results"], err)
+	}
+	return call, results
+}
+
+func TestHandler_InlineQuery(t *testing.T) {
+	handler, _, _ := setupTestHandler(t)
+	bot, telegram := newTestBot(t)
+	handler.bot = bot
+	handler.EnableSearch(&fakeSearcher{count: 25}, nil)
+	ctx := context.Background()
+
+	if err := handler.HandleUpdate(ctx, inlineUpdate("tolkien", "")); err != nil {
+		t.Fatalf("HandleUpdate(inline) error = %v", err)
+	}
+	call, results := lastInlineAnswer(t, telegram)
+	if len(results) != inlinePageSize || call.params["next_offset"] != "20" {
+		t.Fatalf("first page = %d results, next offset %q, want 20 and 20", len(results), call.params["next_offset"])
+	}
+	first := results[0]
+	if first.ID != "1_epub" || first.Title != "Book 1" || first.Description != "Tolkien · EPUB" {
+		t.Errorf("result = %+v", first)
+	}
+	if first.InputMessageContent.Text != "Book 1 by Tolkien (EPUB)" {
+		t.Errorf("message = %q", first.InputMessageContent.Text)
+	}
+	if data := *first.ReplyMarkup.InlineKeyboard[0][0].CallbackData; data != callbackData("book", "1", "epub") {
+		t.Errorf("button = %q, want book 1 epub", data)
+	}
+
+	if err := handler.HandleUpdate(ctx, inlineUpdate("tolkien", "20")); err != nil {
+		t.Fatalf("HandleUpdate(inline page 2) error = %v", err)
+	}
+	call, results = lastInlineAnswer(t, telegram)
+	if len(results) != 5 || results[0].ID != "21_epub" || call.params["next_offset"] != "" {
+		t.Errorf("second page = %d results from %v, next offset %q, want 5 from 21_epub and none", len(results), results, call.params["next_offset"])
+	}
+}
+
+func TestHandler_InlineQuery_Empty(t *testing.T) {
+	tests := []struct {
+		name     string
+		searcher *fakeSearcher
+		query    string
+		offset   string
+	}{
+		{name: "search disabled", query: "tolkien"},
+		{name: "blank query", searcher: &fakeSearcher{count: 5}, query: "  "},
+		{name: "no results", searcher: &fakeSearcher{}, query: "tolkien"},
+		{name: "past the end", searcher: &fakeSearcher{count: 5}, query: "tolkien", offset: "20"},
+		{name: "search failed", searcher: &fakeSearcher{err: errors.New("flibusta down")}, query: "tolkien"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _, _ := setupTestHandler(t)
+			bot, telegram := newTestBot(t)
+			handler.bot = bot
+			if tt.searcher != nil {
+				handler.EnableSearch(tt.searcher, nil)
+			}
+
+			if err := handler.HandleUpdate(context.Background(), inlineUpdate(tt.query, tt.offset)); err != nil {
+				t.Fatalf("HandleUpdate(inline) error = %v", err)
+			}
+			if _, results := lastInlineAnswer(t, telegram); len(results) != 0 {
+				t.Errorf("answered %d results, want 0", len(results))
+			}
+		})
+	}
+}
+
+func TestHandler_InlineResultButton(t *testing.T) {
+	handler, telegram, email := setupDeliveryHandler(t)
+	ctx := context.Background()
+
+	if err := handler.HandleUpdate(ctx, commandUpdate("/kindle reader@kindle.com")); err != nil {
+		t.Fatalf("HandleUpdate(/kindle) error = %v", err)
+	}
+
+	// Buttons on shared results carry no message, the reply goes to the presser
+	update := callbackUpdate("book", "42", "epub")
+	update.CallbackQuery.Message = nil
+	update.CallbackQuery.InlineMessageID = "inline"
+	if err := handler.HandleUpdate(ctx, update); err != nil {
+		t.Fatalf("HandleUpdate(book_42_epub) error = %v", err)
+	}
+
+	sent := waitForRequest(t, telegram, 0, isText("Book sent to reader@kindle.com"))
+	if sent.params["chat_id"] != "12345" {
+		t.Errorf("confirmation sent to %s, want 12345", sent.params["chat_id"])
+	}
+	if len(email.sent) != 1 {
+		t.Errorf("sent %d emails, want 1", len(email.sent))
+	}
+}
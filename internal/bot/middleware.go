@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+)
+
+// observe records commands and button presses in metrics and traces
+func (h *Handler) observe(next route) route {
+	return func(ctx context.Context, req *request) error {
+		span := trace.SpanFromContext(ctx)
+		switch {
+		case req.inline != nil:
+			// Counted as an update type only
+		case req.query != nil:
+			span.SetAttributes(attribute.String("telegram.callback", req.action))
+			h.metrics.ObserveCallback(req.action)
+		case req.action != "text":
+			span.SetAttributes(attribute.String("telegram.command", req.action))
+			h.metrics.ObserveCommand(req.action)
+		}
+		return next(ctx, req)
+	}
+}
+
+// loadUser gets or creates the user sending the request. Requests without
+// a sender, such as channel posts, and requests from banned users are
+// dropped.
+func (h *Handler) loadUser(next route) route {
+	return func(ctx context.Context, req *request) error {
+		from := req.from()
+		if from == nil {
+			return nil
+		}
+
+		user, err := h.userManager.GetOrCreateUser(ctx, from.ID, from.UserName, from.FirstName, from.LastName, from.LanguageCode)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to get/create user", "error", err)
+			return err
+		}
+		if user.IsBanned {
+			h.metrics.UpdateRejected("banned")
+			return h.answerRejected(req, "")
+		}
+
+		req.user = user
+		return next(ctx, req)
+	}
+}
+
+// rateLimit drops requests from users over their rate limit, telling them
+// once until they are allowed again. Inline queries count against their
+// own limit, so typing a search does not block commands and buttons.
+func (h *Handler) rateLimit(next route) route {
+	return func(ctx context.Context, req *request) error {
+		limiter := h.limiter
+		if req.inline != nil {
+			limiter = h.inlineLimit
+		}
+		if limiter == nil {
+			return next(ctx, req)
+		}
+
+		allowed, notify := limiter.allow(req.user.TelegramID)
+		if allowed {
+			return next(ctx, req)
+		}
+
+		h.metrics.UpdateRejected("rate_limited")
+		logging.FromContext(ctx).Debug("Rate limited", "action", req.action)
+		if !notify {
+			return h.answerRejected(req, "")
+		}
+		return h.answerRejected(req, "rate_limited")
+	}
+}
+
+// withLanguage makes sure the user's language is loaded, as translations
+// can be reloaded without it, and adds it to the log fields
+func (h *Handler) withLanguage(next route) route {
+	return func(ctx context.Context, req *request) error {
+		if !h.i18n.IsSupported(req.user.Language) {
+			user := *req.user
+			user.Language = h.i18n.DetectLanguage(user.Language)
+			req.user = &user
+		}
+
+		ctx = logging.With(ctx, "language", req.user.Language)
+		return next(ctx, req)
+	}
+}
+
+// requireAdmin hides command c from everyone but admins, and from admins
+// too while its feature is not set up
+func (h *Handler) requireAdmin(c *botCommand) middleware {
+	return func(next route) route {
+		return func(ctx context.Context, req *request) error {
+			if !h.admins[req.user.TelegramID] || !c.isEnabled(h) {
+				return h.sendMessage(req.chatID(), req.user.Language, "unknown_command")
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// answerRejected answers a request that is not handled: a button press
+// with the message key as a toast, an inline query with no results and the
+// message key on a button to the bot's chat, a message with the message key
+// as a reply. Without a key messages get no reply.
+func (h *Handler) answerRejected(req *request, key string) error {
+	var text string
+	if key != "" {
+		text = h.i18n.T(h.requestLanguage(req), key)
+	}
+
+	if req.query != nil {
+		_, err := h.bot.Request(tgbotapi.NewCallback(req.query.ID, text))
+		return err
+	}
+	if req.inline != nil {
+		answer := emptyInlineAnswer(req.inline)
+		if text != "" {
+			answer.SwitchPMText, answer.SwitchPMParameter = text, "inline"
+		}
+		_, err := h.bot.Request(answer)
+		return err
+	}
+	if text == "" {
+		return nil
+	}
+	_, err := h.send(req.chatID(), text, nil)
+	return err
+}
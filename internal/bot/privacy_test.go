@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
+)
+
+func TestHandler_ExportMyData(t *testing.T) {
+	handler, _, userManager := setupTestHandler(t)
+	bot, telegram := newTestBot(t)
+	handler.bot = bot
+	ctx := context.Background()
+
+	if err := handler.HandleUpdate(ctx, commandUpdate("/kindle reader@kindle.com")); err != nil {
+		t.Fatalf("HandleUpdate(/kindle) error = %v", err)
+	}
+	if err := handler.HandleUpdate(ctx, commandUpdate("/export_my_data")); err != nil {
+		t.Fatalf("HandleUpdate(/export_my_data) error = %v", err)
+	}
+
+	calls := telegram.requests()
+	last := calls[len(calls)-1]
+	if last.method != "sendDocument" {
+		t.Fatalf("last method = %q, want sendDocument", last.method)
+	}
+
+	var data user.UserData
+	if err := json.Unmarshal(last.files["document"], &data); err != nil {
+		t.Fatalf("document is not JSON: %v", err)
+	}
+	if data.Profile.Username != "testuser" || data.Profile.FirstName != "Test" || data.Profile.LastName != "User" {
+		t.Errorf("Profile = %+v", data.Profile)
+	}
+	if data.Preferences.KindleEmail != "reader@kindle.com" {
+		t.Errorf("KindleEmail = %q, want reader@kindle.com", data.Preferences.KindleEmail)
+	}
+
+	if _, err := userManager.GetUser(ctx, 12345); err != nil {
+		t.Errorf("GetUser() error = %v", err)
+	}
+}
+
+func TestHandler_DeleteMe(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantDeleted bool
+		wantText    string
+	}{
+		{name: "confirm", data: "confirm", wantDeleted: true, wantText: "Deleted"},
+		{name: "cancel", data: "cancel", wantDeleted: false, wantText: "Cancelled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _, userManager := setupTestHandler(t)
+			bot, telegram := newTestBot(t)
+			handler.bot = bot
+			ctx := context.Background()
+
+			if err := handler.HandleUpdate(ctx, commandUpdate("/delete_me")); err != nil {
+				t.Fatalf("HandleUpdate(/delete_me) error = %v", err)
+			}
+
+			prompt := telegram.requests()[0]
+			if prompt.method != "sendMessage" || prompt.params["reply_markup"] == "" {
+				t.Fatalf("prompt = %+v, want message with buttons", prompt)
+			}
+
+			if err := handler.HandleUpdate(ctx, callbackUpdate("delete_me", tt.data)); err != nil {
+				t.Fatalf("HandleUpdate(callback) error = %v", err)
+			}
+
+			calls := telegram.requests()
+			edit := calls[len(calls)-1]
+			if edit.method != "editMessageText" || edit.params["text"] != tt.wantText {
+				t.Errorf("edit = %+v, want text %q", edit, tt.wantText)
+			}
+
+			_, err := userManager.GetUser(ctx, 12345)
+			if deleted := errors.Is(err, user.ErrUserNotFound); deleted != tt.wantDeleted {
+				t.Errorf("deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"sync"
+	"time"
+)
+
+// inlineRateFactor is how many more inline queries than other requests a
+// user may send, as Telegram sends one for each pause in typing
+const inlineRateFactor = 5
+
+// rateLimiter allows each user a number of requests per minute. Allowance
+// refills continuously, so bursts up to the whole limit are fine.
+type rateLimiter struct {
+	mu       sync.Mutex
+	perMin   float64
+	buckets  map[int64]*bucket
+	lastTidy time.Time
+	now      func() time.Time
+}
+
+// bucket holds the allowance of one user
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// notified is set once the user has been told about the limit
+	notified bool
+}
+
+// newRateLimiter creates a limiter allowing perMinute requests per user
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		perMin:  float64(perMinute),
+		buckets: make(map[int64]*bucket),
+		now:     time.Now,
+	}
+}
+
+// allow takes one request from the user's allowance. When it is used up,
+// notify is set for the first rejected request only.
+func (l *rateLimiter) allow(userID int64) (allowed, notify bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.tidy(now)
+
+	b, ok := l.buckets[userID]
+	if !ok {
+		b = &bucket{tokens: l.perMin, updated: now}
+		l.buckets[userID] = b
+	}
+	b.tokens = min(l.perMin, b.tokens+now.Sub(b.updated).Minutes()*l.perMin)
+	b.updated = now
+
+	if b.tokens < 1 {
+		notify = !b.notified
+		b.notified = true
+		return false, notify
+	}
+	b.tokens--
+	b.notified = false
+	return true, false
+}
+
+// tidy forgets users idle for a minute, whose allowance is full again
+func (l *rateLimiter) tidy(now time.Time) {
+	if now.Sub(l.lastTidy) < time.Minute {
+		return
+	}
+	l.lastTidy = now
+
+	for id, b := range l.buckets {
+		if now.Sub(b.updated) >= time.Minute {
+			delete(l.buckets, id)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newRateLimiter(60)
+	l.now = func() time.Time { return now }
+
+	// The whole allowance can be used at once
+	for n := 0; n < 60; n++ {
+		if allowed, _ := l.allow(1); !allowed {
+			t.Fatalf("request %d rejected", n+1)
+		}
+	}
+
+	if allowed, notify := l.allow(1); allowed || !notify {
+		t.Errorf("allow() = %v, %v over the limit, want false, true", allowed, notify)
+	}
+	if allowed, notify := l.allow(1); allowed || notify {
+		t.Errorf("allow() = %v, %v again, want false, false", allowed, notify)
+	}
+
+	// Other users have their own allowance
+	if allowed, _ := l.allow(2); !allowed {
+		t.Error("another user was rejected")
+	}
+
+	// One request a second comes back
+	now = now.Add(time.Second)
+	if allowed, _ := l.allow(1); !allowed {
+		t.Error("request after a second rejected")
+	}
+	if allowed, notify := l.allow(1); allowed || !notify {
+		t.Errorf("allow() = %v, %v after refill, want false, true", allowed, notify)
+	}
+
+	// Idle users are forgotten
+	now = now.Add(2 * time.Minute)
+	l.allow(3)
+	if _, ok := l.buckets[1]; ok {
+		t.Error("idle user was kept")
+	}
+}
@@ -0,0 +1,297 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/callback"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/delivery"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/format"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/i18n"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/readinglist"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// maxButtonLabel keeps reading list buttons readable on phones
+const maxButtonLabel = 32
+
+// EnableReadingList lets users save books and send them later. Sends from
+// the list run in the background through the delivery queue.
+func (h *Handler) EnableReadingList(list *readinglist.Manager) {
+	h.readingList = list
+}
+
+// handleList handles /list command by showing the user's reading list.
+func (h *Handler) handleList(ctx context.Context, message *tgbotapi.Message, user *models.User) error {
+	if h.readingList == nil {
+		return h.sendMessage(message.Chat.ID, user.Language, "feature_coming_soon")
+	}
+
+	text, keyboard, err := h.readingListView(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	var markup interface{}
+	if keyboard != nil {
+		markup = *keyboard
+	}
+
+	_, err = h.send(message.Chat.ID, text, markup)
+	return err
+}
+
+// readingListView renders the reading list with a send and a remove button
+// per book. The keyboard is nil for an empty list.
+func (h *Handler) readingListView(ctx context.Context, user *models.User) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	books, err := h.readingList.List(ctx, user.TelegramID)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(books) == 0 {
+		return h.text(user.Language, "list_empty"), nil, nil
+	}
+
+	lines := []string{h.text(user.Language, "list_header", i18n.Params{"count": len(books)})}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := range books {
+		label := savedBookLabel(&books[i])
+		lines = append(lines, fmt.Sprintf("%d. %s", i+1, format.HTML(label)))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			h.button("📤 "+truncate(label, maxButtonLabel), "list", "send", books[i].BookID),
+			h.button("❌", "list", "del", books[i].BookID),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		h.button(h.i18n.T(user.Language, "list_send_all_button"), "list", "all"),
+	))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return strings.Join(lines, "\n"), &keyboard, nil
+}
+
+// handleSaveCallback handles the save button on a search result
+// ("save <id> [<format>]") by adding the book to the reading list.
+func (h *Handler) handleSaveCallback(ctx context.Context, query *tgbotapi.CallbackQuery, data callback.Data, user *models.User) error {
+	key := "feature_coming_soon"
+	if h.readingList != nil {
+		book := h.searchResult(user.TelegramID, data.Arg(0), data.Arg(1))
+
+		err := h.readingList.Add(ctx, user.TelegramID, book)
+		switch {
+		case err == nil:
+			key = "list_added"
+		case errors.Is(err, readinglist.ErrAlreadySaved):
+			key = "list_already_saved"
+		case errors.Is(err, readinglist.ErrListFull):
+			key = "list_full"
+		default:
+			return err
+		}
+	}
+
+	_, err := h.bot.Request(tgbotapi.NewCallback(query.ID, h.i18n.T(user.Language, key)))
+	return err
+}
+
+// searchResult describes a book from the user's search session, falling
+// back to its ID when the session has expired
+func (h *Handler) searchResult(telegramID int64, bookID, format string) *models.SavedBook {
+	book := &models.SavedBook{BookID: bookID, Format: format}
+	if h.contexts == nil {
+		return book
+	}
+
+	if session, ok := h.contexts.Get(telegramID); ok {
+		for _, result := range session.Results {
+			if result.ID == bookID {
+				book.Title, book.Author = result.Title, result.Author
+				break
+			}
+		}
+	}
+	return book
+}
+
+// handleListCallback handles the reading list buttons: "list send <id>",
+// "list del <id>" and "list all".
+func (h *Handler) handleListCallback(ctx context.Context, query *tgbotapi.CallbackQuery, data callback.Data, user *models.User) error {
+	if h.readingList == nil {
+		_, err := h.bot.Request(tgbotapi.NewCallback(query.ID, h.i18n.T(user.Language, "feature_coming_soon")))
+		return err
+	}
+
+	if data.Arg(0) == "del" {
+		if err := h.readingList.Remove(ctx, user.TelegramID, data.Arg(1)); err != nil {
+			return err
+		}
+		if _, err := h.bot.Request(tgbotapi.NewCallback(query.ID, h.i18n.T(user.Language, "list_removed"))); err != nil {
+			return err
+		}
+		return h.refreshReadingList(ctx, query, user)
+	}
+
+	if _, err := h.bot.Request(tgbotapi.NewCallback(query.ID, "")); err != nil {
+		return err
+	}
+
+	chatID := callbackChatID(query)
+	if h.queue == nil {
+		return h.sendMessage(chatID, user.Language, "feature_coming_soon")
+	}
+	if !user.HasKindleEmail() {
+		return h.sendMessage(chatID, user.Language, "kindle_email_required")
+	}
+
+	books, err := h.readingList.List(ctx, user.TelegramID)
+	if err != nil {
+		return err
+	}
+	if data.Arg(0) == "send" {
+		books = filterSavedBooks(books, data.Arg(1))
+	}
+	if len(books) == 0 {
+		return h.sendMessage(chatID, user.Language, "list_empty")
+	}
+
+	// Books still queued from an earlier press are not sent again
+	var pending []models.SavedBook
+	for _, book := range books {
+		if h.sending.start(user.TelegramID, book.BookID) {
+			pending = append(pending, book)
+		}
+	}
+	if len(pending) == 0 {
+		return h.sendMessage(chatID, user.Language, "list_already_queued")
+	}
+
+	queued := 0
+	for i := range pending {
+		if err := h.queue.Enqueue(h.readingListJob(chatID, user, pending[i])); err != nil {
+			for _, book := range pending[i:] {
+				h.sending.finish(user.TelegramID, book.BookID)
+			}
+			if !errors.Is(err, delivery.ErrQueueFull) {
+				return err
+			}
+			logging.FromContext(ctx).Warn("Delivery queue full", "queued", queued, "requested", len(pending))
+			if queued == 0 {
+				return h.sendMessage(chatID, user.Language, "delivery_busy")
+			}
+			break
+		}
+		queued++
+	}
+
+	return h.sendMessage(chatID, user.Language, "list_queued", i18n.Params{"queued": queued, "count": len(pending)})
+}
+
+// readingListJob returns the delivery job for a saved book. Delivered books
+// leave the list; the user is told about each result.
+func (h *Handler) readingListJob(chatID int64, user *models.User, book models.SavedBook) delivery.Job {
+	return delivery.Job{
+		TelegramID: user.TelegramID,
+		BookID:     book.BookID,
+		Format:     book.Format,
+		Target:     delivery.TargetEmail,
+		Done: func(ctx context.Context, err error) {
+			defer h.sending.finish(user.TelegramID, book.BookID)
+
+			key := "list_item_failed"
+			if err == nil {
+				key = "list_item_sent"
+				if err := h.readingList.Remove(ctx, user.TelegramID, book.BookID); err != nil {
+					logging.FromContext(ctx).Warn("Failed to remove delivered book from reading list", "error", err)
+				}
+			}
+			if err := h.sendMessage(chatID, user.Language, key, savedBookLabel(&book)); err != nil {
+				logging.FromContext(ctx).Warn("Failed to report queued delivery", "error", err)
+			}
+		},
+	}
+}
+
+// sendingBooks tracks the reading list books queued for delivery, so a
+// double tap on send does not send a book twice. The zero value is ready to
+// use.
+type sendingBooks struct {
+	mu    sync.Mutex
+	books map[sendingBook]bool
+}
+
+// sendingBook is a book queued for a user
+type sendingBook struct {
+	telegramID int64
+	bookID     string
+}
+
+// start marks a book as queued for the user. It returns false when it
+// already is.
+func (s *sendingBooks) start(telegramID int64, bookID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := sendingBook{telegramID, bookID}
+	if s.books[key] {
+		return false
+	}
+	if s.books == nil {
+		s.books = make(map[sendingBook]bool)
+	}
+	s.books[key] = true
+	return true
+}
+
+// finish marks a book as no longer queued for the user
+func (s *sendingBooks) finish(telegramID int64, bookID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.books, sendingBook{telegramID, bookID})
+}
+
+// refreshReadingList replaces the reading list message with the current list
+func (h *Handler) refreshReadingList(ctx context.Context, query *tgbotapi.CallbackQuery, user *models.User) error {
+	if query.Message == nil {
+		return nil
+	}
+
+	text, keyboard, err := h.readingListView(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	return h.edit(query.Message.Chat.ID, query.Message.MessageID, text, keyboard)
+}
+
+// filterSavedBooks returns the saved books with the given ID
+func filterSavedBooks(books []models.SavedBook, bookID string) []models.SavedBook {
+	var filtered []models.SavedBook
+	for _, book := range books {
+		if book.BookID == bookID {
+			filtered = append(filtered, book)
+		}
+	}
+	return filtered
+}
+
+// savedBookLabel names a saved book in messages
+func savedBookLabel(book *models.SavedBook) string {
+	label := book.Title
+	if label == "" {
+		label = "#" + book.BookID
+	}
+	if book.Author != "" {
+		label += " — " + book.Author
+	}
+	if book.Format != "" {
+		label += " (" + strings.ToUpper(book.Format) + ")"
+	}
+	return label
+}
@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/delivery"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/readinglist"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/search"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// setupReadingListHandler returns a delivery handler with a reading list of
+// up to three books
+func setupReadingListHandler(t *testing.T) (*Handler, *fakeTelegram, *fakeEmailClient) {
+	t.Helper()
+
+	handler, telegram, email := setupDeliveryHandler(t)
+	handler.EnableReadingList(readinglist.NewManager(readinglist.NewMemoryRepository(), 3))
+
+	return handler, telegram, email
+}
+
+// waitForText waits until a message with text is sent
+func waitForText(t *testing.T, telegram *fakeTelegram, text string) {
+	t.Helper()
+
+	waitForRequest(t, telegram, 0, isText(text))
+}
+
+func TestHandler_SaveCallback(t *testing.T) {
+	handler, telegram, _ := setupReadingListHandler(t)
+	handler.EnableSearch(&fakeSearcher{count: 5}, search.NewContextStore(time.Minute))
+	ctx := context.Background()
+
+	// Titles come from the user's search session
+	if err := handler.HandleUpdate(ctx, inlineUpdate("tolkien", "")); err != nil {
+		t.Fatalf("HandleUpdate(inline) error = %v", err)
+	}
+
+	tests := []struct {
+		args  []string
+		toast string
+	}{
+		{args: []string{"1", "epub"}, toast: "Saved"},
+		{args: []string{"1", "epub"}, toast: "Already saved"},
+		{args: []string{"99"}, toast: "Saved"},
+		{args: []string{"2"}, toast: "Saved"},
+		{args: []string{"3"}, toast: "List full"},
+	}
+	for _, tt := range tests {
+		if err := handler.HandleUpdate(ctx, callbackUpdate("save", tt.args...)); err != nil {
+			t.Fatalf("HandleUpdate(save %v) error = %v", tt.args, err)
+		}
+		if text := lastText(telegram); text != tt.toast {
+			t.Errorf("save %v toast = %q, want %q", tt.args, text, tt.toast)
+		}
+	}
+
+	if err := handler.HandleUpdate(ctx, commandUpdate("/list")); err != nil {
+		t.Fatalf("HandleUpdate(/list) error = %v", err)
+	}
+	want := "List (3):\n1. Book 1 — Tolkien (EPUB)\n2. #99\n3. Book 2 — Tolkien"
+	if text := lastText(telegram); text != want {
+		t.Errorf("/list = %q, want %q", text, want)
+	}
+	calls := telegram.requests()
+	if markup := calls[len(calls)-1].params["reply_markup"]; !strings.Contains(markup, callbackData("list", "del", "99")) || !strings.Contains(markup, callbackData("list", "all")) {
+		t.Errorf("keyboard = %s, want remove and send all buttons", markup)
+	}
+
+	if err := handler.HandleUpdate(ctx, callbackUpdate("list", "del", "99")); err != nil {
+		t.Fatalf("HandleUpdate(list_del_99) error = %v", err)
+	}
+	if text := lastText(telegram); !strings.HasPrefix(text, "List (2):") {
+		t.Errorf("list after removal = %q, want 2 books", text)
+	}
+}
+
+func TestHandler_ListSendAll(t *testing.T) {
+	handler, telegram, email := setupReadingListHandler(t)
+	ctx := context.Background()
+
+	if err := handler.HandleUpdate(ctx, commandUpdate("/list")); err != nil {
+		t.Fatalf("HandleUpdate(/list) error = %v", err)
+	}
+	if text := lastText(telegram); text != "List empty" {
+		t.Errorf("/list = %q, want List empty", text)
+	}
+
+	handler.readingList.Add(ctx, 12345, &models.SavedBook{BookID: "42", Title: "Dune"})
+	handler.readingList.Add(ctx, 12345, &models.SavedBook{BookID: "7"})
+
+	if err := handler.HandleUpdate(ctx, callbackUpdate("list", "all")); err != nil {
+		t.Fatalf("HandleUpdate(list_all) error = %v", err)
+	}
+	if text := lastText(telegram); text != "Email required" {
+		t.Errorf("reply without Kindle email = %q, want Email required", text)
+	}
+
+	if err := handler.HandleUpdate(ctx, commandUpdate("/kindle reader@kindle.com")); err != nil {
+		t.Fatalf("HandleUpdate(/kindle) error = %v", err)
+	}
+	if err := handler.HandleUpdate(ctx, callbackUpdate("list", "all")); err != nil {
+		t.Fatalf("HandleUpdate(list_all) error = %v", err)
+	}
+	waitForText(t, telegram, "Queued 2 of 2 books")
+	waitForText(t, telegram, "Sent Dune")
+	waitForText(t, telegram, "Failed #7")
+
+	if len(email.sent) != 1 {
+		t.Errorf("sent %d emails, want 1", len(email.sent))
+	}
+
+	// Only the failed book stays on the list
+	books, _ := handler.readingList.List(ctx, 12345)
+	if len(books) != 1 || books[0].BookID != "7" {
+		t.Errorf("list = %+v, want only book 7", books)
+	}
+}
+
+func TestHandler_ListSend_DoubleTap(t *testing.T) {
+	handler, telegram, email := setupDeliveryHandler(t)
+	handler.EnableReadingList(readinglist.NewManager(readinglist.NewMemoryRepository(), 3))
+	handler.queue = delivery.NewQueue(handler.delivery, 10) // Never started, so jobs stay queued
+	ctx := context.Background()
+
+	if err := handler.HandleUpdate(ctx, commandUpdate("/kindle reader@kindle.com")); err != nil {
+		t.Fatalf("HandleUpdate(/kindle) error = %v", err)
+	}
+	handler.readingList.Add(ctx, 12345, &models.SavedBook{BookID: "42", Title: "Dune"})
+
+	// The second press comes while the book is still queued
+	if err := handler.HandleUpdate(ctx, callbackUpdate("list", "send", "42")); err != nil {
+		t.Fatalf("HandleUpdate(list send) error = %v", err)
+	}
+	if text := lastText(telegram); text != "Queued 1 of 1 book" {
+		t.Errorf("first press = %q, want the book queued", text)
+	}
+	if err := handler.HandleUpdate(ctx, callbackUpdate("list", "all")); err != nil {
+		t.Fatalf("HandleUpdate(list all) error = %v", err)
+	}
+	if text := lastText(telegram); text != "Already queued" {
+		t.Errorf("second press = %q, want Already queued", text)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		handler.queue.Start(runCtx, 1)
+		close(stopped)
+	}()
+	waitForText(t, telegram, "Sent Dune")
+	cancel()
+	<-stopped
+
+	if len(email.sent) != 1 {
+		t.Errorf("sent %d emails, want 1", len(email.sent))
+	}
+	if !handler.sending.start(12345, "42") {
+		t.Error("book still marked as queued after delivery")
+	}
+}
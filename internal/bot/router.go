@@ -0,0 +1,211 @@
+package bot
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/callback"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// route handles a message, button press or inline query
+type route func(ctx context.Context, req *request) error
+
+// middleware wraps a route with behaviour shared by many routes
+type middleware func(next route) route
+
+// request is a message, button press or inline query on its way through
+// the router
+type request struct {
+	message *tgbotapi.Message       // Set for messages
+	query   *tgbotapi.CallbackQuery // Set for button presses
+	inline  *tgbotapi.InlineQuery   // Set for inline queries
+	data    callback.Data           // Set for button presses with valid data
+	user    *models.User            // Set by the loadUser middleware
+	action  string                  // Command name or callback action, for metrics and traces
+}
+
+// from returns the Telegram user who sent the request
+func (r *request) from() *tgbotapi.User {
+	switch {
+	case r.query != nil:
+		return r.query.From
+	case r.inline != nil:
+		return r.inline.From
+	}
+	return r.message.From
+}
+
+// chatID returns the chat to reply in. Inline queries come from other
+// chats, so replies go to the private chat with the user.
+func (r *request) chatID() int64 {
+	switch {
+	case r.query != nil:
+		return callbackChatID(r.query)
+	case r.inline != nil:
+		return r.inline.From.ID
+	}
+	return r.message.Chat.ID
+}
+
+// router dispatches commands by name, button presses by the action in their
+// data, inline queries to the inline route and other messages to the text
+// route, all through the router's middleware
+type router struct {
+	middleware []middleware
+	codec      *callback.Codec
+	commands   map[string]route
+	callbacks  map[string]route
+
+	text            route
+	inline          route
+	unknownCommand  route
+	unknownCallback route
+}
+
+// newRouter creates a router decoding button data with codec and running
+// middleware, outermost first, around every route
+func newRouter(codec *callback.Codec, middleware ...middleware) *router {
+	return &router{
+		middleware: middleware,
+		codec:      codec,
+		commands:   make(map[string]route),
+		callbacks:  make(map[string]route),
+	}
+}
+
+// command routes /name to handle, run inside the given middleware
+func (r *router) command(name string, handle route, middleware ...middleware) {
+	r.commands[name] = chain(handle, middleware)
+}
+
+// callback routes button presses for action to handle, run inside the given
+// middleware
+func (r *router) callback(action string, handle route, middleware ...middleware) {
+	r.callbacks[action] = chain(handle, middleware)
+}
+
+// dispatch runs the route matching req
+func (r *router) dispatch(ctx context.Context, req *request) error {
+	return chain(r.match(req), r.middleware)(ctx, req)
+}
+
+// match finds the route for req and names its action
+func (r *router) match(req *request) route {
+	if req.query != nil {
+		data, err := r.codec.Decode(req.query.Data)
+		if err != nil {
+			// Spoofed, expired or sent by an older version of the bot
+			req.action = "invalid"
+			return r.unknownCallback
+		}
+		handle, ok := r.callbacks[data.Action]
+		if !ok {
+			req.action = "unknown"
+			return r.unknownCallback
+		}
+		req.data, req.action = data, data.Action
+		return handle
+	}
+
+	if req.inline != nil {
+		req.action = "inline"
+		return r.inline
+	}
+
+	if !req.message.IsCommand() {
+		req.action = "text"
+		return r.text
+	}
+	if handle, ok := r.commands[req.message.Command()]; ok {
+		req.action = req.message.Command()
+		return handle
+	}
+	req.action = "unknown"
+	return r.unknownCommand
+}
+
+// chain wraps handle in middleware, the first outermost
+func chain(handle route, middleware []middleware) route {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handle = middleware[i](handle)
+	}
+	return handle
+}
+
+// codec returns the handler's callback data codec. Without signing enabled
+// button data is not signed.
+func (h *Handler) codec() *callback.Codec {
+	h.codecOnce.Do(func() {
+		if h.callbacks == nil {
+			h.callbacks = callback.NewCodec(nil)
+		}
+	})
+	return h.callbacks
+}
+
+// button creates an inline keyboard button pressing action with args
+func (h *Handler) button(label, action string, args ...string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(label, h.codec().Encode(callback.New(action, args...)))
+}
+
+// routes returns the handler's router, built on first use
+func (h *Handler) routes() *router {
+	h.routerOnce.Do(func() {
+		h.router = h.newRouter()
+	})
+	return h.router
+}
+
+// newRouter registers every command and button with the middleware they
+// share: errors and panics are reported, requests counted, the user loaded (and
+// banned users ignored), rate limited and their language resolved.
+func (h *Handler) newRouter() *router {
+	r := newRouter(h.codec(), h.reportErrors, h.observe, h.loadUser, h.rateLimit, h.withLanguage)
+
+	for i := range commands {
+		c := &commands[i]
+		var middleware []middleware
+		if c.admin {
+			middleware = append(middleware, h.requireAdmin(c))
+		}
+		r.command(c.name, func(ctx context.Context, req *request) error {
+			return c.handle(h, ctx, req.message, req.user)
+		}, middleware...)
+	}
+
+	for action, handle := range map[string]func(*Handler, context.Context, *tgbotapi.CallbackQuery, callback.Data, *models.User) error{
+		"lang":        (*Handler).handleLanguageCallback,
+		"delete_me":   (*Handler).handleDeleteMeCallback,
+		"verify":      (*Handler).handleVerifyCallback,
+		"save":        (*Handler).handleSaveCallback,
+		"list":        (*Handler).handleListCallback,
+		"follow":      (*Handler).handleFollowCallback,
+		"follow_auto": (*Handler).handleFollowCallback,
+		"unfollow":    (*Handler).handleFollowCallback,
+		"book":        (*Handler).handleBookCallback,
+		"doc":         (*Handler).handleBookCallback,
+	} {
+		handle := handle
+		r.callback(action, func(ctx context.Context, req *request) error {
+			return handle(h, ctx, req.query, req.data, req.user)
+		})
+	}
+
+	r.text = func(ctx context.Context, req *request) error {
+		return h.handleSearchQuery(ctx, req.message, req.user)
+	}
+	r.inline = func(ctx context.Context, req *request) error {
+		return h.handleInlineQuery(ctx, req.inline, req.user)
+	}
+	r.unknownCommand = func(ctx context.Context, req *request) error {
+		return h.sendMessage(req.chatID(), req.user.Language, "unknown_command")
+	}
+	r.unknownCallback = func(ctx context.Context, req *request) error {
+		_, err := h.bot.Request(tgbotapi.NewCallback(req.query.ID, h.i18n.T(req.user.Language, "button_expired")))
+		return err
+	}
+
+	return r
+}
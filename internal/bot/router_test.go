@@ -0,0 +1,303 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/callback"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+func TestRouter_Match(t *testing.T) {
+	var called string
+	record := func(name string) route {
+		return func(ctx context.Context, req *request) error {
+			called = name
+			return nil
+		}
+	}
+
+	r := newRouter(callback.NewCodec(nil))
+	r.command("start", record("start"))
+	r.callback("list", record("list"))
+	r.callback("book", record("book"))
+	r.text = record("text")
+	r.unknownCommand = record("unknown command")
+	r.unknownCallback = record("unknown callback")
+
+	tests := []struct {
+		name       string
+		req        *request
+		wantRoute  string
+		wantAction string
+	}{
+		{name: "command", req: &request{message: commandUpdate("/start").Message}, wantRoute: "start", wantAction: "start"},
+		{name: "unknown command", req: &request{message: commandUpdate("/nope").Message}, wantRoute: "unknown command", wantAction: "unknown"},
+		{name: "text", req: &request{message: &tgbotapi.Message{Text: "tolkien"}}, wantRoute: "text", wantAction: "text"},
+		{name: "callback", req: &request{query: callbackUpdate("book", "42").CallbackQuery}, wantRoute: "book", wantAction: "book"},
+		{name: "callback args", req: &request{query: callbackUpdate("list", "del", "42").CallbackQuery}, wantRoute: "list", wantAction: "list"},
+		{name: "unknown callback", req: &request{query: callbackUpdate("nope").CallbackQuery}, wantRoute: "unknown callback", wantAction: "unknown"},
+		{name: "legacy callback", req: &request{query: &tgbotapi.CallbackQuery{Data: "book_42"}}, wantRoute: "unknown callback", wantAction: "invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = ""
+			if err := r.dispatch(context.Background(), tt.req); err != nil {
+				t.Fatalf("dispatch() error = %v", err)
+			}
+			if called != tt.wantRoute {
+				t.Errorf("route = %q, want %q", called, tt.wantRoute)
+			}
+			if tt.req.action != tt.wantAction {
+				t.Errorf("action = %q, want %q", tt.req.action, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestRouter_MiddlewareOrder(t *testing.T) {
+	var order []string
+	step := func(name string) middleware {
+		return func(next route) route {
+			return func(ctx context.Context, req *request) error {
+				order = append(order, name)
+				return next(ctx, req)
+			}
+		}
+	}
+
+	r := newRouter(nil, step("outer"), step("inner"))
+	r.command("start", func(ctx context.Context, req *request) error {
+		order = append(order, "handler")
+		return nil
+	}, step("route"))
+
+	if err := r.dispatch(context.Background(), &request{message: commandUpdate("/start").Message}); err != nil {
+		t.Fatalf("dispatch() error = %v", err)
+	}
+	if want := []string{"outer", "inner", "route", "handler"}; !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestHandler_ReportErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		handle    route
+		wantReply bool
+		wantText  string
+	}{
+		{
+			name:      "error",
+			handle:    func(ctx context.Context, req *request) error { return errors.New("database down") },
+			wantReply: true,
+			wantText:  "Error occurred\n\nID ",
+		},
+		{
+			name:      "panic",
+			handle:    func(ctx context.Context, req *request) error { panic("boom") },
+			wantReply: true,
+			wantText:  "Error occurred\n\nID ",
+		},
+		{
+			name: "timeout",
+			handle: func(ctx context.Context, req *request) error {
+				return fmt.Errorf("failed to search: %w", context.DeadlineExceeded)
+			},
+			wantReply: true,
+			wantText:  "Timed out\n\nID ",
+		},
+		{
+			name:      "telegram error",
+			handle:    func(ctx context.Context, req *request) error { return &tgbotapi.Error{Code: 403, Message: "Forbidden"} },
+			wantReply: false,
+		},
+		{
+			name:      "shutdown",
+			handle:    func(ctx context.Context, req *request) error { return context.Canceled },
+			wantReply: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _, _ := setupTestHandler(t)
+			bot, telegram := newTestBot(t)
+			handler.bot = bot
+
+			r := newRouter(nil, handler.reportErrors, handler.loadUser)
+			r.command("start", tt.handle)
+
+			if err := r.dispatch(context.Background(), &request{message: commandUpdate("/start").Message}); err != nil {
+				t.Errorf("dispatch() error = %v, want it reported", err)
+			}
+
+			calls := telegram.requests()
+			if !tt.wantReply {
+				if len(calls) != 0 {
+					t.Errorf("requests = %+v, want no reply", calls)
+				}
+				return
+			}
+			if len(calls) != 1 || !strings.HasPrefix(calls[0].params["text"], tt.wantText) {
+				t.Fatalf("requests = %+v, want %q and an incident ID", calls, tt.wantText)
+			}
+			if id := strings.TrimPrefix(calls[0].params["text"], tt.wantText); len(id) != 8 {
+				t.Errorf("incident ID = %q", id)
+			}
+		})
+	}
+}
+
+func TestHandler_InlineQueryPanic(t *testing.T) {
+	handler, _, _ := setupTestHandler(t)
+	bot, telegram := newTestBot(t)
+	handler.bot = bot
+	handler.EnableSearch(panickingSearcher{}, nil)
+
+	// Inline queries go through the router, so the panic is reported
+	if err := handler.HandleUpdate(context.Background(), inlineUpdate("tolstoy", "")); err != nil {
+		t.Fatalf("HandleUpdate(inline) error = %v, want it reported", err)
+	}
+	calls := telegram.requests()
+	if len(calls) != 2 || calls[0].method != "answerInlineQuery" || !strings.HasPrefix(calls[1].params["text"], "Error occurred\n\nID ") {
+		t.Errorf("requests = %+v, want an empty answer and an incident ID", calls)
+	}
+}
+
+func TestHandler_BannedUser(t *testing.T) {
+	handler, _, _ := setupTestHandler(t)
+	bot, telegram := newTestBot(t)
+	handler.bot = bot
+
+	repo := user.NewMemoryRepository()
+	handler.userManager = user.NewManager(repo)
+	if err := repo.SaveUser(context.Background(), &models.User{ID: 12345, TelegramID: 12345, Language: "en", IsBanned: true}); err != nil {
+		t.Fatalf("SaveUser() error = %v", err)
+	}
+
+	if err := handler.HandleUpdate(context.Background(), commandUpdate("/help")); err != nil {
+		t.Fatalf("HandleUpdate(/help) error = %v", err)
+	}
+	if calls := telegram.requests(); len(calls) != 0 {
+		t.Errorf("banned user got %d replies", len(calls))
+	}
+
+	// Button presses are answered so the client stops waiting
+	if err := handler.HandleUpdate(context.Background(), callbackUpdate("list", "all")); err != nil {
+		t.Fatalf("HandleUpdate(list_all) error = %v", err)
+	}
+	calls := telegram.requests()
+	if len(calls) != 1 || calls[0].method != "answerCallbackQuery" || calls[0].params["text"] != "" {
+		t.Errorf("requests = %+v, want one silent answer", calls)
+	}
+
+	// So are inline queries, without searching
+	handler.EnableSearch(panickingSearcher{}, nil)
+	if err := handler.HandleUpdate(context.Background(), inlineUpdate("tolkien", "")); err != nil {
+		t.Fatalf("HandleUpdate(inline) error = %v", err)
+	}
+	if _, results := lastInlineAnswer(t, telegram); len(results) != 0 {
+		t.Errorf("banned user got %d results", len(results))
+	}
+}
+
+func TestHandler_RateLimit(t *testing.T) {
+	handler, _, _ := setupTestHandler(t)
+	bot, telegram := newTestBot(t)
+	handler.bot = bot
+	handler.EnableRateLimit(2)
+
+	for n := 0; n < 4; n++ {
+		if err := handler.HandleUpdate(context.Background(), commandUpdate("/help")); err != nil {
+			t.Fatalf("HandleUpdate(/help) error = %v", err)
+		}
+	}
+
+	var texts []string
+	for _, call := range telegram.requests() {
+		texts = append(texts, call.params["text"])
+	}
+	// The user is told about the limit once
+	if want := []string{"Help text", "Help text", "Slow down"}; !slices.Equal(texts, want) {
+		t.Errorf("replies = %q, want %q", texts, want)
+	}
+
+	// Inline queries have their own, larger allowance
+	handler.EnableSearch(&fakeSearcher{count: 1}, nil)
+	for n := 0; n < 2*inlineRateFactor; n++ {
+		if err := handler.HandleUpdate(context.Background(), inlineUpdate("tolkien", "")); err != nil {
+			t.Fatalf("HandleUpdate(inline) error = %v", err)
+		}
+		if _, results := lastInlineAnswer(t, telegram); len(results) != 1 {
+			t.Fatalf("inline query %d got %d results, want 1", n+1, len(results))
+		}
+	}
+	if err := handler.HandleUpdate(context.Background(), inlineUpdate("tolkien", "")); err != nil {
+		t.Fatalf("HandleUpdate(inline) error = %v", err)
+	}
+	if _, results := lastInlineAnswer(t, telegram); len(results) != 0 {
+		t.Errorf("rate limited user got %d results", len(results))
+	}
+
+	// Searching does not use up the allowance for commands
+	handler.limiter = newRateLimiter(2)
+	for n := 0; n < 3*inlineRateFactor; n++ {
+		if err := handler.HandleUpdate(context.Background(), inlineUpdate("tolkien", "")); err != nil {
+			t.Fatalf("HandleUpdate(inline) error = %v", err)
+		}
+	}
+	if err := handler.HandleUpdate(context.Background(), commandUpdate("/help")); err != nil {
+		t.Fatalf("HandleUpdate(/help) error = %v", err)
+	}
+	if text := lastText(telegram); text != "Help text" {
+		t.Errorf("/help after inline queries = %q, want Help text", text)
+	}
+}
+
+func TestHandler_CallbackSigning(t *testing.T) {
+	handler, _, _ := setupTestHandler(t)
+	bot, telegram := newTestBot(t)
+	handler.bot = bot
+	handler.EnableCallbackSigning("secret")
+	ctx := context.Background()
+
+	if err := handler.HandleUpdate(ctx, commandUpdate("/delete_me")); err != nil {
+		t.Fatalf("HandleUpdate(/delete_me) error = %v", err)
+	}
+	calls := telegram.requests()
+	var keyboard tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(calls[len(calls)-1].params["reply_markup"]), &keyboard); err != nil {
+		t.Fatalf("reply_markup: %v", err)
+	}
+	confirm := keyboard.InlineKeyboard[0][0].CallbackData
+
+	// Unsigned data, as a crafted client would send, is rejected
+	if err := handler.HandleUpdate(ctx, callbackUpdate("delete_me", "confirm")); err != nil {
+		t.Fatalf("HandleUpdate(unsigned) error = %v", err)
+	}
+	if text := lastText(telegram); text != "Button expired" {
+		t.Errorf("unsigned toast = %q, want Button expired", text)
+	}
+	if _, err := handler.userManager.GetUser(ctx, 12345); err != nil {
+		t.Fatalf("GetUser() after unsigned press error = %v", err)
+	}
+
+	update := callbackUpdate("delete_me", "confirm")
+	update.CallbackQuery.Data = *confirm
+	if err := handler.HandleUpdate(ctx, update); err != nil {
+		t.Fatalf("HandleUpdate(signed) error = %v", err)
+	}
+	if _, err := handler.userManager.GetUser(ctx, 12345); err == nil {
+		t.Error("GetUser() after signed press found the user, want deleted")
+	}
+}
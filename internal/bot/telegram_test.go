@@ -0,0 +1,131 @@
+package bot
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/callback"
+)
+
+// telegramCall is a Bot API request received by fakeTelegram
+type telegramCall struct {
+	method string
+	params map[string]string
+	files  map[string][]byte
+}
+
+// fakeTelegram is a Bot API server recording the requests it receives
+type fakeTelegram struct {
+	mu    sync.Mutex
+	calls []telegramCall
+}
+
+// newTestBot returns a BotAPI talking to a fake Telegram server
+func newTestBot(t *testing.T) (*tgbotapi.BotAPI, *fakeTelegram) {
+	t.Helper()
+
+	fake := &fakeTelegram{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	bot, err := tgbotapi.NewBotAPIWithClient("test-token", server.URL+"/bot%s/%s", server.Client())
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+
+	return bot, fake
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	call := telegramCall{
+		method: r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:],
+		params: make(map[string]string),
+		files:  make(map[string][]byte),
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for name, headers := range r.MultipartForm.File {
+			file, err := headers[0].Open()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			call.files[name], _ = io.ReadAll(file)
+			file.Close()
+		}
+	} else if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for name := range r.Form {
+		call.params[name] = r.Form.Get(name)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch call.method {
+	case "getMe":
+		_, _ = io.WriteString(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Test","username":"test_bot"}}`)
+		return
+	case "answerCallbackQuery":
+		_, _ = io.WriteString(w, `{"ok":true,"result":true}`)
+	default:
+		_, _ = io.WriteString(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":1,"type":"private"}}}`)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+}
+
+// requests returns the recorded requests, excluding getMe
+func (f *fakeTelegram) requests() []telegramCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]telegramCall(nil), f.calls...)
+}
+
+// commandUpdate returns a message update from user 12345 with the given command text
+func commandUpdate(text string) *tgbotapi.Update {
+	command, _, _ := strings.Cut(text, " ")
+
+	return &tgbotapi.Update{
+		UpdateID: 1,
+		Message: &tgbotapi.Message{
+			MessageID: 10,
+			From:      &tgbotapi.User{ID: 12345, UserName: "testuser", FirstName: "Test", LastName: "User", LanguageCode: "en"},
+			Chat:      &tgbotapi.Chat{ID: 12345},
+			Text:      text,
+			Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+		},
+	}
+}
+
+// callbackData returns the data of a button pressing action with args, as
+// sent by a handler without callback signing
+func callbackData(action string, args ...string) string {
+	return callback.NewCodec(nil).Encode(callback.New(action, args...))
+}
+
+// callbackUpdate returns a click from user 12345 on a button pressing action
+// with args
+func callbackUpdate(action string, args ...string) *tgbotapi.Update {
+	return &tgbotapi.Update{
+		UpdateID: 2,
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "cb",
+			From:    &tgbotapi.User{ID: 12345, UserName: "testuser", FirstName: "Test", LastName: "User", LanguageCode: "en"},
+			Message: &tgbotapi.Message{MessageID: 11, Chat: &tgbotapi.Chat{ID: 12345}},
+			Data:    callbackData(action, args...),
+		},
+	}
+}
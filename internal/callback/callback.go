@@ -0,0 +1,252 @@
+// Package callback encodes the data attached to inline keyboard buttons.
+//
+// Data is an action with string arguments, packed into a versioned binary
+// form and base64 encoded. With a key the packed data is signed, so crafted
+// clients cannot press buttons the bot never sent. Data too long for the
+// 64 bytes Telegram allows is kept on the server for StateTTL and the button
+// carries a reference to it.
+package callback
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MaxLength is the longest callback data Telegram accepts, in bytes
+const MaxLength = 64
+
+// StateTTL is how long data kept on the server stays valid
+const StateTTL = 7 * 24 * time.Hour
+
+// version is written first in packed data, so the format can change while
+// older buttons are still around
+const version = 1
+
+// Flags in the byte after the version
+const (
+	flagStored = 1 << iota // The payload references data kept on the server
+	flagSigned             // A signature follows the payload
+)
+
+const (
+	headerLength    = 2
+	signatureLength = 8
+	stateIDLength   = 8
+)
+
+var encoding = base64.RawURLEncoding
+
+var (
+	// ErrInvalid is returned for data the codec did not produce
+	ErrInvalid = errors.New("invalid callback data")
+	// ErrSignature is returned for data with a missing or wrong signature
+	ErrSignature = errors.New("invalid callback data signature")
+	// ErrExpired is returned for references to data no longer on the server
+	ErrExpired = errors.New("callback data expired")
+)
+
+// Data is what a button asks the bot to do
+type Data struct {
+	Action string
+	Args   []string
+}
+
+// New creates data for action with the given arguments
+func New(action string, args ...string) Data {
+	return Data{Action: action, Args: args}
+}
+
+// Arg returns the i-th argument, or "" when there are fewer arguments
+func (d Data) Arg(i int) string {
+	if i < len(d.Args) {
+		return d.Args[i]
+	}
+	return ""
+}
+
+// Codec encodes and decodes callback data. It is safe for concurrent use.
+type Codec struct {
+	key    []byte
+	states *stateStore
+}
+
+// NewCodec creates a codec signing data with key. Without a key data is not
+// signed, and signed data is rejected as it cannot be checked.
+func NewCodec(key []byte) *Codec {
+	return &Codec{
+		key:    key,
+		states: newStateStore(StateTTL),
+	}
+}
+
+// Encode packs d into callback data of at most MaxLength bytes
+func (c *Codec) Encode(d Data) string {
+	payload := pack(d)
+	flags := byte(0)
+	if c.maxPayload() < len(payload) {
+		payload, flags = c.states.put(d), flagStored
+	}
+	if c.key != nil {
+		flags |= flagSigned
+	}
+
+	b := append([]byte{version, flags}, payload...)
+	if c.key != nil {
+		b = append(b, c.sign(b)...)
+	}
+	return encoding.EncodeToString(b)
+}
+
+// Decode unpacks callback data produced by Encode
+func (c *Codec) Decode(s string) (Data, error) {
+	b, err := encoding.DecodeString(s)
+	if err != nil || len(b) < headerLength || b[0] != version {
+		return Data{}, ErrInvalid
+	}
+
+	flags := b[1]
+	if (flags&flagSigned != 0) != (c.key != nil) {
+		return Data{}, ErrSignature
+	}
+	if c.key != nil {
+		if len(b) < headerLength+signatureLength {
+			return Data{}, ErrSignature
+		}
+		var signature []byte
+		b, signature = b[:len(b)-signatureLength], b[len(b)-signatureLength:]
+		if !hmac.Equal(signature, c.sign(b)) {
+			return Data{}, ErrSignature
+		}
+	}
+	return c.decodePayload(flags, b[headerLength:])
+}
+
+// decodePayload unpacks data, fetching it from the server when stored
+func (c *Codec) decodePayload(flags byte, payload []byte) (Data, error) {
+	if flags&flagStored == 0 {
+		return unpack(payload)
+	}
+	if len(payload) != stateIDLength {
+		return Data{}, ErrInvalid
+	}
+	d, ok := c.states.get(string(payload))
+	if !ok {
+		return Data{}, ErrExpired
+	}
+	return d, nil
+}
+
+// maxPayload returns the longest payload that fits in MaxLength once
+// encoded
+func (c *Codec) maxPayload() int {
+	n := encoding.DecodedLen(MaxLength) - headerLength
+	if c.key != nil {
+		n -= signatureLength
+	}
+	return n
+}
+
+// sign returns the truncated HMAC of b
+func (c *Codec) sign(b []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(b)
+	return mac.Sum(nil)[:signatureLength]
+}
+
+// pack writes the action and arguments, each prefixed with its length
+func pack(d Data) []byte {
+	var b []byte
+	for _, field := range append([]string{d.Action}, d.Args...) {
+		b = binary.AppendUvarint(b, uint64(len(field)))
+		b = append(b, field...)
+	}
+	return b
+}
+
+// unpack reads data written by pack
+func unpack(b []byte) (Data, error) {
+	var fields []string
+	for len(b) > 0 {
+		n, size := binary.Uvarint(b)
+		if size <= 0 || n > uint64(len(b)-size) {
+			return Data{}, ErrInvalid
+		}
+		b = b[size:]
+		fields = append(fields, string(b[:n]))
+		b = b[n:]
+	}
+	if len(fields) == 0 || fields[0] == "" {
+		return Data{}, ErrInvalid
+	}
+	return New(fields[0], fields[1:]...), nil
+}
+
+// stateStore keeps data too long for a button in memory until it expires
+type stateStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	states   map[string]state
+	lastTidy time.Time
+	now      func() time.Time
+}
+
+// state is data kept on the server
+type state struct {
+	data    Data
+	expires time.Time
+}
+
+// newStateStore creates a store keeping data for ttl
+func newStateStore(ttl time.Duration) *stateStore {
+	return &stateStore{
+		ttl:    ttl,
+		states: make(map[string]state),
+		now:    time.Now,
+	}
+}
+
+// put stores d and returns a random ID to find it by
+func (s *stateStore) put(d Data) []byte {
+	id := make([]byte, stateIDLength)
+	_, _ = rand.Read(id)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.tidy(now)
+	s.states[string(id)] = state{data: d, expires: now.Add(s.ttl)}
+	return id
+}
+
+// get returns the data stored under id, unless it has expired
+func (s *stateStore) get(id string) (Data, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.states[id]
+	if !ok || !s.now().Before(st.expires) {
+		return Data{}, false
+	}
+	return st.data, true
+}
+
+// tidy removes expired data, at most once a minute
+func (s *stateStore) tidy(now time.Time) {
+	if now.Sub(s.lastTidy) < time.Minute {
+		return
+	}
+	s.lastTidy = now
+
+	for id, st := range s.states {
+		if !now.Before(st.expires) {
+			delete(s.states, id)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package callback
+
+import (
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodec_RoundTrip(t *testing.T) {
+	long := strings.Repeat("x", 100)
+
+	tests := []struct {
+		name       string
+		key        []byte
+		data       Data
+		wantStored bool
+	}{
+		{name: "no args", data: New("list", "all")},
+		{name: "args", data: New("book", "42", "epub")},
+		{name: "empty arg", data: New("save", "42", "")},
+		{name: "unicode", data: New("follow", "author", "Лев Толстой")},
+		{name: "signed", key: []byte("secret"), data: New("book", "42", "epub")},
+		{name: "stored", data: New("follow", "series", long), wantStored: true},
+		{name: "signed stored", key: []byte("secret"), data: New("follow", "series", long), wantStored: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCodec(tt.key)
+
+			s := c.Encode(tt.data)
+			if len(s) > MaxLength {
+				t.Errorf("Encode() = %q, %d bytes long", s, len(s))
+			}
+			if b, _ := base64.RawURLEncoding.DecodeString(s); (b[1]&flagStored != 0) != tt.wantStored {
+				t.Errorf("Encode() stored = %v, want %v", !tt.wantStored, tt.wantStored)
+			}
+
+			got, err := c.Decode(s)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if got.Action != tt.data.Action || !slices.Equal(got.Args, tt.data.Args) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.data)
+			}
+		})
+	}
+}
+
+func TestCodec_Decode_Invalid(t *testing.T) {
+	signed := NewCodec([]byte("secret"))
+	unsigned := NewCodec(nil)
+
+	valid := signed.Encode(New("book", "42"))
+	b, _ := base64.RawURLEncoding.DecodeString(valid)
+	b[len(b)-1] ^= 1
+	tampered := base64.RawURLEncoding.EncodeToString(b)
+
+	tests := []struct {
+		name  string
+		codec *Codec
+		data  string
+		want  error
+	}{
+		{name: "legacy", codec: unsigned, data: "book_42", want: ErrInvalid},
+		{name: "empty", codec: unsigned, data: "", want: ErrInvalid},
+		{name: "other version", codec: unsigned, data: base64.RawURLEncoding.EncodeToString([]byte{2, 0, 4, 'b', 'o', 'o', 'k'}), want: ErrInvalid},
+		{name: "truncated", codec: unsigned, data: base64.RawURLEncoding.EncodeToString([]byte{version, 0, 9, 'b'}), want: ErrInvalid},
+		{name: "tampered", codec: signed, data: tampered, want: ErrSignature},
+		{name: "other key", codec: NewCodec([]byte("other")), data: valid, want: ErrSignature},
+		{name: "unsigned", codec: signed, data: unsigned.Encode(New("book", "42")), want: ErrSignature},
+		{name: "signed without key", codec: unsigned, data: valid, want: ErrSignature},
+		{name: "unknown state", codec: unsigned, data: NewCodec(nil).Encode(New("follow", strings.Repeat("x", 100))), want: ErrExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.codec.Decode(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("Decode(%q) error = %v, want %v", tt.data, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodec_StateExpires(t *testing.T) {
+	c := NewCodec(nil)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.states.now = func() time.Time { return now }
+
+	s := c.Encode(New("follow", strings.Repeat("x", 100)))
+	if _, err := c.Decode(s); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	now = now.Add(StateTTL)
+	if _, err := c.Decode(s); !errors.Is(err, ErrExpired) {
+		t.Errorf("Decode() after TTL error = %v, want %v", err, ErrExpired)
+	}
+
+	// Expired data is dropped when new data is stored
+	c.Encode(New("follow", strings.Repeat("y", 100)))
+	if len(c.states.states) != 1 {
+		t.Errorf("stored %d states, want 1", len(c.states.states))
+	}
+}
@@ -1,105 +1,424 @@
 package config
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/destination"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/secrets"
 )
 
-// Config holds application configuration
+// Config holds application configuration.
+//
+// Every field is described by struct tags:
+//   - env: environment variable name (also used in error messages)
+//   - file: dotted key in the YAML/TOML config file
+//   - default: value used when no source sets the field
+//   - secret: "true" hides the value in --print-config and excludes it from flags
+//
+// Secret fields can also be read from a file named by the environment variable
+// with a _FILE suffix (TELEGRAM_BOT_TOKEN_FILE), and their value can be an
+// Azure Key Vault reference resolved by ResolveSecrets.
+//
+// Non-secret fields can also be set on the command line with a flag named
+// after the environment variable (BOT_MODE -> --bot-mode).
 type Config struct {
 	// Telegram Bot
-	TelegramBotToken string
-	BotMode          string // "polling" or "webhook"
-	WebhookURL       string
-	WebhookSecret    string
+	TelegramBotToken     string `env:"TELEGRAM_BOT_TOKEN" file:"telegram.bot_token" secret:"true"`
+	BotMode              string `env:"BOT_MODE" file:"telegram.mode" default:"polling"` // "polling" or "webhook"
+	WebhookURL           string `env:"WEBHOOK_URL" file:"telegram.webhook_url"`
+	WebhookSecret        string `env:"WEBHOOK_SECRET" file:"telegram.webhook_secret" secret:"true"`
+	CallbackSecret       string `env:"CALLBACK_SECRET" file:"telegram.callback_secret" secret:"true"` // Signs button data; unsigned when empty
+	MaxConcurrentUpdates int    `env:"MAX_CONCURRENT_UPDATES" file:"telegram.max_concurrent_updates" default:"100"`
+	UserRateLimit        int    `env:"USER_RATE_LIMIT" file:"telegram.user_rate_limit" default:"30"` // Messages and button presses per user per minute; 0 disables
 
 	// Azure Communication Services
-	AzureCommunicationConnectionString string
-	SenderEmail                        string
+	AzureCommunicationConnectionString string `env:"AZURE_COMMUNICATION_CONNECTION_STRING" file:"email.connection_string" secret:"true"`
+	SenderEmail                        string `env:"SENDER_EMAIL" file:"email.sender"`
+	Destinations                       string `env:"DESTINATIONS" file:"email.destinations" default:"kindle"` // Comma-separated: kindle, pocketbook, email
 
 	// Database
-	DBType string // "memory", "postgres", or "cosmos"
+	DBType string `env:"DB_TYPE" file:"database.type" default:"memory"` // "memory", "postgres", or "cosmos"
 
 	// PostgreSQL
-	DBHost     string
-	DBPort     string
-	DBName     string
-	DBUser     string
-	DBPassword string
-	DBSSLMode  string
+	DBHost     string `env:"DB_HOST" file:"database.postgres.host"`
+	DBPort     string `env:"DB_PORT" file:"database.postgres.port" default:"5432"`
+	DBName     string `env:"DB_NAME" file:"database.postgres.name"`
+	DBUser     string `env:"DB_USER" file:"database.postgres.user"`
+	DBPassword string `env:"DB_PASSWORD" file:"database.postgres.password" secret:"true"`
+	DBSSLMode  string `env:"DB_SSL_MODE" file:"database.postgres.ssl_mode" default:"require"`
 
 	// Cosmos DB
-	CosmosEndpoint  string
-	CosmosKey       string
-	CosmosDatabase  string
-	CosmosContainer string
+	CosmosEndpoint  string `env:"COSMOS_ENDPOINT" file:"database.cosmos.endpoint"`
+	CosmosKey       string `env:"COSMOS_KEY" file:"database.cosmos.key" secret:"true"`
+	CosmosDatabase  string `env:"COSMOS_DATABASE" file:"database.cosmos.database"`
+	CosmosContainer string `env:"COSMOS_CONTAINER" file:"database.cosmos.container"`
 
 	// Application
-	LogLevel string
-	Port     string
+	LogLevel  string `env:"LOG_LEVEL" file:"log.level" default:"info"`
+	LogFormat string `env:"LOG_FORMAT" file:"log.format"` // "json" or "text"
+
+	// Localization
+	I18nStrict           bool          `env:"I18N_STRICT" file:"i18n.strict"`                                  // Refuse to start or reload with incomplete or inconsistent translations
+	LocalesDir           string        `env:"LOCALES_DIR" file:"i18n.locales_dir"`                             // Translation files overriding or adding to the built-in ones
+	LocalesWatchInterval time.Duration `env:"LOCALES_WATCH_INTERVAL" file:"i18n.watch_interval" default:"30s"` // How often LOCALES_DIR is checked for changes; 0 disables
+
+	// HTTP server (webhook, health probes and metrics)
+	Port             string        `env:"PORT" file:"server.port" default:"8080"`
+	HealthPort       string        `env:"HEALTH_PORT" file:"server.health_port"` // Serves probes and metrics apart from the webhook; empty uses PORT
+	HTTPReadTimeout  time.Duration `env:"HTTP_READ_TIMEOUT" file:"server.read_timeout" default:"10s"`
+	HTTPWriteTimeout time.Duration `env:"HTTP_WRITE_TIMEOUT" file:"server.write_timeout" default:"10s"`
+	ShutdownTimeout  time.Duration `env:"SHUTDOWN_TIMEOUT" file:"server.shutdown_timeout" default:"5s"`
+
+	// Health checks
+	FlibustaURL        string        `env:"FLIBUSTA_URL" file:"flibusta.url" default:"https://flibusta.is"`
+	HealthCheckTimeout time.Duration `env:"HEALTH_CHECK_TIMEOUT" file:"health.check_timeout" default:"5s"`
+
+	// Administration
+	AdminUserIDs string `env:"ADMIN_USER_IDS" file:"admin.user_ids"` // Comma-separated Telegram IDs allowed to use admin commands
+
+	// Maintenance
+	MaintenanceInterval time.Duration `env:"MAINTENANCE_INTERVAL" file:"maintenance.interval" default:"1h"`                // 0 disables the scheduler
+	InactiveUserPeriod  time.Duration `env:"INACTIVE_USER_PERIOD" file:"maintenance.inactive_user_period" default:"2160h"` // 90 days; 0 never marks users inactive
+	SearchContextTTL    time.Duration `env:"SEARCH_CONTEXT_TTL" file:"maintenance.search_context_ttl" default:"30m"`
+
+	// Background delivery and reading lists
+	DeliveryWorkers     int `env:"DELIVERY_WORKERS" file:"delivery.workers" default:"2"`
+	DeliveryQueueSize   int `env:"DELIVERY_QUEUE_SIZE" file:"delivery.queue_size" default:"100"`
+	ReadingListMaxBooks int `env:"READING_LIST_MAX_BOOKS" file:"delivery.reading_list_max_books" default:"50"`
+
+	// Author and series follows
+	FollowPollInterval time.Duration `env:"FOLLOW_POLL_INTERVAL" file:"follows.poll_interval" default:"1h"` // 0 disables new-release checks
+	MaxFollows         int           `env:"MAX_FOLLOWS" file:"follows.max_follows" default:"100"`
 
 	// Azure Application Insights
-	AppInsightsInstrumentationKey string
+	AppInsightsInstrumentationKey string `env:"APPINSIGHTS_INSTRUMENTATIONKEY" file:"app_insights.instrumentation_key" secret:"true"`
+	AppInsightsConnectionString   string `env:"APPLICATIONINSIGHTS_CONNECTION_STRING" file:"app_insights.connection_string" secret:"true"`
+
+	// Azure Key Vault
+	KeyVaultClientID        string        `env:"AZURE_CLIENT_ID" file:"key_vault.client_id"`                                // User-assigned managed identity; empty for system-assigned
+	KeyVaultRefreshInterval time.Duration `env:"KEY_VAULT_REFRESH_INTERVAL" file:"key_vault.refresh_interval" default:"1h"` // 0 disables refresh
+
+	// Tracing
+	TracingExporter    string  `env:"TRACING_EXPORTER" file:"tracing.exporter"`                     // "none", "otlp", or "appinsights"
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO" file:"tracing.sample_ratio" default:"1"` // Fraction of traces sampled, 0..1
+}
+
+// Options controls where configuration is read from.
+// Sources are applied in order: defaults < file < environment < flags.
+type Options struct {
+	// File is an optional YAML (.yaml, .yml) or TOML (.toml) config file
+	File string
+
+	// Flags holds values set on the command line, keyed by environment variable name
+	Flags map[string]string
 }
 
-// Load loads configuration from environment variables
+// Load loads configuration from environment variables and the optional
+// config file named by CONFIG_FILE
 func Load() (*Config, error) {
+	return LoadWithOptions(Options{})
+}
+
+// LoadWithOptions loads configuration from all sources and validates it.
+// All problems are reported together in the returned error.
+func LoadWithOptions(opts Options) (*Config, error) {
 	// Try to load .env file (optional)
 	_ = godotenv.Load()
 
-	cfg := &Config{
-		TelegramBotToken:                   os.Getenv("TELEGRAM_BOT_TOKEN"),
-		BotMode:                            getEnvOrDefault("BOT_MODE", "polling"),
-		WebhookURL:                         os.Getenv("WEBHOOK_URL"),
-		WebhookSecret:                      os.Getenv("WEBHOOK_SECRET"),
-		AzureCommunicationConnectionString: os.Getenv("AZURE_COMMUNICATION_CONNECTION_STRING"),
-		SenderEmail:                        os.Getenv("SENDER_EMAIL"),
-		DBType:                             getEnvOrDefault("DB_TYPE", "memory"),
-		DBHost:                             os.Getenv("DB_HOST"),
-		DBPort:                             getEnvOrDefault("DB_PORT", "5432"),
-		DBName:                             os.Getenv("DB_NAME"),
-		DBUser:                             os.Getenv("DB_USER"),
-		DBPassword:                         os.Getenv("DB_PASSWORD"),
-		DBSSLMode:                          getEnvOrDefault("DB_SSL_MODE", "require"),
-		CosmosEndpoint:                     os.Getenv("COSMOS_ENDPOINT"),
-		CosmosKey:                          os.Getenv("COSMOS_KEY"),
-		CosmosDatabase:                     os.Getenv("COSMOS_DATABASE"),
-		CosmosContainer:                    os.Getenv("COSMOS_CONTAINER"),
-		LogLevel:                           getEnvOrDefault("LOG_LEVEL", "info"),
-		Port:                               getEnvOrDefault("PORT", "8080"),
-		AppInsightsInstrumentationKey:      os.Getenv("APPINSIGHTS_INSTRUMENTATIONKEY"),
+	if opts.File == "" {
+		opts.File = os.Getenv("CONFIG_FILE")
 	}
 
+	var fileValues map[string]string
+	if opts.File != "" {
+		values, err := readFile(opts.File)
+		if err != nil {
+			return nil, err
+		}
+		fileValues = values
+	}
+
+	cfg := &Config{}
+	var errs []error
+
+	known := make(map[string]bool)
+	for _, f := range fields() {
+		known[f.file] = true
+
+		value := f.def
+		if v, ok := fileValues[f.file]; ok {
+			value = v
+		}
+		value = getEnvOrDefault(f.env, value)
+		if f.secret {
+			v, err := secretFromFile(f.env, value)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			value = v
+		}
+		if v, ok := opts.Flags[f.env]; ok {
+			value = v
+		}
+
+		if err := f.set(cfg, value); err != nil {
+			errs = append(errs, err)
+			// Fall back to the default so validation does not report the field twice
+			_ = f.set(cfg, f.def)
+		}
+	}
+
+	for key := range fileValues {
+		if !known[key] {
+			errs = append(errs, fmt.Errorf("unknown config file key: %s", key))
+		}
+	}
+
+	cfg.applyDerivedDefaults()
+	errs = append(errs, cfg.validate()...)
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return cfg, nil
+}
+
+// applyDerivedDefaults fills settings whose defaults depend on other settings
+func (c *Config) applyDerivedDefaults() {
+	// Production (webhook) deployments log JSON, local runs log text
+	if c.LogFormat == "" {
+		if c.BotMode == "webhook" {
+			c.LogFormat = "json"
+		} else {
+			c.LogFormat = "text"
+		}
+	}
+
+	// Application Insights is used for tracing when configured
+	if c.TracingExporter == "" {
+		if c.AppInsightsConnectionString != "" || c.AppInsightsInstrumentationKey != "" {
+			c.TracingExporter = "appinsights"
+		} else {
+			c.TracingExporter = "none"
+		}
+	}
+}
+
+// validate checks the loaded configuration and returns every problem found
+func (c *Config) validate() []error {
+	var errs []error
+
 	// Validate required fields
-	if cfg.TelegramBotToken == "" {
-		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN is required")
+	if c.TelegramBotToken == "" {
+		errs = append(errs, fmt.Errorf("TELEGRAM_BOT_TOKEN is required"))
+	}
+
+	switch c.BotMode {
+	case "polling":
+	case "webhook":
+		if c.WebhookURL == "" {
+			errs = append(errs, fmt.Errorf("WEBHOOK_URL is required for webhook mode"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid BOT_MODE: %s (must be 'polling' or 'webhook')", c.BotMode))
+	}
+
+	if c.MaxConcurrentUpdates <= 0 {
+		errs = append(errs, fmt.Errorf("invalid MAX_CONCURRENT_UPDATES: must be positive"))
+	}
+	if c.UserRateLimit < 0 {
+		errs = append(errs, fmt.Errorf("invalid USER_RATE_LIMIT: must not be negative"))
+	}
+	if c.HealthPort != "" && c.HealthPort == c.Port {
+		errs = append(errs, fmt.Errorf("invalid HEALTH_PORT: must differ from PORT"))
+	}
+
+	// Validate logging configuration
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("invalid LOG_LEVEL: %s (must be 'debug', 'info', 'warn', or 'error')", c.LogLevel))
+	}
+
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		errs = append(errs, fmt.Errorf("invalid LOG_FORMAT: %s (must be 'json' or 'text')", c.LogFormat))
+	}
+
+	// Validate HTTP server and health check timings
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"HTTP_READ_TIMEOUT", c.HTTPReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", c.HTTPWriteTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+		{"HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout},
+	} {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("invalid %s: must be a positive duration such as 5s", d.name))
+		}
+	}
+
+	// Validate tracing configuration
+	switch c.TracingExporter {
+	case "none", "otlp":
+	case "appinsights":
+		if c.AppInsightsConnectionString == "" && c.AppInsightsInstrumentationKey == "" {
+			errs = append(errs, fmt.Errorf("APPLICATIONINSIGHTS_CONNECTION_STRING or APPINSIGHTS_INSTRUMENTATIONKEY is required for appinsights tracing"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid TRACING_EXPORTER: %s (must be 'none', 'otlp', or 'appinsights')", c.TracingExporter))
+	}
+
+	if _, err := destination.NewValidatorFromNames(c.DestinationNames()); err != nil {
+		errs = append(errs, fmt.Errorf("invalid DESTINATIONS: %w (must be 'kindle', 'pocketbook', or 'email')", err))
+	}
+
+	// Validate administration and maintenance settings
+	if _, err := c.AdminIDs(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"MAINTENANCE_INTERVAL", c.MaintenanceInterval},
+		{"INACTIVE_USER_PERIOD", c.InactiveUserPeriod},
+		{"FOLLOW_POLL_INTERVAL", c.FollowPollInterval},
+		{"LOCALES_WATCH_INTERVAL", c.LocalesWatchInterval},
+	} {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("invalid %s: must not be negative", d.name))
+		}
+	}
+	if c.SearchContextTTL <= 0 {
+		errs = append(errs, fmt.Errorf("invalid SEARCH_CONTEXT_TTL: must be a positive duration such as 30m"))
 	}
 
-	if cfg.BotMode == "webhook" {
-		if cfg.WebhookURL == "" {
-			return nil, fmt.Errorf("WEBHOOK_URL is required for webhook mode")
+	// Validate background delivery settings
+	for _, n := range []struct {
+		name  string
+		value int
+	}{
+		{"DELIVERY_WORKERS", c.DeliveryWorkers},
+		{"DELIVERY_QUEUE_SIZE", c.DeliveryQueueSize},
+		{"READING_LIST_MAX_BOOKS", c.ReadingListMaxBooks},
+		{"MAX_FOLLOWS", c.MaxFollows},
+	} {
+		if n.value <= 0 {
+			errs = append(errs, fmt.Errorf("invalid %s: must be positive", n.name))
 		}
 	}
 
+	if c.KeyVaultRefreshInterval < 0 {
+		errs = append(errs, fmt.Errorf("invalid KEY_VAULT_REFRESH_INTERVAL: must not be negative"))
+	}
+
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("invalid TRACING_SAMPLE_RATIO: must be a number between 0 and 1"))
+	}
+
 	// Validate database configuration
-	switch cfg.DBType {
+	switch c.DBType {
 	case "memory":
 		// No additional validation needed
 	case "postgres":
-		if cfg.DBHost == "" || cfg.DBName == "" || cfg.DBUser == "" || cfg.DBPassword == "" {
-			return nil, fmt.Errorf("postgres configuration incomplete: DB_HOST, DB_NAME, DB_USER, and DB_PASSWORD are required")
+		if c.DBHost == "" || c.DBName == "" || c.DBUser == "" || c.DBPassword == "" {
+			errs = append(errs, fmt.Errorf("postgres configuration incomplete: DB_HOST, DB_NAME, DB_USER, and DB_PASSWORD are required"))
 		}
 	case "cosmos":
-		if cfg.CosmosEndpoint == "" || cfg.CosmosKey == "" || cfg.CosmosDatabase == "" || cfg.CosmosContainer == "" {
-			return nil, fmt.Errorf("cosmos configuration incomplete: COSMOS_ENDPOINT, COSMOS_KEY, COSMOS_DATABASE, and COSMOS_CONTAINER are required")
+		if c.CosmosEndpoint == "" || c.CosmosKey == "" || c.CosmosDatabase == "" || c.CosmosContainer == "" {
+			errs = append(errs, fmt.Errorf("cosmos configuration incomplete: COSMOS_ENDPOINT, COSMOS_KEY, COSMOS_DATABASE, and COSMOS_CONTAINER are required"))
 		}
 	default:
-		return nil, fmt.Errorf("invalid DB_TYPE: %s (must be 'memory', 'postgres', or 'cosmos')", cfg.DBType)
+		errs = append(errs, fmt.Errorf("invalid DB_TYPE: %s (must be 'memory', 'postgres', or 'cosmos')", c.DBType))
 	}
 
-	return cfg, nil
+	return errs
+}
+
+// ResolveSecrets replaces Key Vault references in secret settings with the
+// secret values. Resolved settings are tracked by r for refresh.
+func (c *Config) ResolveSecrets(ctx context.Context, r *secrets.Resolver) error {
+	var errs []error
+	for _, f := range fields() {
+		if !f.secret {
+			continue
+		}
+
+		value, err := r.Resolve(ctx, f.env, f.get(c))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		_ = f.set(c, value)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Secrets returns the non-empty secret values, for log redaction
+func (c *Config) Secrets() []string {
+	var secrets []string
+	for _, f := range fields() {
+		if f.secret {
+			if v := f.get(c); v != "" {
+				secrets = append(secrets, v)
+			}
+		}
+	}
+	return secrets
+}
+
+// DestinationNames returns the enabled e-reader destinations
+func (c *Config) DestinationNames() []string {
+	var names []string
+	for _, name := range strings.Split(c.Destinations, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// AdminIDs returns the Telegram IDs of the bot administrators
+func (c *Config) AdminIDs() ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(c.AdminUserIDs, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ADMIN_USER_IDS: %q is not a Telegram user ID", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// CommunicationEndpoint returns the Azure Communication Services endpoint
+// from the connection string, or "" if it is not configured
+func (c *Config) CommunicationEndpoint() string {
+	for _, part := range strings.Split(c.AzureCommunicationConnectionString, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "endpoint") {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
 }
 
 // getEnvOrDefault returns environment variable value or default
@@ -109,3 +428,71 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// secretFromFile reads the setting from the file named by {env}_FILE, if set.
+// Otherwise value is returned unchanged.
+func secretFromFile(env, value string) (string, error) {
+	path := os.Getenv(env + "_FILE")
+	if path == "" {
+		return value, nil
+	}
+	if os.Getenv(env) != "" {
+		return value, fmt.Errorf("invalid %s_FILE: %s is also set, use only one of them", env, env)
+	}
+
+	secret, err := secrets.ReadFile(path)
+	if err != nil {
+		return value, fmt.Errorf("invalid %s_FILE: %w", env, err)
+	}
+
+	return secret, nil
+}
+
+// field describes one configuration setting, derived from Config struct tags
+type field struct {
+	index  int
+	env    string
+	file   string
+	def    string
+	secret bool
+}
+
+// fields lists the configuration settings in declaration order
+func fields() []field {
+	t := reflect.TypeOf(Config{})
+	result := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		result = append(result, field{
+			index:  i,
+			env:    sf.Tag.Get("env"),
+			file:   sf.Tag.Get("file"),
+			def:    sf.Tag.Get("default"),
+			secret: sf.Tag.Get("secret") == "true",
+		})
+	}
+	return result
+}
+
+// set parses value into the field's type and stores it in cfg
+func (f field) set(cfg *Config, value string) error {
+	v := reflect.ValueOf(cfg).Elem().Field(f.index)
+
+	if value == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	parsed, err := parseValue(v.Type(), value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", f.env, err)
+	}
+	v.Set(parsed)
+
+	return nil
+}
+
+// get formats the field's current value in cfg
+func (f field) get(cfg *Config) string {
+	return formatValue(reflect.ValueOf(cfg).Elem().Field(f.index))
+}
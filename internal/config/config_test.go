@@ -1,8 +1,18 @@
 package config
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/secrets"
 )
 
 func TestLoad_Success(t *testing.T) {
@@ -283,3 +293,327 @@ func TestLoad_AllOptionalFields(t *testing.T) {
 		t.Errorf("AppInsightsInstrumentationKey = %v, want %v", cfg.AppInsightsInstrumentationKey, "insights_key")
 	}
 }
+
+func TestLoad_LogFormat(t *testing.T) {
+	os.Setenv("TELEGRAM_BOT_TOKEN", "test_token")
+	defer os.Unsetenv("TELEGRAM_BOT_TOKEN")
+	defer os.Unsetenv("BOT_MODE")
+	defer os.Unsetenv("WEBHOOK_URL")
+	defer os.Unsetenv("LOG_FORMAT")
+
+	// Polling mode defaults to text output
+	os.Unsetenv("BOT_MODE")
+	os.Unsetenv("LOG_FORMAT")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.LogFormat != "text" {
+		t.Errorf("LogFormat = %v, want %v", cfg.LogFormat, "text")
+	}
+
+	// Webhook mode defaults to JSON output
+	os.Setenv("BOT_MODE", "webhook")
+	os.Setenv("WEBHOOK_URL", "https://example.com/webhook")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.LogFormat != "json" {
+		t.Errorf("LogFormat = %v, want %v", cfg.LogFormat, "json")
+	}
+
+	// Explicit format wins
+	os.Setenv("LOG_FORMAT", "text")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.LogFormat != "text" {
+		t.Errorf("LogFormat = %v, want %v", cfg.LogFormat, "text")
+	}
+
+	// Invalid format is rejected
+	os.Setenv("LOG_FORMAT", "xml")
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for invalid LOG_FORMAT, got nil")
+	}
+}
+
+func TestLoad_InvalidLogLevel(t *testing.T) {
+	os.Setenv("TELEGRAM_BOT_TOKEN", "test_token")
+	os.Setenv("LOG_LEVEL", "verbose")
+	defer os.Unsetenv("TELEGRAM_BOT_TOKEN")
+	defer os.Unsetenv("LOG_LEVEL")
+
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for invalid LOG_LEVEL, got nil")
+	}
+}
+
+func TestLoad_Tracing(t *testing.T) {
+	os.Setenv("TELEGRAM_BOT_TOKEN", "test_token")
+	defer os.Unsetenv("TELEGRAM_BOT_TOKEN")
+	defer os.Unsetenv("TRACING_EXPORTER")
+	defer os.Unsetenv("TRACING_SAMPLE_RATIO")
+	defer os.Unsetenv("APPINSIGHTS_INSTRUMENTATIONKEY")
+
+	// Tracing is disabled by default
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.TracingExporter != "none" {
+		t.Errorf("TracingExporter = %v, want %v", cfg.TracingExporter, "none")
+	}
+	if cfg.TracingSampleRatio != 1 {
+		t.Errorf("TracingSampleRatio = %v, want %v", cfg.TracingSampleRatio, 1)
+	}
+
+	// Application Insights is selected when a key is present
+	os.Setenv("APPINSIGHTS_INSTRUMENTATIONKEY", "insights_key")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.TracingExporter != "appinsights" {
+		t.Errorf("TracingExporter = %v, want %v", cfg.TracingExporter, "appinsights")
+	}
+	os.Unsetenv("APPINSIGHTS_INSTRUMENTATIONKEY")
+
+	// Application Insights requires a key when selected explicitly
+	os.Setenv("TRACING_EXPORTER", "appinsights")
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for appinsights exporter without key, got nil")
+	}
+
+	os.Setenv("TRACING_EXPORTER", "jaeger")
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for invalid TRACING_EXPORTER, got nil")
+	}
+
+	os.Setenv("TRACING_EXPORTER", "otlp")
+	os.Setenv("TRACING_SAMPLE_RATIO", "1.5")
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for out of range TRACING_SAMPLE_RATIO, got nil")
+	}
+}
+
+func TestLoad_HealthCheckTimeout(t *testing.T) {
+	os.Setenv("TELEGRAM_BOT_TOKEN", "test_token")
+	defer os.Unsetenv("TELEGRAM_BOT_TOKEN")
+	defer os.Unsetenv("HEALTH_CHECK_TIMEOUT")
+
+	os.Unsetenv("HEALTH_CHECK_TIMEOUT")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.HealthCheckTimeout != 5*time.Second {
+		t.Errorf("HealthCheckTimeout = %v, want %v", cfg.HealthCheckTimeout, 5*time.Second)
+	}
+	if cfg.FlibustaURL != "https://flibusta.is" {
+		t.Errorf("FlibustaURL = %v, want %v", cfg.FlibustaURL, "https://flibusta.is")
+	}
+
+	os.Setenv("HEALTH_CHECK_TIMEOUT", "soon")
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for invalid HEALTH_CHECK_TIMEOUT, got nil")
+	}
+}
+
+func TestLoad_HealthPort(t *testing.T) {
+	os.Setenv("TELEGRAM_BOT_TOKEN", "test_token")
+	defer os.Unsetenv("TELEGRAM_BOT_TOKEN")
+	defer os.Unsetenv("HEALTH_PORT")
+
+	os.Setenv("HEALTH_PORT", "9090")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.HealthPort != "9090" {
+		t.Errorf("HealthPort = %v, want 9090", cfg.HealthPort)
+	}
+
+	// Probes cannot share the webhook's port
+	os.Setenv("HEALTH_PORT", cfg.Port)
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for HEALTH_PORT equal to PORT, got nil")
+	}
+}
+
+func TestLoad_Maintenance(t *testing.T) {
+	os.Setenv("TELEGRAM_BOT_TOKEN", "test_token")
+	defer os.Unsetenv("TELEGRAM_BOT_TOKEN")
+	defer os.Unsetenv("INACTIVE_USER_PERIOD")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.InactiveUserPeriod != 90*24*time.Hour {
+		t.Errorf("InactiveUserPeriod = %v, want %v", cfg.InactiveUserPeriod, 90*24*time.Hour)
+	}
+
+	if names := cfg.DestinationNames(); !reflect.DeepEqual(names, []string{"kindle"}) {
+		t.Errorf("DestinationNames() = %v, want [kindle]", names)
+	}
+
+	os.Setenv("INACTIVE_USER_PERIOD", "-1h")
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for negative INACTIVE_USER_PERIOD, got nil")
+	}
+}
+
+func TestLoad_Destinations(t *testing.T) {
+	os.Setenv("TELEGRAM_BOT_TOKEN", "test_token")
+	defer os.Unsetenv("TELEGRAM_BOT_TOKEN")
+	defer os.Unsetenv("DESTINATIONS")
+
+	os.Setenv("DESTINATIONS", "kindle, pocketbook,email")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if names := cfg.DestinationNames(); !reflect.DeepEqual(names, []string{"kindle", "pocketbook", "email"}) {
+		t.Errorf("DestinationNames() = %v", names)
+	}
+
+	os.Setenv("DESTINATIONS", "kindle,kobo")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "DESTINATIONS") {
+		t.Errorf("Load() error = %v, want invalid DESTINATIONS", err)
+	}
+}
+
+func TestConfig_AdminIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []int64
+		wantErr  bool
+	}{
+		{name: "not configured", value: "", expected: nil},
+		{name: "list", value: "123, 456,", expected: []int64{123, 456}},
+		{name: "invalid", value: "123,admin", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{AdminUserIDs: tt.value}
+			got, err := cfg.AdminIDs()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AdminIDs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("AdminIDs() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfig_CommunicationEndpoint(t *testing.T) {
+	tests := []struct {
+		name             string
+		connectionString string
+		expected         string
+	}{
+		{
+			name:             "full connection string",
+			connectionString: "endpoint=https://bot.communication.azure.com/;accesskey=abc==",
+			expected:         "https://bot.communication.azure.com/",
+		},
+		{
+			name:             "not configured",
+			connectionString: "",
+			expected:         "",
+		},
+		{
+			name:             "no endpoint",
+			connectionString: "accesskey=abc",
+			expected:         "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{AzureCommunicationConnectionString: tt.connectionString}
+			if got := cfg.CommunicationEndpoint(); got != tt.expected {
+				t.Errorf("CommunicationEndpoint() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoad_SecretFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telegram_bot_token")
+	if err := os.WriteFile(path, []byte("token_from_file\n"), 0600); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+
+	os.Unsetenv("TELEGRAM_BOT_TOKEN")
+	os.Setenv("TELEGRAM_BOT_TOKEN_FILE", path)
+	defer os.Unsetenv("TELEGRAM_BOT_TOKEN_FILE")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.TelegramBotToken != "token_from_file" {
+		t.Errorf("TelegramBotToken = %v, want %v", cfg.TelegramBotToken, "token_from_file")
+	}
+
+	// Setting both the variable and its _FILE variant is ambiguous
+	os.Setenv("TELEGRAM_BOT_TOKEN", "token_from_env")
+	defer os.Unsetenv("TELEGRAM_BOT_TOKEN")
+	if _, err := Load(); err == nil {
+		t.Error("Expected error when both TELEGRAM_BOT_TOKEN and TELEGRAM_BOT_TOKEN_FILE are set, got nil")
+	}
+
+	// Missing files are reported
+	os.Unsetenv("TELEGRAM_BOT_TOKEN")
+	os.Setenv("TELEGRAM_BOT_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "TELEGRAM_BOT_TOKEN_FILE") {
+		t.Errorf("Load() error = %v, want TELEGRAM_BOT_TOKEN_FILE error", err)
+	}
+}
+
+type staticToken string
+
+func (s staticToken) Token(context.Context) (string, error) { return string(s), nil }
+
+func TestConfig_ResolveSecrets(t *testing.T) {
+	vault := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/secrets/telegram-bot-token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"value": "token_from_vault"})
+	}))
+	defer vault.Close()
+
+	cfg := &Config{
+		TelegramBotToken: "@Microsoft.KeyVault(SecretUri=" + vault.URL + "/secrets/telegram-bot-token)",
+		DBPassword:       "plain_password",
+		BotMode:          "polling",
+	}
+
+	resolver := secrets.NewResolver(secrets.NewKeyVaultClient(staticToken("vault-token"), vault.Client()))
+	if err := cfg.ResolveSecrets(context.Background(), resolver); err != nil {
+		t.Fatalf("ResolveSecrets() error = %v", err)
+	}
+
+	if cfg.TelegramBotToken != "token_from_vault" {
+		t.Errorf("TelegramBotToken = %v, want %v", cfg.TelegramBotToken, "token_from_vault")
+	}
+	if cfg.DBPassword != "plain_password" {
+		t.Errorf("DBPassword = %v, want %v", cfg.DBPassword, "plain_password")
+	}
+
+	// Unresolvable references are reported by setting name
+	cfg.CosmosKey = "@Microsoft.KeyVault(SecretUri=" + vault.URL + "/secrets/cosmos-key)"
+	err := cfg.ResolveSecrets(context.Background(), resolver)
+	if err == nil || !strings.Contains(err.Error(), "COSMOS_KEY") {
+		t.Errorf("ResolveSecrets() error = %v, want COSMOS_KEY error", err)
+	}
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secret values in printed configuration
+const redacted = "[REDACTED]"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// readFile reads a YAML or TOML config file into dotted keys
+// ("telegram.mode") mapped to string values
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	raw := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	case ".toml":
+		if err := toml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file format: %s (must be .yaml, .yml, or .toml)", path)
+	}
+
+	values := make(map[string]string)
+	flatten("", raw, values)
+
+	return values, nil
+}
+
+// flatten converts nested maps to dotted keys
+func flatten(prefix string, in map[string]interface{}, out map[string]string) {
+	for key, value := range in {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flatten(key, v, out)
+		case nil:
+			out[key] = ""
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// parseValue converts a string to the given field type
+func parseValue(t reflect.Type, value string) (reflect.Value, error) {
+	if t == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("must be a duration such as 5s: %q", value)
+		}
+		return reflect.ValueOf(d), nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(value), nil
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("must be an integer: %q", value)
+		}
+		return reflect.ValueOf(n), nil
+	case reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("must be an integer: %q", value)
+		}
+		return reflect.ValueOf(n), nil
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("must be a number: %q", value)
+		}
+		return reflect.ValueOf(f), nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("must be true or false: %q", value)
+		}
+		return reflect.ValueOf(b), nil
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported setting type %s", t)
+	}
+}
+
+// formatValue converts a field value back to its string form
+func formatValue(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// Flags holds the configuration flags registered on a flag set
+type Flags struct {
+	ConfigFile  string
+	PrintConfig bool
+
+	fs     *flag.FlagSet
+	values map[string]*string // flag name -> value
+	envs   map[string]string  // flag name -> environment variable name
+}
+
+// RegisterFlags registers --config, --print-config and one flag per
+// non-secret setting on fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{
+		fs:     fs,
+		values: make(map[string]*string),
+		envs:   make(map[string]string),
+	}
+
+	fs.StringVar(&f.ConfigFile, "config", "", "path to a YAML or TOML config file (overrides CONFIG_FILE)")
+	fs.BoolVar(&f.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+
+	for _, field := range fields() {
+		if field.secret {
+			// Secrets on the command line leak through the process list
+			continue
+		}
+		name := flagName(field.env)
+		f.values[name] = fs.String(name, "", fmt.Sprintf("overrides %s", field.env))
+		f.envs[name] = field.env
+	}
+
+	return f
+}
+
+// Options returns the load options for the flags set on the command line.
+// Call it after the flag set has been parsed.
+func (f *Flags) Options() Options {
+	opts := Options{
+		File:  f.ConfigFile,
+		Flags: make(map[string]string),
+	}
+
+	f.fs.Visit(func(fl *flag.Flag) {
+		if env, ok := f.envs[fl.Name]; ok {
+			opts.Flags[env] = *f.values[fl.Name]
+		}
+	})
+
+	return opts
+}
+
+// flagName converts an environment variable name to a flag name (BOT_MODE -> bot-mode)
+func flagName(env string) string {
+	return strings.ReplaceAll(strings.ToLower(env), "_", "-")
+}
+
+// Print writes the configuration as YAML in config file layout, with secrets redacted
+func (c *Config) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+
+	for _, f := range fields() {
+		value := f.get(c)
+		if f.secret && value != "" {
+			value = redacted
+		}
+		setNode(root, strings.Split(f.file, "."), value)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return fmt.Errorf("failed to print config: %w", err)
+	}
+
+	return enc.Close()
+}
+
+// setNode stores value under the key path in a YAML mapping node, keeping insertion order
+func setNode(node *yaml.Node, path []string, value string) {
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == path[0] {
+			if len(path) > 1 {
+				setNode(node.Content[i+1], path[1:], value)
+			}
+			return
+		}
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Value: path[0]}
+	if len(path) == 1 {
+		scalar := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+		if value == "" {
+			// Print "" rather than a bare key, which YAML reads as null
+			scalar.Style = yaml.DoubleQuotedStyle
+		}
+		node.Content = append(node.Content, key, scalar)
+		return
+	}
+
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	node.Content = append(node.Content, key, child)
+	setNode(child, path[1:], value)
+}
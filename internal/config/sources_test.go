@@ -0,0 +1,300 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadWithOptions_YAMLFile(t *testing.T) {
+	os.Unsetenv("BOT_MODE")
+	os.Unsetenv("LOG_LEVEL")
+
+	path := writeConfigFile(t, "config.yaml", `
+telegram:
+  bot_token: file_token
+  mode: webhook
+  webhook_url: https://example.com/webhook
+  max_concurrent_updates: 20
+log:
+  level: debug
+server:
+  shutdown_timeout: 30s
+tracing:
+  sample_ratio: 0.25
+`)
+
+	cfg, err := LoadWithOptions(Options{File: path})
+	if err != nil {
+		t.Fatalf("LoadWithOptions() error = %v", err)
+	}
+
+	if cfg.TelegramBotToken != "file_token" {
+		t.Errorf("TelegramBotToken = %v, want %v", cfg.TelegramBotToken, "file_token")
+	}
+	if cfg.BotMode != "webhook" {
+		t.Errorf("BotMode = %v, want %v", cfg.BotMode, "webhook")
+	}
+	if cfg.MaxConcurrentUpdates != 20 {
+		t.Errorf("MaxConcurrentUpdates = %v, want %v", cfg.MaxConcurrentUpdates, 20)
+	}
+	if cfg.LogLevel != "debug" {
+		t.Errorf("LogLevel = %v, want %v", cfg.LogLevel, "debug")
+	}
+	if cfg.ShutdownTimeout != 30*time.Second {
+		t.Errorf("ShutdownTimeout = %v, want %v", cfg.ShutdownTimeout, 30*time.Second)
+	}
+	if cfg.TracingSampleRatio != 0.25 {
+		t.Errorf("TracingSampleRatio = %v, want %v", cfg.TracingSampleRatio, 0.25)
+	}
+
+	// Unset values keep their defaults
+	if cfg.Port != "8080" {
+		t.Errorf("Port = %v, want %v", cfg.Port, "8080")
+	}
+	if cfg.HTTPReadTimeout != 10*time.Second {
+		t.Errorf("HTTPReadTimeout = %v, want %v", cfg.HTTPReadTimeout, 10*time.Second)
+	}
+}
+
+func TestLoadWithOptions_TOMLFile(t *testing.T) {
+	path := writeConfigFile(t, "config.toml", `
+[telegram]
+bot_token = "toml_token"
+
+[database]
+type = "memory"
+
+[health]
+check_timeout = "2s"
+`)
+
+	cfg, err := LoadWithOptions(Options{File: path})
+	if err != nil {
+		t.Fatalf("LoadWithOptions() error = %v", err)
+	}
+
+	if cfg.TelegramBotToken != "toml_token" {
+		t.Errorf("TelegramBotToken = %v, want %v", cfg.TelegramBotToken, "toml_token")
+	}
+	if cfg.HealthCheckTimeout != 2*time.Second {
+		t.Errorf("HealthCheckTimeout = %v, want %v", cfg.HealthCheckTimeout, 2*time.Second)
+	}
+}
+
+func TestLoadWithOptions_Precedence(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+telegram:
+  bot_token: file_token
+log:
+  level: warn
+server:
+  port: "9000"
+`)
+
+	os.Setenv("LOG_LEVEL", "error")
+	os.Setenv("PORT", "9100")
+	defer os.Unsetenv("LOG_LEVEL")
+	defer os.Unsetenv("PORT")
+
+	cfg, err := LoadWithOptions(Options{
+		File:  path,
+		Flags: map[string]string{"PORT": "9200"},
+	})
+	if err != nil {
+		t.Fatalf("LoadWithOptions() error = %v", err)
+	}
+
+	// Environment overrides file
+	if cfg.LogLevel != "error" {
+		t.Errorf("LogLevel = %v, want %v (env over file)", cfg.LogLevel, "error")
+	}
+	// Flags override environment
+	if cfg.Port != "9200" {
+		t.Errorf("Port = %v, want %v (flag over env)", cfg.Port, "9200")
+	}
+}
+
+func TestLoadWithOptions_ConfigFileEnv(t *testing.T) {
+	path := writeConfigFile(t, "bot.yml", "telegram:\n  bot_token: from_config_file_env\n")
+
+	os.Unsetenv("TELEGRAM_BOT_TOKEN")
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.TelegramBotToken != "from_config_file_env" {
+		t.Errorf("TelegramBotToken = %v, want %v", cfg.TelegramBotToken, "from_config_file_env")
+	}
+}
+
+func TestLoadWithOptions_FileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name:    "unsupported extension",
+			file:    "config.ini",
+			content: "bot_token=x",
+		},
+		{
+			name:    "malformed yaml",
+			file:    "config.yaml",
+			content: "telegram: [unclosed",
+		},
+		{
+			name:    "unknown key",
+			file:    "config.yaml",
+			content: "telegram:\n  bot_token: x\n  moed: polling\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigFile(t, tt.file, tt.content)
+			if _, err := LoadWithOptions(Options{File: path}); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+
+	if _, err := LoadWithOptions(Options{File: "/nonexistent/config.yaml"}); err == nil {
+		t.Error("Expected error for missing config file, got nil")
+	}
+}
+
+func TestLoadWithOptions_AggregatedErrors(t *testing.T) {
+	os.Unsetenv("TELEGRAM_BOT_TOKEN")
+	os.Setenv("BOT_MODE", "webhook")
+	os.Setenv("LOG_LEVEL", "verbose")
+	os.Setenv("HTTP_READ_TIMEOUT", "ten seconds")
+	defer os.Unsetenv("BOT_MODE")
+	defer os.Unsetenv("LOG_LEVEL")
+	defer os.Unsetenv("HTTP_READ_TIMEOUT")
+
+	_, err := LoadWithOptions(Options{})
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	msg := err.Error()
+	for _, want := range []string{
+		"TELEGRAM_BOT_TOKEN is required",
+		"WEBHOOK_URL is required",
+		"invalid LOG_LEVEL",
+		"invalid HTTP_READ_TIMEOUT",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Error %q does not mention %q", msg, want)
+		}
+	}
+
+	// An unparsable value is reported once, not again by validation
+	if n := strings.Count(msg, "HTTP_READ_TIMEOUT"); n != 1 {
+		t.Errorf("HTTP_READ_TIMEOUT reported %d times, want 1", n)
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	fs := flag.NewFlagSet("bot", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+
+	if err := fs.Parse([]string{"--config", "bot.yaml", "--print-config", "--bot-mode", "webhook", "--port", "9000"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if !flags.PrintConfig {
+		t.Error("PrintConfig = false, want true")
+	}
+
+	opts := flags.Options()
+	if opts.File != "bot.yaml" {
+		t.Errorf("File = %v, want %v", opts.File, "bot.yaml")
+	}
+	if opts.Flags["BOT_MODE"] != "webhook" {
+		t.Errorf("BOT_MODE flag = %v, want %v", opts.Flags["BOT_MODE"], "webhook")
+	}
+	if opts.Flags["PORT"] != "9000" {
+		t.Errorf("PORT flag = %v, want %v", opts.Flags["PORT"], "9000")
+	}
+	// Flags that were not set must not override other sources
+	if _, ok := opts.Flags["LOG_LEVEL"]; ok {
+		t.Error("Unset LOG_LEVEL flag should not be in options")
+	}
+
+	// Secrets are not accepted on the command line
+	if fs.Lookup("telegram-bot-token") != nil {
+		t.Error("Secret settings should not be registered as flags")
+	}
+}
+
+func TestConfig_Print(t *testing.T) {
+	cfg := &Config{
+		TelegramBotToken: "123456:secret-token",
+		BotMode:          "polling",
+		DBType:           "memory",
+		ShutdownTimeout:  5 * time.Second,
+	}
+
+	var buf bytes.Buffer
+	if err := cfg.Print(&buf); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+
+	output := buf.String()
+	if strings.Contains(output, "secret-token") {
+		t.Errorf("Printed config contains a secret:\n%s", output)
+	}
+	for _, want := range []string{
+		"bot_token: '[REDACTED]'",
+		"mode: polling",
+		"shutdown_timeout: 5s",
+		`webhook_secret: ""`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Printed config does not contain %q:\n%s", want, output)
+		}
+	}
+
+	// The printed config can be loaded back as a config file
+	path := writeConfigFile(t, "printed.yaml", output)
+	if _, err := readFile(path); err != nil {
+		t.Errorf("Printed config is not a valid config file: %v", err)
+	}
+}
+
+func TestConfig_Secrets(t *testing.T) {
+	cfg := &Config{
+		TelegramBotToken: "token",
+		DBPassword:       "password",
+		BotMode:          "polling",
+	}
+
+	secrets := cfg.Secrets()
+	if len(secrets) != 2 {
+		t.Fatalf("Secrets() = %v, want 2 values", secrets)
+	}
+	for _, s := range secrets {
+		if s == "polling" {
+			t.Error("Secrets() should not include non-secret values")
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/downloader"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/ebook"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/kindle"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/metrics"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/tracing"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
+)
+
+// Delivery targets
+const (
+	// TargetEmail emails the book to the user's e-reader address
+	TargetEmail = "email"
+	// TargetTelegram sends the book back into the chat as a document
+	TargetTelegram = "telegram"
+)
+
+const (
+	// MaxTelegramSize is the largest document a bot may upload to Telegram
+	MaxTelegramSize = 50 * 1024 * 1024
+	// TelegramFormat is sent to Telegram when no format is requested
+	TelegramFormat = "epub"
+)
+
+var (
+	// ErrNoKindleEmail is returned when the user has not set a Kindle email
+	ErrNoKindleEmail = errors.New("user has no Kindle email")
+	// ErrFormatNotAccepted is returned when the user's e-reader does not accept the format
+	ErrFormatNotAccepted = errors.New("format not accepted by the destination")
+	// ErrTooLarge is returned when the book exceeds the destination's size limit
+	ErrTooLarge = errors.New("book too large for the destination")
+	// ErrTargetDisabled is returned for delivery targets that are not enabled
+	ErrTargetDisabled = errors.New("delivery target not enabled")
+)
+
+// Document is a book sent to a Telegram chat
+type Document struct {
+	Name        string
+	ContentType string
+	Data        []byte
+	Caption     string
+	Thumbnail   []byte // JPEG, nil if the book has no cover
+}
+
+// DocumentSender uploads documents to Telegram chats
+type DocumentSender interface {
+	SendDocument(ctx context.Context, chatID int64, doc *Document) error
+}
+
+// Service downloads books and sends them to users' Kindles or Telegram chats
+type Service struct {
+	users      *user.Manager
+	downloader *downloader.Downloader
+	sender     *kindle.Sender
+	telegram   DocumentSender
+	metrics    *metrics.Metrics
+}
+
+// NewService creates a delivery service
+func NewService(users *user.Manager, downloader *downloader.Downloader, sender *kindle.Sender, metrics *metrics.Metrics) *Service {
+	return &Service{
+		users:      users,
+		downloader: downloader,
+		sender:     sender,
+		metrics:    metrics,
+	}
+}
+
+// EnableTelegram enables the Telegram delivery target
+func (s *Service) EnableTelegram(sender DocumentSender) {
+	s.telegram = sender
+}
+
+// Deliver downloads a book in the given format and sends it to target: the
+// user's e-reader email or their Telegram chat. An empty format selects the
+// target's preferred format.
+func (s *Service) Deliver(ctx context.Context, telegramID int64, bookID, format, target string) (err error) {
+	ctx, span := tracing.Start(ctx, "delivery.Deliver", trace.WithAttributes(
+		attribute.Int64("user.telegram_id", telegramID),
+		attribute.String("book.id", bookID),
+		attribute.String("delivery.target", target),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.users.GetUser(ctx, telegramID)
+	if err != nil {
+		s.metrics.DeliveryFailed(target, "user_error")
+		return err
+	}
+
+	var maxSize int64
+	switch target {
+	case TargetEmail:
+		if !u.HasKindleEmail() {
+			s.metrics.DeliveryFailed(target, "no_email")
+			return ErrNoKindleEmail
+		}
+
+		// The address may belong to a destination that has since been disabled
+		dest, err := s.users.Destination(u.KindleEmail)
+		if err != nil {
+			s.metrics.DeliveryFailed(target, "invalid_destination")
+			return err
+		}
+		if format == "" {
+			format = dest.Formats[0]
+		}
+		span.SetAttributes(attribute.String("delivery.destination", dest.Name))
+		if !dest.AllowsFormat(format) {
+			s.metrics.DeliveryFailed(target, "invalid_format")
+			return ErrFormatNotAccepted
+		}
+		maxSize = dest.MaxSize
+	case TargetTelegram:
+		if s.telegram == nil {
+			s.metrics.DeliveryFailed(target, "disabled")
+			return ErrTargetDisabled
+		}
+		if format == "" {
+			format = TelegramFormat
+		}
+		maxSize = MaxTelegramSize
+	default:
+		return fmt.Errorf("unknown delivery target: %s", target)
+	}
+	span.SetAttributes(attribute.String("book.format", format))
+
+	start := time.Now()
+	file, err := s.downloader.Download(ctx, bookID, format)
+	s.metrics.ObserveDownload(format, time.Since(start), err)
+	if err != nil {
+		s.metrics.DeliveryFailed(target, downloadFailureReason(err))
+		return err
+	}
+
+	if file.Size() > maxSize {
+		s.metrics.DeliveryFailed(target, "too_large")
+		return ErrTooLarge
+	}
+
+	if target == TargetTelegram {
+		err = s.telegram.SendDocument(ctx, telegramID, newDocument(ctx, file))
+	} else {
+		err = s.sender.SendToKindle(ctx, u.KindleEmail, kindle.Attachment{
+			Name:        file.Name,
+			ContentType: file.ContentType,
+			Data:        file.Data,
+		})
+	}
+	if err != nil {
+		if errors.Is(err, kindle.ErrAttachmentTooLarge) {
+			s.metrics.DeliveryFailed(target, "too_large")
+		} else {
+			s.metrics.DeliveryFailed(target, "send_error")
+		}
+		return err
+	}
+
+	s.metrics.DeliverySucceeded(target)
+
+	// The book is on its way; a failed counter update must not report failure
+	if err := s.users.RecordBookSent(ctx, telegramID); err != nil {
+		logging.FromContext(ctx).Warn("Failed to record book sent", "error", err)
+	}
+
+	logging.FromContext(ctx).Info("Book delivered", "book_id", bookID, "format", format, "target", target, "size", file.Size())
+
+	return nil
+}
+
+// newDocument prepares a downloaded book for Telegram, captioned with its
+// title and authors and with its cover as thumbnail when they can be read
+func newDocument(ctx context.Context, file *downloader.BookFile) *Document {
+	meta := ebook.Inspect(ctx, file.Format, file.Data)
+
+	thumbnail, err := ebook.Thumbnail(ctx, meta.Cover)
+	var p *logging.PanicError
+	switch {
+	case errors.As(err, &p):
+		// The book is still sent, without a thumbnail
+		logging.LogError(ctx, "Panic while creating thumbnail", err, logging.NewIncidentID())
+	case err != nil && !errors.Is(err, ebook.ErrNoCover):
+		logging.FromContext(ctx).Debug("Failed to create thumbnail", "book_id", file.BookID, "error", err)
+	}
+
+	return &Document{
+		Name:        file.Name,
+		ContentType: file.ContentType,
+		Data:        file.Data,
+		Caption:     meta.Caption(file.Name),
+		Thumbnail:   thumbnail,
+	}
+}
+
+// SendTestDocument emails doc to the user's Kindle, so the user can check
+// that our sender address is whitelisted
+func (s *Service) SendTestDocument(ctx context.Context, telegramID int64, doc kindle.Attachment) (err error) {
+	ctx, span := tracing.Start(ctx, "delivery.SendTestDocument", trace.WithAttributes(
+		attribute.Int64("user.telegram_id", telegramID),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.users.GetUser(ctx, telegramID)
+	if err != nil {
+		return err
+	}
+	if !u.HasKindleEmail() {
+		return ErrNoKindleEmail
+	}
+
+	if err := s.sender.SendToKindle(ctx, u.KindleEmail, doc); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("Test document sent")
+
+	return nil
+}
+
+// downloadFailureReason maps a download error to a metrics reason
+func downloadFailureReason(err error) string {
+	switch {
+	case errors.Is(err, downloader.ErrBookNotFound), errors.Is(err, downloader.ErrInvalidBookID):
+		return "not_found"
+	case errors.Is(err, downloader.ErrUnsupportedFormat):
+		return "invalid_format"
+	case errors.Is(err, downloader.ErrFileTooLarge):
+		return "too_large"
+	default:
+		return "download_error"
+	}
+}
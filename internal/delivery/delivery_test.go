@@ -0,0 +1,318 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/destination"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/downloader"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/kindle"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// fakeEmailClient records sent emails
+type fakeEmailClient struct {
+	sent []*kindle.Email
+}
+
+func (c *fakeEmailClient) Send(ctx context.Context, email *kindle.Email) error {
+	c.sent = append(c.sent, email)
+	return nil
+}
+
+func newTestService(t *testing.T) (*Service, *user.MemoryRepository, *fakeEmailClient) {
+	t.Helper()
+
+	flibusta := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/b/42/epub" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/epub+zip")
+		_, _ = w.Write([]byte("epub-data"))
+	}))
+	t.Cleanup(flibusta.Close)
+
+	repo := user.NewMemoryRepository()
+	email := &fakeEmailClient{}
+	service := NewService(
+		user.NewManager(repo),
+		downloader.NewDownloader(flibusta.URL, flibusta.Client()),
+		kindle.NewSender(email),
+		nil,
+	)
+
+	return service, repo, email
+}
+
+func TestService_Deliver(t *testing.T) {
+	service, repo, email := newTestService(t)
+	ctx := context.Background()
+
+	if err := repo.SaveUser(ctx, &models.User{TelegramID: 1, KindleEmail: "reader@kindle.com"}); err != nil {
+		t.Fatalf("SaveUser() error = %v", err)
+	}
+
+	if err := service.Deliver(ctx, 1, "42", "epub", TargetEmail); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	if len(email.sent) != 1 {
+		t.Fatalf("Sent %d emails, want 1", len(email.sent))
+	}
+	if email.sent[0].To != "reader@kindle.com" {
+		t.Errorf("To = %v, want reader@kindle.com", email.sent[0].To)
+	}
+	if email.sent[0].Attachments[0].Name != "42.epub" {
+		t.Errorf("Attachment = %v, want 42.epub", email.sent[0].Attachments[0].Name)
+	}
+
+	u, _ := repo.GetUser(ctx, 1)
+	if u.BooksSent != 1 {
+		t.Errorf("BooksSent = %d, want 1", u.BooksSent)
+	}
+}
+
+func TestService_Deliver_Errors(t *testing.T) {
+	service, repo, email := newTestService(t)
+	ctx := context.Background()
+
+	if err := repo.SaveUser(ctx, &models.User{TelegramID: 1}); err != nil {
+		t.Fatalf("SaveUser() error = %v", err)
+	}
+	if err := repo.SaveUser(ctx, &models.User{TelegramID: 2, KindleEmail: "reader@kindle.com"}); err != nil {
+		t.Fatalf("SaveUser() error = %v", err)
+	}
+	// Set while PocketBook was enabled
+	if err := repo.SaveUser(ctx, &models.User{TelegramID: 3, KindleEmail: "reader@pbsync.com"}); err != nil {
+		t.Fatalf("SaveUser() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		telegramID int64
+		bookID     string
+		format     string
+		wantErr    error
+	}{
+		{name: "unknown user", telegramID: 99, bookID: "42", format: "epub", wantErr: user.ErrUserNotFound},
+		{name: "no kindle email", telegramID: 1, bookID: "42", format: "epub", wantErr: ErrNoKindleEmail},
+		{name: "missing book", telegramID: 2, bookID: "7", format: "epub", wantErr: downloader.ErrBookNotFound},
+		{name: "format not accepted", telegramID: 2, bookID: "42", format: "fb2", wantErr: ErrFormatNotAccepted},
+		{name: "disabled destination", telegramID: 3, bookID: "42", format: "epub", wantErr: user.ErrInvalidEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.Deliver(ctx, tt.telegramID, tt.bookID, tt.format, TargetEmail)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Deliver() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if len(email.sent) != 0 {
+		t.Errorf("Sent %d emails, want 0", len(email.sent))
+	}
+}
+
+func TestService_SendTestDocument(t *testing.T) {
+	service, repo, email := newTestService(t)
+	ctx := context.Background()
+
+	repo.SaveUser(ctx, &models.User{TelegramID: 1, KindleEmail: "reader@kindle.com"})
+	repo.SaveUser(ctx, &models.User{TelegramID: 2})
+
+	if err := service.SendTestDocument(ctx, 1, kindle.TestDocument("hello")); err != nil {
+		t.Fatalf("SendTestDocument() error = %v", err)
+	}
+	if len(email.sent) != 1 || email.sent[0].To != "reader@kindle.com" {
+		t.Fatalf("sent = %v, want one email to reader@kindle.com", email.sent)
+	}
+	if got := string(email.sent[0].Attachments[0].Data); got != "hello" {
+		t.Errorf("attachment = %q, want hello", got)
+	}
+
+	// Test documents are not books
+	u, _ := repo.GetUser(ctx, 1)
+	if u.BooksSent != 0 {
+		t.Errorf("BooksSent = %d, want 0", u.BooksSent)
+	}
+
+	if err := service.SendTestDocument(ctx, 2, kindle.TestDocument("hello")); !errors.Is(err, ErrNoKindleEmail) {
+		t.Errorf("SendTestDocument() without email error = %v, want ErrNoKindleEmail", err)
+	}
+}
+
+func TestService_Deliver_Destination(t *testing.T) {
+	service, repo, email := newTestService(t)
+	ctx := context.Background()
+
+	small := destination.Destination{Name: "small", Domains: []string{"small.example"}, Formats: []string{"epub"}, MaxSize: 4}
+	service.users.SetDestinations(destination.NewValidator(destination.Kindle, destination.PocketBook, small))
+
+	repo.SaveUser(ctx, &models.User{TelegramID: 1, KindleEmail: "reader@pbsync.com"})
+	repo.SaveUser(ctx, &models.User{TelegramID: 2, KindleEmail: "reader@small.example"})
+
+	// An empty format selects the destination's preferred one
+	if err := service.Deliver(ctx, 1, "42", "", TargetEmail); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+	if len(email.sent) != 1 || email.sent[0].Attachments[0].Name != "42.epub" {
+		t.Fatalf("sent = %v, want 42.epub", email.sent)
+	}
+
+	if err := service.Deliver(ctx, 2, "42", "epub", TargetEmail); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Deliver() error = %v, want ErrTooLarge", err)
+	}
+}
+
+// fakeDocumentSender records documents sent to Telegram chats
+type fakeDocumentSender struct {
+	chatIDs []int64
+	sent    []*Document
+}
+
+func (s *fakeDocumentSender) SendDocument(ctx context.Context, chatID int64, doc *Document) error {
+	s.chatIDs = append(s.chatIDs, chatID)
+	s.sent = append(s.sent, doc)
+	return nil
+}
+
+func TestService_Deliver_Telegram(t *testing.T) {
+	service, repo, email := newTestService(t)
+	ctx := context.Background()
+
+	// No Kindle email is needed for Telegram
+	repo.SaveUser(ctx, &models.User{TelegramID: 1})
+
+	if err := service.Deliver(ctx, 1, "42", "", TargetTelegram); !errors.Is(err, ErrTargetDisabled) {
+		t.Fatalf("Deliver() before EnableTelegram error = %v, want ErrTargetDisabled", err)
+	}
+
+	telegram := &fakeDocumentSender{}
+	service.EnableTelegram(telegram)
+
+	if err := service.Deliver(ctx, 1, "42", "", TargetTelegram); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+	if len(telegram.sent) != 1 || telegram.chatIDs[0] != 1 {
+		t.Fatalf("sent %d documents to %v, want one to chat 1", len(telegram.sent), telegram.chatIDs)
+	}
+	doc := telegram.sent[0]
+	// The fake book has no metadata, so the caption falls back to the file name
+	if doc.Name != "42.epub" || doc.Caption != "42.epub" || doc.Thumbnail != nil {
+		t.Errorf("document = %q, caption %q, thumbnail %d bytes", doc.Name, doc.Caption, len(doc.Thumbnail))
+	}
+	if len(email.sent) != 0 {
+		t.Errorf("sent %d emails, want 0", len(email.sent))
+	}
+
+	u, _ := repo.GetUser(ctx, 1)
+	if u.BooksSent != 1 {
+		t.Errorf("BooksSent = %d, want 1", u.BooksSent)
+	}
+
+	if err := service.Deliver(ctx, 1, "42", "", "fax"); err == nil {
+		t.Error("Deliver() accepted an unknown target")
+	}
+}
+
+func TestQueue(t *testing.T) {
+	service, repo, email := newTestService(t)
+	repo.SaveUser(context.Background(), &models.User{TelegramID: 1, KindleEmail: "reader@kindle.com"})
+
+	queue := NewQueue(service, 2)
+	results := make(chan error, 3)
+	done := func(ctx context.Context, err error) { results <- err }
+
+	if err := queue.Enqueue(Job{TelegramID: 1, BookID: "42", Target: TargetEmail, Done: done}); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if err := queue.Enqueue(Job{TelegramID: 1, BookID: "7", Target: TargetEmail, Done: done}); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if err := queue.Enqueue(Job{TelegramID: 1, BookID: "8", Target: TargetEmail}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Enqueue() on a full queue error = %v, want ErrQueueFull", err)
+	}
+	if queue.Depth() != 2 {
+		t.Errorf("Depth() = %d, want 2", queue.Depth())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		queue.Start(ctx, 1)
+		close(stopped)
+	}()
+
+	if err := <-results; err != nil {
+		t.Errorf("first job error = %v", err)
+	}
+	if err := <-results; !errors.Is(err, downloader.ErrBookNotFound) {
+		t.Errorf("second job error = %v, want ErrBookNotFound", err)
+	}
+	cancel()
+	<-stopped
+
+	if len(email.sent) != 1 || queue.Depth() != 0 {
+		t.Errorf("sent %d emails with %d jobs left, want 1 and 0", len(email.sent), queue.Depth())
+	}
+}
+
+func TestQueue_Panic(t *testing.T) {
+	service, repo, _ := newTestService(t)
+	repo.SaveUser(context.Background(), &models.User{TelegramID: 1, KindleEmail: "reader@kindle.com"})
+
+	queue := NewQueue(service, 2)
+	results := make(chan error, 1)
+	queue.Enqueue(Job{TelegramID: 1, BookID: "42", Target: TargetEmail, Done: func(ctx context.Context, err error) { panic("callback bug") }})
+	queue.Enqueue(Job{TelegramID: 1, BookID: "42", Target: TargetEmail, Done: func(ctx context.Context, err error) { results <- err }})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Start(ctx, 1)
+
+	// The only worker survives the panic and runs the next job
+	select {
+	case err := <-results:
+		if err != nil {
+			t.Errorf("second job error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second job did not run")
+	}
+}
+
+func TestQueue_Shutdown(t *testing.T) {
+	service, repo, email := newTestService(t)
+	repo.SaveUser(context.Background(), &models.User{TelegramID: 1, KindleEmail: "reader@kindle.com"})
+
+	queue := NewQueue(service, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan error, 2)
+
+	// The first job stops the queue while the second one waits
+	queue.Enqueue(Job{TelegramID: 1, BookID: "42", Target: TargetEmail, Done: func(ctx context.Context, err error) {
+		cancel()
+		results <- err
+	}})
+	queue.Enqueue(Job{TelegramID: 1, BookID: "42", Target: TargetEmail, Done: func(ctx context.Context, err error) { results <- err }})
+
+	queue.Start(ctx, 1)
+
+	if err := <-results; err != nil {
+		t.Errorf("first job error = %v", err)
+	}
+	if err := <-results; !errors.Is(err, ErrShutdown) {
+		t.Errorf("waiting job error = %v, want ErrShutdown", err)
+	}
+	if len(email.sent) != 1 || queue.Depth() != 0 {
+		t.Errorf("sent %d emails with %d jobs left, want 1 and 0", len(email.sent), queue.Depth())
+	}
+}
@@ -0,0 +1,123 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+)
+
+var (
+	// ErrQueueFull is returned when the delivery queue cannot take more jobs
+	ErrQueueFull = errors.New("delivery queue is full")
+	// ErrShutdown is reported for jobs still waiting when the queue stops
+	ErrShutdown = errors.New("delivery queue stopped")
+)
+
+// Job is a book delivery run in the background
+type Job struct {
+	TelegramID int64
+	BookID     string
+	Format     string
+	Target     string
+
+	// Done, if set, is called with the delivery result
+	Done func(ctx context.Context, err error)
+}
+
+// Queue runs deliveries in the background with a fixed number of workers
+type Queue struct {
+	service *Service
+	jobs    chan Job
+}
+
+// NewQueue creates a queue holding up to size waiting jobs
+func NewQueue(service *Service, size int) *Queue {
+	return &Queue{
+		service: service,
+		jobs:    make(chan Job, size),
+	}
+}
+
+// Enqueue adds a job without blocking
+func (q *Queue) Enqueue(job Job) error {
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Depth returns the number of jobs waiting for a worker
+func (q *Queue) Depth() int {
+	return len(q.jobs)
+}
+
+// Start runs workers until ctx is cancelled, then waits for the deliveries
+// in progress. Jobs still waiting are reported done with ErrShutdown.
+func (q *Queue) Start(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-q.jobs:
+					ctx := logging.With(ctx, "user_id", job.TelegramID, "book_id", job.BookID)
+					logging.Safely(ctx, "Panic in queued delivery", func() { q.run(ctx, job) })
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	q.drain(ctx)
+}
+
+// drain reports the jobs still waiting as failed, so their users hear back
+func (q *Queue) drain(ctx context.Context) {
+	for {
+		select {
+		case job := <-q.jobs:
+			ctx := logging.With(ctx, "user_id", job.TelegramID, "book_id", job.BookID)
+			logging.Safely(ctx, "Panic in queued delivery", func() { q.drop(ctx, job) })
+		default:
+			return
+		}
+	}
+}
+
+// drop reports a job that will not be delivered
+func (q *Queue) drop(ctx context.Context, job Job) {
+	logging.FromContext(ctx).Warn("Queued delivery dropped on shutdown")
+	if job.Done != nil {
+		job.Done(ctx, ErrShutdown)
+	}
+}
+
+// run delivers one job and reports the result
+func (q *Queue) run(ctx context.Context, job Job) {
+	err := q.deliver(ctx, job)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Queued delivery failed", "error", err)
+	}
+	if job.Done != nil {
+		job.Done(ctx, err)
+	}
+}
+
+// deliver delivers the job's book, turning a panic into an error so the
+// job is still reported done
+func (q *Queue) deliver(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = logging.NewPanicError(r)
+			logging.LogError(ctx, "Panic in queued delivery", err, logging.NewIncidentID())
+		}
+	}()
+	return q.service.Deliver(ctx, job.TelegramID, job.BookID, job.Format, job.Target)
+}
@@ -0,0 +1,144 @@
+// Package destination describes the e-reader email addresses books can be
+// delivered to, with the formats and sizes each accepts.
+package destination
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidAddress is returned for addresses no enabled destination accepts
+var ErrInvalidAddress = errors.New("invalid e-reader email address")
+
+// Destination is a kind of e-reader address books can be emailed to
+type Destination struct {
+	Name    string   // Identifies the destination in configuration
+	Domains []string // Accepted address domains; empty accepts any domain
+	Formats []string // Accepted book formats, most preferred first
+	MaxSize int64    // Largest accepted attachment in bytes
+}
+
+// Built-in destinations
+var (
+	// Kindle is Amazon's Send to Kindle service
+	Kindle = Destination{
+		Name:    "kindle",
+		Domains: []string{"kindle.com", "free.kindle.com", "kindle.cn", "free.kindle.cn"},
+		Formats: []string{"epub", "pdf", "docx", "doc", "rtf", "txt"},
+		MaxSize: 50 * 1024 * 1024,
+	}
+
+	// PocketBook is the Send-to-PocketBook service
+	PocketBook = Destination{
+		Name:    "pocketbook",
+		Domains: []string{"pbsync.com"},
+		Formats: []string{"epub", "fb2", "pdf", "djvu", "mobi", "docx", "doc", "rtf", "txt"},
+		MaxSize: 25 * 1024 * 1024,
+	}
+
+	// Email is any other mailbox, for readers that sync from email
+	Email = Destination{
+		Name:    "email",
+		Formats: []string{"epub", "fb2", "pdf", "djvu", "mobi", "docx", "doc", "rtf", "txt"},
+		MaxSize: 10 * 1024 * 1024,
+	}
+)
+
+// builtin lists the destinations that can be enabled by name
+var builtin = []Destination{Kindle, PocketBook, Email}
+
+// Lookup returns the built-in destination with the given name
+func Lookup(name string) (Destination, bool) {
+	for _, d := range builtin {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Destination{}, false
+}
+
+// AllowsFormat reports whether the destination accepts books in format
+func (d *Destination) AllowsFormat(format string) bool {
+	format = strings.ToLower(format)
+	for _, f := range d.Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the destination lists domain
+func (d *Destination) matches(domain string) bool {
+	for _, accepted := range d.Domains {
+		if domain == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// Validator decides which destination an address belongs to
+type Validator struct {
+	destinations []Destination
+}
+
+// NewValidator creates a validator accepting the given destinations. A
+// destination listing the address's domain is preferred over one accepting
+// any domain.
+func NewValidator(destinations ...Destination) *Validator {
+	return &Validator{destinations: destinations}
+}
+
+// NewValidatorFromNames creates a validator for built-in destinations
+func NewValidatorFromNames(names []string) (*Validator, error) {
+	destinations := make([]Destination, 0, len(names))
+	for _, name := range names {
+		d, ok := Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown destination: %s", name)
+		}
+		destinations = append(destinations, d)
+	}
+	return NewValidator(destinations...), nil
+}
+
+// Validate checks an address and returns it normalized, with its destination.
+// Domains are matched case-insensitively and returned in lower case.
+func (v *Validator) Validate(address string) (string, *Destination, error) {
+	local, domain, ok := strings.Cut(strings.TrimSpace(address), "@")
+	if !ok || local == "" || strings.ContainsAny(local+domain, " \t@") || !validDomain(domain) {
+		return "", nil, ErrInvalidAddress
+	}
+
+	domain = strings.ToLower(domain)
+	address = local + "@" + domain
+
+	for i := range v.destinations {
+		if v.destinations[i].matches(domain) {
+			return address, &v.destinations[i], nil
+		}
+	}
+	for i := range v.destinations {
+		if len(v.destinations[i].Domains) == 0 {
+			return address, &v.destinations[i], nil
+		}
+	}
+
+	return "", nil, ErrInvalidAddress
+}
+
+// validDomain reports whether domain has at least two non-empty labels
+func validDomain(domain string) bool {
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" {
+			return false
+		}
+	}
+	return true
+}
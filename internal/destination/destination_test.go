@@ -0,0 +1,80 @@
+package destination
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidator_Validate(t *testing.T) {
+	kindleOnly := NewValidator(Kindle)
+	all := NewValidator(Email, Kindle, PocketBook) // Domain matches win over Email
+
+	tests := []struct {
+		name      string
+		validator *Validator
+		address   string
+		want      string
+		wantDest  string
+		wantErr   bool
+	}{
+		{name: "kindle", validator: kindleOnly, address: "reader@kindle.com", want: "reader@kindle.com", wantDest: "kindle"},
+		{name: "free kindle", validator: kindleOnly, address: "reader@free.kindle.com", want: "reader@free.kindle.com", wantDest: "kindle"},
+		{name: "china", validator: kindleOnly, address: "reader@kindle.cn", want: "reader@kindle.cn", wantDest: "kindle"},
+		{name: "case insensitive", validator: kindleOnly, address: " Reader@Kindle.COM ", want: "Reader@kindle.com", wantDest: "kindle"},
+		{name: "other domain", validator: kindleOnly, address: "reader@gmail.com", wantErr: true},
+		{name: "lookalike domain", validator: kindleOnly, address: "reader@notkindle.com", wantErr: true},
+		{name: "no local part", validator: kindleOnly, address: "@kindle.com", wantErr: true},
+		{name: "spaces", validator: kindleOnly, address: "my reader@kindle.com", wantErr: true},
+		{name: "no at", validator: kindleOnly, address: "readerkindle.com", wantErr: true},
+		{name: "two ats", validator: all, address: "a@b@example.com", wantErr: true},
+		{name: "no dot", validator: all, address: "reader@localhost", wantErr: true},
+		{name: "empty label", validator: all, address: "reader@example..org", wantErr: true},
+		{name: "pocketbook", validator: all, address: "reader@pbsync.com", want: "reader@pbsync.com", wantDest: "pocketbook"},
+		{name: "kindle before email", validator: all, address: "reader@kindle.com", want: "reader@kindle.com", wantDest: "kindle"},
+		{name: "generic email", validator: all, address: "reader@example.org", want: "reader@example.org", wantDest: "email"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dest, err := tt.validator.Validate(tt.address)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidAddress) {
+					t.Errorf("Validate(%q) error = %v, want ErrInvalidAddress", tt.address, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate(%q) error = %v", tt.address, err)
+			}
+			if got != tt.want || dest.Name != tt.wantDest {
+				t.Errorf("Validate(%q) = %q, %s, want %q, %s", tt.address, got, dest.Name, tt.want, tt.wantDest)
+			}
+		})
+	}
+}
+
+func TestDestination_AllowsFormat(t *testing.T) {
+	if !Kindle.AllowsFormat("EPUB") {
+		t.Error("Kindle does not allow EPUB")
+	}
+	if Kindle.AllowsFormat("fb2") {
+		t.Error("Kindle allows fb2")
+	}
+	if !PocketBook.AllowsFormat("fb2") {
+		t.Error("PocketBook does not allow fb2")
+	}
+}
+
+func TestNewValidatorFromNames(t *testing.T) {
+	v, err := NewValidatorFromNames([]string{"kindle", "pocketbook"})
+	if err != nil {
+		t.Fatalf("NewValidatorFromNames() error = %v", err)
+	}
+	if _, _, err := v.Validate("reader@pbsync.com"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	if _, err := NewValidatorFromNames([]string{"kobo"}); err == nil {
+		t.Error("NewValidatorFromNames() accepted an unknown destination")
+	}
+}
@@ -0,0 +1,155 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/tracing"
+)
+
+// MaxFileSize is the largest book file accepted, matching the Kindle email limit
+const MaxFileSize = 50 * 1024 * 1024
+
+var (
+	// ErrBookNotFound is returned when the book or the requested format does not exist
+	ErrBookNotFound = errors.New("book not found")
+	// ErrFileTooLarge is returned when the book file exceeds MaxFileSize
+	ErrFileTooLarge = errors.New("book file too large")
+	// ErrInvalidBookID is returned for book IDs that are not Flibusta IDs
+	ErrInvalidBookID = errors.New("invalid book ID")
+	// ErrUnsupportedFormat is returned for formats Flibusta does not serve
+	ErrUnsupportedFormat = errors.New("unsupported book format")
+)
+
+// formats lists the download formats served by Flibusta
+var formats = map[string]bool{
+	"fb2":  true,
+	"epub": true,
+	"mobi": true,
+	"pdf":  true,
+	"djvu": true,
+	"doc":  true,
+	"docx": true,
+	"rtf":  true,
+	"txt":  true,
+}
+
+// BookFile is a downloaded book
+type BookFile struct {
+	BookID      string
+	Format      string
+	Name        string // File name suggested by Flibusta
+	ContentType string
+	Data        []byte
+}
+
+// Size returns the file size in bytes
+func (f *BookFile) Size() int64 {
+	return int64(len(f.Data))
+}
+
+// Downloader downloads book files from Flibusta
+type Downloader struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewDownloader creates a downloader for the Flibusta site at baseURL.
+// A nil client uses a client with a 60s timeout.
+func NewDownloader(baseURL string, client *http.Client) *Downloader {
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+
+	return &Downloader{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  client,
+	}
+}
+
+// Download downloads a book in the given format (epub, fb2, mobi, ...)
+func (d *Downloader) Download(ctx context.Context, bookID, format string) (file *BookFile, err error) {
+	ctx, span := tracing.Start(ctx, "downloader.Download",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("book.id", bookID),
+			attribute.String("book.format", format),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := strconv.ParseUint(bookID, 10, 64); err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidBookID, bookID)
+	}
+	format = strings.ToLower(format)
+	if !formats[format] {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/b/%s/%s", d.baseURL, bookID, format), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download request: %w", err)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download book %s: %w", bookID, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrBookNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to download book %s: %s", bookID, resp.Status)
+	}
+
+	// Flibusta answers with an HTML page when the format is not available
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if contentType == "text/html" {
+		return nil, ErrBookNotFound
+	}
+
+	if resp.ContentLength > MaxFileSize {
+		return nil, ErrFileTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read book %s: %w", bookID, err)
+	}
+	if len(data) > MaxFileSize {
+		return nil, ErrFileTooLarge
+	}
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return &BookFile{
+		BookID:      bookID,
+		Format:      format,
+		Name:        fileName(resp.Header.Get("Content-Disposition"), bookID, format),
+		ContentType: contentType,
+		Data:        data,
+	}, nil
+}
+
+// fileName returns the file name from a Content-Disposition header, or {id}.{format}
+func fileName(contentDisposition, bookID, format string) string {
+	if _, params, err := mime.ParseMediaType(contentDisposition); err == nil {
+		if name := params["filename"]; name != "" && !strings.ContainsAny(name, `/\`) {
+			return name
+		}
+	}
+	return bookID + "." + format
+}
@@ -0,0 +1,94 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDownloader_Download(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/b/123/epub":
+			w.Header().Set("Content-Type", "application/epub+zip")
+			w.Header().Set("Content-Disposition", `attachment; filename="Tolstoy_War_and_Peace.epub"`)
+			_, _ = w.Write([]byte("epub-data"))
+		case "/b/123/mobi":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html>not available</html>"))
+		case "/b/456/epub":
+			w.Header().Set("Content-Length", "60000000")
+			w.WriteHeader(http.StatusOK)
+		case "/b/789/epub":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	d := NewDownloader(server.URL+"/", server.Client())
+
+	file, err := d.Download(context.Background(), "123", "EPUB")
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if file.Name != "Tolstoy_War_and_Peace.epub" {
+		t.Errorf("Name = %v, want Tolstoy_War_and_Peace.epub", file.Name)
+	}
+	if file.Format != "epub" {
+		t.Errorf("Format = %v, want epub", file.Format)
+	}
+	if file.ContentType != "application/epub+zip" {
+		t.Errorf("ContentType = %v, want application/epub+zip", file.ContentType)
+	}
+	if !bytes.Equal(file.Data, []byte("epub-data")) || file.Size() != 9 {
+		t.Errorf("Data = %q, want epub-data", file.Data)
+	}
+
+	tests := []struct {
+		name    string
+		bookID  string
+		format  string
+		wantErr error
+	}{
+		{name: "format not available", bookID: "123", format: "mobi", wantErr: ErrBookNotFound},
+		{name: "missing book", bookID: "999", format: "epub", wantErr: ErrBookNotFound},
+		{name: "too large", bookID: "456", format: "epub", wantErr: ErrFileTooLarge},
+		{name: "invalid id", bookID: "../admin", format: "epub", wantErr: ErrInvalidBookID},
+		{name: "unsupported format", bookID: "123", format: "exe", wantErr: ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := d.Download(context.Background(), tt.bookID, tt.format)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Download() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := d.Download(context.Background(), "789", "epub"); err == nil {
+		t.Error("Expected error for server failure, got nil")
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: `attachment; filename="book.fb2.zip"`, want: "book.fb2.zip"},
+		{header: `attachment; filename="../../etc/passwd"`, want: "1.epub"},
+		{header: "", want: "1.epub"},
+	}
+
+	for _, tt := range tests {
+		if got := fileName(tt.header, "1", "epub"); got != tt.want {
+			t.Errorf("fileName(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
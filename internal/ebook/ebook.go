@@ -0,0 +1,221 @@
+// Package ebook reads metadata and covers from downloaded book files.
+// Reading is best effort: files that cannot be parsed yield empty metadata.
+package ebook
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"path"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/tracing"
+)
+
+// maxEntrySize limits how much of a single archive entry is read
+const maxEntrySize = 50 * 1024 * 1024
+
+// Metadata describes a book file
+type Metadata struct {
+	Title   string
+	Authors []string
+	Cover   []byte // Encoded image, nil if the book has none
+}
+
+// Caption returns "Title — Author, Author", falling back to fallback when
+// the title is unknown
+func (m *Metadata) Caption(fallback string) string {
+	if m.Title == "" {
+		return fallback
+	}
+	if len(m.Authors) == 0 {
+		return m.Title
+	}
+	return m.Title + " — " + strings.Join(m.Authors, ", ")
+}
+
+// Inspect reads the metadata of a book in the given format. EPUB and FB2
+// (plain or zipped) are supported; other formats return empty metadata.
+func Inspect(ctx context.Context, format string, data []byte) Metadata {
+	_, span := tracing.Start(ctx, "ebook.Inspect", trace.WithAttributes(
+		attribute.String("book.format", format),
+		attribute.Int("book.size", len(data)),
+	))
+	defer span.End()
+
+	var m Metadata
+	var err error
+	switch strings.ToLower(format) {
+	case "epub":
+		m, err = inspectEPUB(data)
+	case "fb2":
+		m, err = inspectFB2(data)
+	}
+	if err != nil {
+		// Unreadable files still send, just without metadata
+		tracing.RecordError(span, err)
+	}
+	return m
+}
+
+// inspectEPUB reads the OPF package document of an EPUB
+func inspectEPUB(data []byte) (Metadata, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := decodeEntry(zr, "META-INF/container.xml", &container); err != nil || len(container.Rootfiles) == 0 {
+		return Metadata{}, err
+	}
+	opfPath := container.Rootfiles[0].FullPath
+
+	var pkg struct {
+		Titles   []string `xml:"metadata>title"`
+		Creators []string `xml:"metadata>creator"`
+		Metas    []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"metadata>meta"`
+		Items []struct {
+			ID         string `xml:"id,attr"`
+			Href       string `xml:"href,attr"`
+			MediaType  string `xml:"media-type,attr"`
+			Properties string `xml:"properties,attr"`
+		} `xml:"manifest>item"`
+	}
+	if err := decodeEntry(zr, opfPath, &pkg); err != nil {
+		return Metadata{}, err
+	}
+
+	m := Metadata{}
+	if len(pkg.Titles) > 0 {
+		m.Title = strings.TrimSpace(pkg.Titles[0])
+	}
+	for _, c := range pkg.Creators {
+		if c = strings.TrimSpace(c); c != "" {
+			m.Authors = append(m.Authors, c)
+		}
+	}
+
+	// EPUB 3 marks the cover in the manifest, EPUB 2 in a meta element
+	coverID := ""
+	for _, meta := range pkg.Metas {
+		if meta.Name == "cover" {
+			coverID = meta.Content
+		}
+	}
+	coverHref := ""
+	for _, item := range pkg.Items {
+		if !strings.HasPrefix(item.MediaType, "image/") {
+			continue
+		}
+		if strings.Contains(" "+item.Properties+" ", " cover-image ") {
+			coverHref = item.Href
+			break
+		}
+		if item.ID == coverID {
+			coverHref = item.Href
+		}
+	}
+	if coverHref != "" {
+		m.Cover, _ = readEntry(zr, path.Join(path.Dir(opfPath), coverHref))
+	}
+
+	return m, nil
+}
+
+// inspectFB2 reads the description of a FictionBook file
+func inspectFB2(data []byte) (Metadata, error) {
+	// Flibusta usually serves FB2 zipped
+	if bytes.HasPrefix(data, []byte("PK")) {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return Metadata{}, err
+		}
+		for _, f := range zr.File {
+			if strings.HasSuffix(strings.ToLower(f.Name), ".fb2") {
+				if data, err = readEntry(zr, f.Name); err != nil {
+					return Metadata{}, err
+				}
+				break
+			}
+		}
+	}
+
+	var book struct {
+		Title   string `xml:"description>title-info>book-title"`
+		Authors []struct {
+			FirstName  string `xml:"first-name"`
+			MiddleName string `xml:"middle-name"`
+			LastName   string `xml:"last-name"`
+			Nickname   string `xml:"nickname"`
+		} `xml:"description>title-info>author"`
+		Cover struct {
+			Href string `xml:"href,attr"`
+		} `xml:"description>title-info>coverpage>image"`
+		Binaries []struct {
+			ID   string `xml:"id,attr"`
+			Data string `xml:",chardata"`
+		} `xml:"binary"`
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = charsetReader
+	if err := dec.Decode(&book); err != nil {
+		return Metadata{}, err
+	}
+
+	m := Metadata{Title: strings.TrimSpace(book.Title)}
+	for _, a := range book.Authors {
+		name := strings.Join(strings.Fields(a.FirstName+" "+a.MiddleName+" "+a.LastName), " ")
+		if name == "" {
+			name = strings.TrimSpace(a.Nickname)
+		}
+		if name != "" {
+			m.Authors = append(m.Authors, name)
+		}
+	}
+
+	coverID := strings.TrimPrefix(book.Cover.Href, "#")
+	for _, b := range book.Binaries {
+		if coverID != "" && b.ID == coverID {
+			m.Cover, _ = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(b.Data), ""))
+			break
+		}
+	}
+
+	return m, nil
+}
+
+// decodeEntry decodes the XML archive entry name into v
+func decodeEntry(zr *zip.Reader, name string, v interface{}) error {
+	data, err := readEntry(zr, name)
+	if err != nil {
+		return err
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = charsetReader
+	return dec.Decode(v)
+}
+
+// readEntry returns the contents of the archive entry name
+func readEntry(zr *zip.Reader, name string) ([]byte, error) {
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(io.LimitReader(f, maxEntrySize))
+}
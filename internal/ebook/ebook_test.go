@@ -0,0 +1,212 @@
+package ebook
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// testCover returns a PNG of the given size
+func testCover(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+// zipFiles returns an archive of the given files
+func zipFiles(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%s) error = %v", name, err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestInspect_EPUB(t *testing.T) {
+	cover := testCover(t, 10, 10)
+	container := `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+
+	tests := []struct {
+		name string
+		opf  string
+	}{
+		{name: "epub2", opf: `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" xmlns:dc="http://purl.org/dc/elements/1.1/" version="2.0">
+  <metadata>
+    <dc:title>Мастер и Маргарита</dc:title>
+    <dc:creator>Михаил Булгаков</dc:creator>
+    <meta name="cover" content="cover-img"/>
+  </metadata>
+  <manifest>
+    <item id="text" href="text.xhtml" media-type="application/xhtml+xml"/>
+    <item id="cover-img" href="images/cover.png" media-type="image/png"/>
+  </manifest>
+</package>`},
+		{name: "epub3", opf: `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" xmlns:dc="http://purl.org/dc/elements/1.1/" version="3.0">
+  <metadata>
+    <dc:title>Мастер и Маргарита</dc:title>
+    <dc:creator>Михаил Булгаков</dc:creator>
+  </metadata>
+  <manifest>
+    <item id="img" href="images/cover.png" media-type="image/png" properties="cover-image"/>
+  </manifest>
+</package>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := zipFiles(t, map[string][]byte{
+				"META-INF/container.xml": []byte(container),
+				"OEBPS/content.opf":      []byte(tt.opf),
+				"OEBPS/images/cover.png": cover,
+			})
+
+			m := Inspect(context.Background(), "epub", data)
+			if got := m.Caption("fallback"); got != "Мастер и Маргарита — Михаил Булгаков" {
+				t.Errorf("Caption() = %q", got)
+			}
+			if !bytes.Equal(m.Cover, cover) {
+				t.Errorf("Cover = %d bytes, want %d", len(m.Cover), len(cover))
+			}
+		})
+	}
+}
+
+func TestInspect_FB2(t *testing.T) {
+	cover := testCover(t, 10, 10)
+	fb2 := `<?xml version="1.0" encoding="utf-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description><title-info>
+    <author><first-name>Лев</first-name><middle-name>Николаевич</middle-name><last-name>Толстой</last-name></author>
+    <author><nickname>Редактор</nickname></author>
+    <book-title>Война и мир</book-title>
+    <coverpage><image l:href="#cover.png"/></coverpage>
+  </title-info></description>
+  <body><p>Text</p></body>
+  <binary id="other.png" content-type="image/png">AAAA</binary>
+  <binary id="cover.png" content-type="image/png">` + base64.StdEncoding.EncodeToString(cover) + `</binary>
+</FictionBook>`
+	want := "Война и мир — Лев Николаевич Толстой, Редактор"
+
+	t.Run("plain", func(t *testing.T) {
+		m := Inspect(context.Background(), "fb2", []byte(fb2))
+		if got := m.Caption(""); got != want {
+			t.Errorf("Caption() = %q, want %q", got, want)
+		}
+		if !bytes.Equal(m.Cover, cover) {
+			t.Errorf("Cover = %d bytes, want %d", len(m.Cover), len(cover))
+		}
+	})
+
+	t.Run("zipped", func(t *testing.T) {
+		m := Inspect(context.Background(), "fb2", zipFiles(t, map[string][]byte{"book.fb2": []byte(fb2)}))
+		if got := m.Caption(""); got != want {
+			t.Errorf("Caption() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("windows-1251", func(t *testing.T) {
+		// "Война" in windows-1251
+		title := []byte{0xC2, 0xEE, 0xE9, 0xED, 0xE0}
+		data := []byte(`<?xml version="1.0" encoding="windows-1251"?><FictionBook><description><title-info><book-title>` +
+			string(title) + `</book-title></title-info></description></FictionBook>`)
+		if got := Inspect(context.Background(), "fb2", data).Title; got != "Война" {
+			t.Errorf("Title = %q, want Война", got)
+		}
+	})
+}
+
+func TestInspect_Unreadable(t *testing.T) {
+	tests := []struct {
+		format string
+		data   []byte
+	}{
+		{"epub", []byte("not a zip")},
+		{"fb2", []byte("<FictionBook")},
+		{"pdf", []byte("%PDF-1.4")},
+	}
+
+	for _, tt := range tests {
+		m := Inspect(context.Background(), tt.format, tt.data)
+		if m.Title != "" || m.Cover != nil {
+			t.Errorf("Inspect(%s) = %+v, want empty metadata", tt.format, m)
+		}
+		if got := m.Caption("42.epub"); got != "42.epub" {
+			t.Errorf("Caption() = %q, want fallback", got)
+		}
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	thumb, err := Thumbnail(context.Background(), testCover(t, 600, 900))
+	if err != nil {
+		t.Fatalf("Thumbnail() error = %v", err)
+	}
+	if len(thumb) > thumbnailMaxSize {
+		t.Errorf("thumbnail is %d bytes, want at most %d", len(thumb), thumbnailMaxSize)
+	}
+
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatalf("thumbnail is not a JPEG: %v", err)
+	}
+	if cfg.Width != 213 || cfg.Height != 320 {
+		t.Errorf("thumbnail is %dx%d, want 213x320", cfg.Width, cfg.Height)
+	}
+
+	if _, err := Thumbnail(context.Background(), nil); !errors.Is(err, ErrNoCover) {
+		t.Errorf("Thumbnail(nil) error = %v, want ErrNoCover", err)
+	}
+	if _, err := Thumbnail(context.Background(), []byte("not an image")); err == nil || !strings.Contains(err.Error(), "decode") {
+		t.Errorf("Thumbnail(garbage) error = %v, want decode error", err)
+	}
+}
+
+func TestThumbnail_RejectsHugeCover(t *testing.T) {
+	// A tiny GIF whose header claims a 60000x60000 image
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black}), nil); err != nil {
+		t.Fatal(err)
+	}
+	cover := buf.Bytes()
+	binary.LittleEndian.PutUint16(cover[6:], 60000)
+	binary.LittleEndian.PutUint16(cover[8:], 60000)
+
+	if _, err := Thumbnail(context.Background(), cover); !errors.Is(err, ErrCoverTooLarge) {
+		t.Errorf("Thumbnail(huge) error = %v, want ErrCoverTooLarge", err)
+	}
+}
@@ -0,0 +1,118 @@
+package ebook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Covers may be in any of these formats
+	"image/jpeg"
+	_ "image/png"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/tracing"
+)
+
+// Telegram requires document thumbnails to be JPEG, at most 320px on each
+// side and under 200 kB
+const (
+	thumbnailMaxSide = 320
+	thumbnailMaxSize = 200 * 1024
+)
+
+// coverMaxPixels bounds the covers decoded, as a small file can declare an
+// image that takes gigabytes of memory once decoded
+const coverMaxPixels = 4096 * 4096
+
+var (
+	// ErrNoCover is returned when a thumbnail is requested for a book without cover
+	ErrNoCover = errors.New("book has no cover")
+	// ErrCoverTooLarge is returned for covers with more than coverMaxPixels
+	ErrCoverTooLarge = errors.New("cover image too large")
+)
+
+// Thumbnail scales a cover image down into a JPEG suitable as a Telegram
+// document thumbnail. Image decoders panicking on a malformed cover are
+// reported as a logging.PanicError.
+func Thumbnail(ctx context.Context, cover []byte) (thumb []byte, err error) {
+	if len(cover) == 0 {
+		return nil, ErrNoCover
+	}
+
+	_, span := tracing.Start(ctx, "ebook.Thumbnail", trace.WithAttributes(attribute.Int("cover.size", len(cover))))
+	defer func() {
+		if r := recover(); r != nil {
+			thumb, err = nil, logging.NewPanicError(r)
+		}
+		tracing.End(span, err)
+	}()
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(cover))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cover: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > coverMaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrCoverTooLarge, cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(cover))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cover: %w", err)
+	}
+	img = scaleDown(img, thumbnailMaxSide)
+
+	for quality := 85; quality >= 25; quality -= 15 {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+		}
+		if buf.Len() <= thumbnailMaxSize {
+			return buf.Bytes(), nil
+		}
+	}
+
+	return nil, errors.New("thumbnail too large")
+}
+
+// scaleDown fits img into a maxSide square by averaging source pixels
+func scaleDown(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+
+	dw, dh := maxSide, h*maxSide/w
+	if h > w {
+		dw, dh = w*maxSide/h, maxSide
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, bl, a = r+pr, g+pg, bl+pb, a+pa
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,143 @@
+// Package format prepares text for Telegram messages: it escapes values for
+// the HTML and MarkdownV2 parse modes and splits text that is too long for a
+// single message.
+package format
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Parse modes understood by Telegram
+const (
+	ModeHTML       = "HTML"
+	ModeMarkdownV2 = "MarkdownV2"
+)
+
+// MaxMessageLength is the longest message text Telegram accepts, in UTF-16
+// code units
+const MaxMessageLength = 4096
+
+// maxEntityLength bounds the HTML entities Split keeps whole, such as &amp;
+const maxEntityLength = 10
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// htmlTag matches the tags of HTML messages
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// markdownV2Special lists the characters MarkdownV2 reserves for markup
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+// HTML escapes s so Telegram shows it literally in HTML messages
+func HTML(s string) string {
+	return htmlEscaper.Replace(s)
+}
+
+// PlainText turns HTML message text into plain text, for places that
+// show no formatting such as the command menu
+func PlainText(s string) string {
+	return html.UnescapeString(htmlTag.ReplaceAllString(s, ""))
+}
+
+// MarkdownV2 escapes s so Telegram shows it literally in MarkdownV2 messages
+func MarkdownV2(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if strings.ContainsRune(markdownV2Special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Escape escapes s for the given parse mode. Text without a parse mode is
+// shown as is and returned unchanged.
+func Escape(mode, s string) string {
+	switch mode {
+	case ModeHTML:
+		return HTML(s)
+	case ModeMarkdownV2:
+		return MarkdownV2(s)
+	default:
+		return s
+	}
+}
+
+// Length returns the length of s as Telegram counts it, in UTF-16 code units
+func Length(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeLength(r)
+	}
+	return n
+}
+
+// runeLength returns the number of UTF-16 code units encoding r
+func runeLength(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// Split breaks text into parts of at most limit UTF-16 code units. It
+// breaks between paragraphs, lines or words where it can, and otherwise
+// between characters outside HTML entities. Markup must not span lines, as
+// tags are not closed and reopened around a break.
+func Split(text string, limit int) []string {
+	var parts []string
+	for limit > 0 && Length(text) > limit {
+		head := text[:prefixLength(text, limit)]
+		if head == "" {
+			// A limit below one character still has to make progress
+			_, size := utf8.DecodeRuneInString(text)
+			head = text[:size]
+		}
+
+		cut, next := splitPoint(head)
+		if cut <= 0 {
+			// No separator to break at, so break inside the word
+			cut = len(head)
+			if amp := strings.LastIndexByte(head, '&'); amp > 0 && len(head)-amp <= maxEntityLength && !strings.Contains(head[amp:], ";") {
+				cut = amp
+			}
+			next = cut
+		}
+
+		parts = append(parts, text[:cut])
+		text = text[next:]
+	}
+	if text == "" && len(parts) > 0 {
+		return parts
+	}
+	return append(parts, text)
+}
+
+// splitPoint returns where to end a part within head and where the next
+// part starts, skipping the separator between them
+func splitPoint(head string) (cut, next int) {
+	for _, sep := range []string{"\n\n", "\n", " "} {
+		if i := strings.LastIndex(head, sep); i > 0 {
+			return i, i + len(sep)
+		}
+	}
+	return -1, -1
+}
+
+// prefixLength returns the length in bytes of the longest prefix of s that
+// is at most limit UTF-16 code units long
+func prefixLength(s string, limit int) int {
+	n := 0
+	for i, r := range s {
+		n += runeLength(r)
+		if n > limit {
+			return i
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,110 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+		in   string
+		want string
+	}{
+		{name: "HTML", mode: ModeHTML, in: `<b>Tom & "Jerry"</b>`, want: `&lt;b&gt;Tom &amp; "Jerry"&lt;/b&gt;`},
+		{name: "HTML plain", mode: ModeHTML, in: "Война и мир", want: "Война и мир"},
+		{name: "MarkdownV2", mode: ModeMarkdownV2, in: "*Hi* [a](b) 1.5-2!", want: `\*Hi\* \[a\]\(b\) 1\.5\-2\!`},
+		{name: "MarkdownV2 backslash", mode: ModeMarkdownV2, in: `a\b_c`, want: `a\\b\_c`},
+		{name: "no parse mode", mode: "", in: "<b>*x*</b>", want: "<b>*x*</b>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Escape(tt.mode, tt.in); got != tt.want {
+				t.Errorf("Escape(%q, %q) = %q, want %q", tt.mode, tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "Help &amp; <i>tips</i>", want: "Help & tips"},
+		{in: `<a href="https://example.com">Site</a> &lt;3`, want: "Site <3"},
+		{in: "Война и мир", want: "Война и мир"},
+	}
+
+	for _, tt := range tests {
+		if got := PlainText(tt.in); got != tt.want {
+			t.Errorf("PlainText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{in: "", want: 0},
+		{in: "hello", want: 5},
+		{in: "привет", want: 6},
+		{in: "📚 book", want: 7},
+	}
+
+	for _, tt := range tests {
+		if got := Length(tt.in); got != tt.want {
+			t.Errorf("Length(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{name: "fits", text: "short text", limit: 20, want: []string{"short text"}},
+		{name: "empty", text: "", limit: 20, want: []string{""}},
+		{name: "paragraphs", text: "first one\n\nsecond\nthird", limit: 20, want: []string{"first one", "second\nthird"}},
+		{name: "lines", text: "line one\nline two\nline three", limit: 18, want: []string{"line one\nline two", "line three"}},
+		{name: "words", text: "one two three four", limit: 9, want: []string{"one two", "three", "four"}},
+		{name: "long word", text: "abcdefghij", limit: 4, want: []string{"abcd", "efgh", "ij"}},
+		{name: "entity kept whole", text: "abc&amp;def", limit: 6, want: []string{"abc", "&amp;d", "ef"}},
+		{name: "surrogate pairs", text: "📚📚📚", limit: 4, want: []string{"📚📚", "📚"}},
+		{name: "limit below a character", text: "📚📚", limit: 1, want: []string{"📚", "📚"}},
+		{name: "no limit", text: "anything", limit: 0, want: []string{"anything"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Split(tt.text, tt.limit); !slices.Equal(got, tt.want) {
+				t.Errorf("Split(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplit_MessageLength(t *testing.T) {
+	text := strings.Repeat("📖 Война и мир — Лев Толстой\n", 400)
+
+	parts := Split(text, MaxMessageLength)
+	if len(parts) < 2 {
+		t.Fatalf("Split() returned %d part(s), want several", len(parts))
+	}
+	for n, part := range parts {
+		if Length(part) > MaxMessageLength {
+			t.Errorf("part %d is %d long", n, Length(part))
+		}
+	}
+	if joined := strings.Join(parts, "\n"); joined != strings.TrimSuffix(text, "\n") && joined != text {
+		t.Error("parts do not add up to the text")
+	}
+}
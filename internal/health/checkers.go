@@ -0,0 +1,149 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// HTTPChecker checks that an HTTP endpoint is reachable. Any response below
+// 500 counts as reachable: the check is about connectivity, not authorization.
+type HTTPChecker struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+// NewHTTPChecker creates a reachability checker for url. A nil client uses http.DefaultClient.
+func NewHTTPChecker(name, url string, client *http.Client) *HTTPChecker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPChecker{
+		name:   name,
+		url:    url,
+		client: client,
+	}
+}
+
+// Name identifies the check in reports
+func (c *HTTPChecker) Name() string {
+	return c.name
+}
+
+// Check sends a HEAD request to the endpoint
+func (c *HTTPChecker) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return withoutURL(err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// TelegramChecker checks that the Bot API accepts the bot's token
+type TelegramChecker struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+// NewTelegramChecker creates a checker calling the getMe method at url,
+// which contains the bot token. A nil client uses http.DefaultClient.
+func NewTelegramChecker(name, url string, client *http.Client) *TelegramChecker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &TelegramChecker{
+		name:   name,
+		url:    url,
+		client: client,
+	}
+}
+
+// Name identifies the check in reports
+func (c *TelegramChecker) Name() string {
+	return c.name
+}
+
+// Check calls getMe, bounded by ctx unlike the Bot API client. Errors never
+// contain the URL, so the token does not end up in reports.
+func (c *TelegramChecker) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return errors.New("failed to create request")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return withoutURL(err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if !result.OK {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, result.Description)
+	}
+
+	return nil
+}
+
+// withoutURL strips the request URL from an HTTP client error, as URLs can
+// contain credentials
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s failed: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
+}
+
+// QueueDepthChecker fails when a queue holds more than max items
+type QueueDepthChecker struct {
+	name  string
+	depth func() int
+	max   int
+}
+
+// NewQueueDepthChecker creates a checker reading the current depth from depth
+func NewQueueDepthChecker(name string, depth func() int, max int) *QueueDepthChecker {
+	return &QueueDepthChecker{
+		name:  name,
+		depth: depth,
+		max:   max,
+	}
+}
+
+// Name identifies the check in reports
+func (c *QueueDepthChecker) Name() string {
+	return c.name
+}
+
+// Check compares the current depth with the limit
+func (c *QueueDepthChecker) Check(_ context.Context) error {
+	if depth := c.depth(); depth > c.max {
+		return fmt.Errorf("queue depth %d exceeds limit %d", depth, c.max)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPChecker(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "redirect", status: http.StatusFound},
+		{name: "forbidden is reachable", status: http.StatusForbidden},
+		{name: "server error", status: http.StatusBadGateway, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodHead {
+					t.Errorf("Method = %v, want HEAD", r.Method)
+				}
+				if tt.status == http.StatusFound {
+					// Redirect to a path that answers 200
+					if r.URL.Path == "/" {
+						http.Redirect(w, r, "/home", tt.status)
+						return
+					}
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			checker := NewHTTPChecker("flibusta", server.URL+"/", server.Client())
+			if checker.Name() != "flibusta" {
+				t.Errorf("Name() = %v, want flibusta", checker.Name())
+			}
+
+			err := checker.Check(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHTTPChecker_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	if err := NewHTTPChecker("flibusta", url, nil).Check(context.Background()); err == nil {
+		t.Error("Expected error for unreachable endpoint, got nil")
+	}
+}
+
+func TestTelegramChecker(t *testing.T) {
+	const token = "123:secret"
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		delay   time.Duration
+		wantErr bool
+	}{
+		{name: "ok", status: http.StatusOK, body: `{"ok":true,"result":{"id":1}}`},
+		{name: "revoked token", status: http.StatusUnauthorized, body: `{"ok":false,"description":"Unauthorized"}`, wantErr: true},
+		{name: "not the Bot API", status: http.StatusBadGateway, body: "<html>", wantErr: true},
+		{name: "hangs", status: http.StatusOK, body: `{"ok":true}`, delay: time.Second, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+					return
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			err := NewTelegramChecker("telegram", server.URL+"/bot"+token+"/getMe", server.Client()).Check(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && strings.Contains(err.Error(), token) {
+				t.Errorf("Check() error = %v, contains the token", err)
+			}
+		})
+	}
+}
+
+func TestTelegramChecker_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL + "/bot123:secret/getMe"
+	server.Close()
+
+	err := NewTelegramChecker("telegram", url, nil).Check(context.Background())
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("Check() error = %v, want an error without the URL", err)
+	}
+}
+
+func TestQueueDepthChecker(t *testing.T) {
+	depth := 5
+	checker := NewQueueDepthChecker("delivery_queue", func() int { return depth }, 10)
+
+	if err := checker.Check(context.Background()); err != nil {
+		t.Errorf("Check() error = %v, want nil", err)
+	}
+
+	depth = 11
+	if err := checker.Check(context.Background()); err == nil {
+		t.Error("Expected error when depth exceeds limit, got nil")
+	}
+}
@@ -0,0 +1,206 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+)
+
+// Status values reported by checks and the overall report
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded" // A non-critical check failed
+	StatusFail     = "fail"
+)
+
+// Checker checks a single dependency
+type Checker interface {
+	// Name identifies the check in reports
+	Name() string
+
+	// Check returns an error if the dependency is unhealthy
+	Check(ctx context.Context) error
+}
+
+// checkerFunc adapts a function to the Checker interface
+type checkerFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// NewChecker creates a Checker from a function
+func NewChecker(name string, fn func(ctx context.Context) error) Checker {
+	return &checkerFunc{name: name, fn: fn}
+}
+
+func (c *checkerFunc) Name() string                    { return c.name }
+func (c *checkerFunc) Check(ctx context.Context) error { return c.fn(ctx) }
+
+// Result is the outcome of a single check
+type Result struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Critical   bool   `json:"critical"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Report is the outcome of all registered checks
+type Report struct {
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+	Checks    []Result  `json:"checks"`
+}
+
+type registration struct {
+	checker  Checker
+	critical bool
+}
+
+// Registry runs registered checkers and serves the probe endpoints
+type Registry struct {
+	timeout  time.Duration
+	started  time.Time
+	redact   func(string) string
+	mu       sync.RWMutex
+	checkers []registration
+}
+
+// NewRegistry creates a registry whose checks are each limited to timeout
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{
+		timeout: timeout,
+		started: time.Now(),
+	}
+}
+
+// EnableRedaction passes check errors through redact before they are
+// reported, as reports are served without authentication.
+func (r *Registry) EnableRedaction(redact func(string) string) {
+	r.redact = redact
+}
+
+// Register adds a critical checker; its failure makes the service not ready
+func (r *Registry) Register(c Checker) {
+	r.add(c, true)
+}
+
+// RegisterNonCritical adds a checker whose failure only degrades the report
+func (r *Registry) RegisterNonCritical(c Checker) {
+	r.add(c, false)
+}
+
+func (r *Registry) add(c Checker, critical bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkers = append(r.checkers, registration{checker: c, critical: critical})
+}
+
+// Run executes all checks concurrently and returns the combined report
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checkers := make([]registration, len(r.checkers))
+	copy(checkers, r.checkers)
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checkers))
+	var wg sync.WaitGroup
+	for i, reg := range checkers {
+		wg.Add(1)
+		go func(i int, reg registration) {
+			defer wg.Done()
+			results[i] = r.runCheck(ctx, reg)
+		}(i, reg)
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:    StatusOK,
+		Timestamp: time.Now().UTC(),
+		Checks:    results,
+	}
+	for _, result := range results {
+		if result.Status == StatusOK {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusFail
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+
+	return report
+}
+
+// runCheck runs one checker with the registry timeout
+func (r *Registry) runCheck(ctx context.Context, reg registration) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx, reg.checker)
+
+	result := Result{
+		Name:       reg.checker.Name(),
+		Status:     StatusOK,
+		Critical:   reg.critical,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+		if r.redact != nil {
+			result.Error = r.redact(result.Error)
+		}
+	}
+
+	return result
+}
+
+// check runs checker, turning a panic into a failed check so it cannot
+// crash the bot from a probe
+func check(ctx context.Context, checker Checker) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = logging.NewPanicError(r)
+			logging.LogError(ctx, "Panic in health check", err, logging.NewIncidentID())
+		}
+	}()
+	return checker.Check(ctx)
+}
+
+// LivenessHandler serves /livez: the process is up and able to serve HTTP
+func (r *Registry) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":         StatusOK,
+			"uptime_seconds": int64(time.Since(r.started).Seconds()),
+		})
+	})
+}
+
+// ReadinessHandler serves /readyz: 200 unless a critical check fails
+func (r *Registry) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Run(req.Context())
+
+		status := http.StatusOK
+		if report.Status == StatusFail {
+			status = http.StatusServiceUnavailable
+		}
+
+		writeJSON(w, status, report)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,159 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ok(name string) Checker {
+	return NewChecker(name, func(context.Context) error { return nil })
+}
+
+func failing(name string) Checker {
+	return NewChecker(name, func(context.Context) error { return errors.New(name + " unreachable") })
+}
+
+func TestRegistry_Run(t *testing.T) {
+	tests := []struct {
+		name        string
+		critical    []Checker
+		nonCritical []Checker
+		want        string
+	}{
+		{
+			name:     "all healthy",
+			critical: []Checker{ok("repository"), ok("telegram")},
+			want:     StatusOK,
+		},
+		{
+			name:     "no checks",
+			critical: nil,
+			want:     StatusOK,
+		},
+		{
+			name:        "non-critical failure degrades",
+			critical:    []Checker{ok("repository")},
+			nonCritical: []Checker{failing("flibusta")},
+			want:        StatusDegraded,
+		},
+		{
+			name:        "critical failure fails",
+			critical:    []Checker{failing("repository")},
+			nonCritical: []Checker{failing("flibusta")},
+			want:        StatusFail,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry(time.Second)
+			for _, c := range tt.critical {
+				registry.Register(c)
+			}
+			for _, c := range tt.nonCritical {
+				registry.RegisterNonCritical(c)
+			}
+
+			report := registry.Run(context.Background())
+			if report.Status != tt.want {
+				t.Errorf("Status = %v, want %v", report.Status, tt.want)
+			}
+			if len(report.Checks) != len(tt.critical)+len(tt.nonCritical) {
+				t.Errorf("Checks = %d, want %d", len(report.Checks), len(tt.critical)+len(tt.nonCritical))
+			}
+		})
+	}
+}
+
+func TestRegistry_Timeout(t *testing.T) {
+	registry := NewRegistry(20 * time.Millisecond)
+	registry.Register(NewChecker("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	start := time.Now()
+	report := registry.Run(context.Background())
+
+	if time.Since(start) > time.Second {
+		t.Error("Check was not limited by the registry timeout")
+	}
+	if report.Status != StatusFail {
+		t.Errorf("Status = %v, want %v", report.Status, StatusFail)
+	}
+	if report.Checks[0].Error == "" {
+		t.Error("Timed out check should report an error")
+	}
+}
+
+func TestRegistry_Redaction(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	registry.EnableRedaction(func(s string) string { return strings.ReplaceAll(s, "secret", "[REDACTED]") })
+	registry.Register(NewChecker("repository", func(context.Context) error {
+		return errors.New("failed to connect with password=secret")
+	}))
+
+	report := registry.Run(context.Background())
+	if got := report.Checks[0].Error; got != "failed to connect with password=[REDACTED]" {
+		t.Errorf("Error = %q, want the secret redacted", got)
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	registry.Register(ok("repository"))
+
+	rec := httptest.NewRecorder()
+	registry.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Status code = %v, want %v", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %v, want application/json", ct)
+	}
+
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if report.Checks[0].Name != "repository" {
+		t.Errorf("Check name = %v, want repository", report.Checks[0].Name)
+	}
+
+	// A failing critical check makes the service unready
+	registry.Register(failing("telegram"))
+	rec = httptest.NewRecorder()
+	registry.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Status code = %v, want %v", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestLivenessHandler(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	registry.Register(failing("repository"))
+
+	rec := httptest.NewRecorder()
+	registry.LivenessHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/livez", nil))
+
+	// Liveness does not depend on checks
+	if rec.Code != http.StatusOK {
+		t.Errorf("Status code = %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if body["status"] != StatusOK {
+		t.Errorf("status = %v, want %v", body["status"], StatusOK)
+	}
+}
@@ -1,23 +1,50 @@
 package i18n
 
 import (
+	"embed"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"path"
+	"sort"
 	"strings"
+	"sync"
 )
 
-// I18n provides internationalization support
+// embeddedLocales holds the translations shipped with the binary
+//
+//go:embed locales/*.json
+var embeddedLocales embed.FS
+
+// Params holds named message arguments. A message translated with Params
+// replaces {name} placeholders, so each language can order them freely, and
+// picks its plural form by the "count" parameter.
+type Params map[string]interface{}
+
+// message is a translation, either plain text or a set of plural forms
+type message struct {
+	text  string
+	forms map[string]string // CLDR plural category -> text; nil for plain text
+}
+
+// I18n provides internationalization support. It is safe for concurrent
+// use, including while translations are reloaded.
 type I18n struct {
-	translations map[string]map[string]string // lang -> key -> value
+	mu           sync.RWMutex
+	translations map[string]map[string]message // lang -> key -> message
 	defaultLang  string
+
+	// Where Reload reads translations from
+	embedded bool
+	dir      string
+	strict   bool // Reload rejects translations failing Validate
 }
 
 // New creates a new I18n instance
 func New(defaultLang string) *I18n {
 	return &I18n{
-		translations: make(map[string]map[string]string),
+		translations: make(map[string]map[string]message),
 		defaultLang:  defaultLang,
 	}
 }
@@ -28,9 +55,46 @@ func NewI18n(dir, defaultLang string) (*I18n, error) {
 	if err := i18n.LoadTranslations(dir); err != nil {
 		return nil, err
 	}
+	i18n.dir = dir
+	return i18n, nil
+}
+
+// NewEmbedded creates a new I18n instance with the translations built into
+// the binary. Files in overrideDir, if set, replace individual messages or
+// add languages.
+func NewEmbedded(overrideDir, defaultLang string) (*I18n, error) {
+	i18n := New(defaultLang)
+	if err := i18n.loadFS(embeddedFS()); err != nil {
+		return nil, fmt.Errorf("failed to load embedded translations: %w", err)
+	}
+	if overrideDir != "" {
+		if err := i18n.LoadTranslations(overrideDir); err != nil {
+			return nil, err
+		}
+	}
+	i18n.embedded, i18n.dir = true, overrideDir
 	return i18n, nil
 }
 
+// EmbeddedLanguages returns the languages built into the binary
+func EmbeddedLanguages() []string {
+	files, _ := fs.Glob(embeddedFS(), "*.json")
+	langs := make([]string, 0, len(files))
+	for _, file := range files {
+		langs = append(langs, strings.TrimSuffix(file, ".json"))
+	}
+	return langs
+}
+
+// embeddedFS returns the embedded locales directory
+func embeddedFS() fs.FS {
+	locales, err := fs.Sub(embeddedLocales, "locales")
+	if err != nil {
+		panic(err) // The directory is embedded at build time
+	}
+	return locales
+}
+
 // LoadTranslations loads translation files from a directory
 func (i *I18n) LoadTranslations(dir string) error {
 	// Check if directory exists
@@ -38,16 +102,25 @@ func (i *I18n) LoadTranslations(dir string) error {
 		return fmt.Errorf("directory does not exist: %s", dir)
 	}
 
-	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	return i.loadFS(os.DirFS(dir))
+}
+
+// loadFS loads the translation files at the root of fsys
+func (i *I18n) loadFS(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.json")
 	if err != nil {
 		return fmt.Errorf("failed to list translation files: %w", err)
 	}
 
 	for _, file := range files {
 		// Extract language code from filename (e.g., "en.json" -> "en")
-		lang := strings.TrimSuffix(filepath.Base(file), ".json")
+		lang := strings.TrimSuffix(path.Base(file), ".json")
 
-		if err := i.LoadLanguage(lang, file); err != nil {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("failed to load language %s: failed to read file: %w", lang, err)
+		}
+		if err := i.addMessages(lang, data); err != nil {
 			return fmt.Errorf("failed to load language %s: %w", lang, err)
 		}
 	}
@@ -55,72 +128,230 @@ func (i *I18n) LoadTranslations(dir string) error {
 	return nil
 }
 
-// LoadLanguage loads translations for a specific language
+// LoadLanguage loads translations for a specific language. Messages already
+// loaded for the language are kept unless the file replaces them.
 func (i *I18n) LoadLanguage(lang, path string) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
 
-	var translations map[string]string
-	if err := json.Unmarshal(data, &translations); err != nil {
+	return i.addMessages(lang, data)
+}
+
+// addMessages parses a translation file and adds its messages to lang. A
+// file with an invalid message adds none of them.
+func (i *I18n) addMessages(lang string, data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
 		return fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
+	parsed := make(map[string]message, len(raw))
+	for key, value := range raw {
+		msg, err := parseMessage(value)
+		if err != nil {
+			return fmt.Errorf("invalid message %s: %w", key, err)
+		}
+		parsed[key] = msg
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	translations := i.translations[lang]
+	if translations == nil {
+		translations = make(map[string]message, len(parsed))
+	}
+	for key, msg := range parsed {
+		translations[key] = msg
+	}
+
 	i.translations[lang] = translations
 	return nil
 }
 
-// T translates a key to the specified language
+// parseMessage parses a message, either a string or an object of plural forms
+func parseMessage(data json.RawMessage) (message, error) {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		return message{text: text}, nil
+	}
+
+	var forms map[string]string
+	if err := json.Unmarshal(data, &forms); err != nil {
+		return message{}, fmt.Errorf("must be a string or an object of plural forms")
+	}
+	for category := range forms {
+		if !pluralCategories[category] {
+			return message{}, fmt.Errorf("unknown plural category %q", category)
+		}
+	}
+	if _, ok := forms[PluralOther]; !ok {
+		return message{}, fmt.Errorf("missing plural category %q", PluralOther)
+	}
+
+	return message{forms: forms}, nil
+}
+
+// T translates a key to the specified language. Arguments are either
+// positional fmt verbs or a single Params for named placeholders. Plural
+// messages pick their form by the "count" parameter, or by the first
+// integer among positional arguments.
 func (i *I18n) T(lang, key string, args ...interface{}) string {
-	// Try requested language
-	if template, ok := i.translations[lang][key]; ok {
-		if len(args) > 0 {
-			return fmt.Sprintf(template, args...)
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	msg, ok := i.translations[lang][key]
+	if !ok {
+		// Fallback to default language
+		lang = i.defaultLang
+		msg, ok = i.translations[lang][key]
+	}
+	if !ok {
+		// Return key if translation not found
+		return key
+	}
+
+	if len(args) == 1 {
+		if params, ok := args[0].(Params); ok {
+			count, _ := toInt(params["count"])
+			return formatNamed(msg.form(lang, count), params)
 		}
-		return template
 	}
 
-	// Fallback to default language
-	if template, ok := i.translations[i.defaultLang][key]; ok {
-		if len(args) > 0 {
-			return fmt.Sprintf(template, args...)
+	count := 0
+	for _, arg := range args {
+		if n, ok := toInt(arg); ok {
+			count = n
+			break
 		}
-		return template
 	}
+	template := msg.form(lang, count)
+	if len(args) > 0 {
+		return fmt.Sprintf(template, args...)
+	}
+	return template
+}
 
-	// Return key if translation not found
-	return key
+// form returns the text to use for count
+func (m message) form(lang string, count int) string {
+	if m.forms == nil {
+		return m.text
+	}
+	if form, ok := m.forms[PluralCategory(lang, count)]; ok {
+		return form
+	}
+	return m.forms[PluralOther]
 }
 
-// GetSupportedLanguages returns list of loaded languages
+// formatNamed replaces {name} placeholders with params. Unknown placeholders
+// are left as they are.
+func formatNamed(template string, params Params) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end == -1 {
+			break
+		}
+		end += start
+
+		value, ok := params[template[start+1:end]]
+		if !ok {
+			b.WriteString(template[:start+1])
+			template = template[start+1:]
+			continue
+		}
+		b.WriteString(template[:start])
+		fmt.Fprint(&b, value)
+		template = template[end+1:]
+	}
+	b.WriteString(template)
+	return b.String()
+}
+
+// toInt converts an integer argument to int
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int8:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case uint:
+		return int(n), true
+	case uint8:
+		return int(n), true
+	case uint16:
+		return int(n), true
+	case uint32:
+		return int(n), true
+	case uint64:
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
+
+// GetSupportedLanguages returns the loaded languages, sorted
 func (i *I18n) GetSupportedLanguages() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.languages()
+}
+
+// languages returns the loaded languages, sorted. The caller must hold i.mu.
+func (i *I18n) languages() []string {
 	langs := make([]string, 0, len(i.translations))
 	for lang := range i.translations {
 		langs = append(langs, lang)
 	}
+	sort.Strings(langs)
 	return langs
 }
 
-// DetectLanguage detects user's language from Telegram language code
-func DetectLanguage(telegramLangCode string) string {
-	// Telegram sends language codes like "en", "ru", "en-US", etc.
-	// Extract the base language code
-	lang := strings.ToLower(telegramLangCode)
-	if idx := strings.Index(lang, "-"); idx != -1 {
-		lang = lang[:idx]
-	}
+// DefaultLanguage returns the language used for missing translations
+func (i *I18n) DefaultLanguage() string {
+	return i.defaultLang
+}
 
-	// Check if we support this language
-	supportedLanguages := map[string]bool{
-		"en": true,
-		"ru": true,
-	}
+// IsSupported reports whether translations for lang are loaded
+func (i *I18n) IsSupported(lang string) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	_, ok := i.translations[lang]
+	return ok
+}
 
-	if supportedLanguages[lang] {
+// DetectLanguage returns the loaded language matching a Telegram language
+// code, or the default language
+func (i *I18n) DetectLanguage(telegramLangCode string) string {
+	if lang, ok := MatchLanguage(telegramLangCode, i.GetSupportedLanguages()); ok {
 		return lang
 	}
+	return i.defaultLang
+}
 
-	// Default to English
-	return "en"
+// MatchLanguage returns the base language of a code such as "en" or "en-US"
+// if it is one of languages
+func MatchLanguage(code string, languages []string) (string, bool) {
+	lang, _, _ := strings.Cut(strings.ToLower(code), "-")
+	lang, _, _ = strings.Cut(lang, "_")
+	for _, supported := range languages {
+		if lang == supported {
+			return lang, true
+		}
+	}
+	return "", false
 }
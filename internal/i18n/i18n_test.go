@@ -1,9 +1,13 @@
 package i18n
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewI18n(t *testing.T) {
@@ -154,6 +158,61 @@ func TestI18n_T(t *testing.T) {
 	}
 }
 
+func TestNewEmbedded(t *testing.T) {
+	i18n, err := NewEmbedded("", "en")
+	if err != nil {
+		t.Fatalf("NewEmbedded() error = %v", err)
+	}
+
+	want := []string{"be", "en", "kk", "ru", "uk"}
+	if langs := i18n.GetSupportedLanguages(); strings.Join(langs, ",") != strings.Join(want, ",") {
+		t.Errorf("GetSupportedLanguages() = %v, want %v", langs, want)
+	}
+	if langs := EmbeddedLanguages(); strings.Join(langs, ",") != strings.Join(want, ",") {
+		t.Errorf("EmbeddedLanguages() = %v, want %v", langs, want)
+	}
+	if err := i18n.Validate(); err != nil {
+		t.Errorf("Embedded locales are invalid:\n%v", err)
+	}
+}
+
+func TestNewEmbedded_Override(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Override one English message and add a language
+	os.WriteFile(filepath.Join(tmpDir, "en.json"), []byte(`{"welcome": "Hi there"}`), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "fr.json"), []byte(`{"welcome": "Bienvenue"}`), 0644)
+
+	i18n, err := NewEmbedded(tmpDir, "en")
+	if err != nil {
+		t.Fatalf("NewEmbedded() error = %v", err)
+	}
+
+	tests := []struct {
+		lang     string
+		key      string
+		expected string
+	}{
+		{lang: "en", key: "welcome", expected: "Hi there"},
+		{lang: "en", key: "cancel", expected: "Cancel"},
+		{lang: "ru", key: "cancel", expected: "Отмена"},
+		{lang: "fr", key: "welcome", expected: "Bienvenue"},
+		{lang: "fr", key: "cancel", expected: "Cancel"},
+	}
+	for _, tt := range tests {
+		if result := i18n.T(tt.lang, tt.key); result != tt.expected {
+			t.Errorf("T(%q, %q) = %q, want %q", tt.lang, tt.key, result, tt.expected)
+		}
+	}
+	if !i18n.IsSupported("fr") || i18n.DetectLanguage("fr-FR") != "fr" {
+		t.Error("Override directory did not add fr")
+	}
+
+	if _, err := NewEmbedded("/nonexistent/path", "en"); err == nil {
+		t.Error("Expected error for missing override directory, got nil")
+	}
+}
+
 func TestI18n_DetectLanguage(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -195,11 +254,31 @@ func TestI18n_DetectLanguage(t *testing.T) {
 			langCode: "r",
 			expected: "en",
 		},
+		{
+			name:     "ukrainian",
+			langCode: "uk",
+			expected: "uk",
+		},
+		{
+			name:     "belarusian with region",
+			langCode: "be-BY",
+			expected: "be",
+		},
+		{
+			name:     "kazakh with underscore",
+			langCode: "kk_KZ",
+			expected: "kk",
+		},
+	}
+
+	i18n, err := NewEmbedded("", "en")
+	if err != nil {
+		t.Fatalf("NewEmbedded() error = %v", err)
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := DetectLanguage(tt.langCode)
+			result := i18n.DetectLanguage(tt.langCode)
 			if result != tt.expected {
 				t.Errorf("DetectLanguage(%q) = %v, want %v", tt.langCode, result, tt.expected)
 			}
@@ -255,3 +334,430 @@ func TestI18n_LoadTranslations_MalformedJSON(t *testing.T) {
 		t.Error("Expected error for malformed JSON, got nil")
 	}
 }
+
+func TestI18n_LoadTranslations_InvalidPlural(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "unknown category", content: `{"books": {"one": "%d book", "several": "%d books", "other": "%d books"}}`},
+		{name: "missing other", content: `{"books": {"one": "%d book"}}`},
+		{name: "not a string", content: `{"books": 5}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			os.WriteFile(filepath.Join(tmpDir, "en.json"), []byte(tt.content), 0644)
+
+			if _, err := NewI18n(tmpDir, "en"); err == nil {
+				t.Error("Expected error for invalid plural message, got nil")
+			}
+		})
+	}
+}
+
+func TestI18n_LoadLanguage_InvalidMessage(t *testing.T) {
+	i18n := New("en")
+	path := filepath.Join(t.TempDir(), "en.json")
+	os.WriteFile(path, []byte(`{"a_greeting": "Hello", "b_books": {"one": "%d book"}, "c_farewell": "Bye"}`), 0644)
+
+	if err := i18n.LoadLanguage("en", path); err == nil {
+		t.Fatal("LoadLanguage() error = nil, want the invalid message")
+	}
+	// None of the file's messages are applied, whatever order they parse in
+	for _, key := range []string{"a_greeting", "c_farewell"} {
+		if result := i18n.T("en", key); result != key {
+			t.Errorf("T(%s) = %q, want the key as the file was rejected", key, result)
+		}
+	}
+}
+
+func TestPluralCategory(t *testing.T) {
+	tests := []struct {
+		lang string
+		n    int
+		want string
+	}{
+		{lang: "en", n: 0, want: PluralOther},
+		{lang: "en", n: 1, want: PluralOne},
+		{lang: "en", n: 2, want: PluralOther},
+		{lang: "en-US", n: 1, want: PluralOne},
+		{lang: "fr", n: 1, want: PluralOne},
+		{lang: "ru", n: 1, want: PluralOne},
+		{lang: "ru", n: 21, want: PluralOne},
+		{lang: "ru", n: 2, want: PluralFew},
+		{lang: "ru", n: 24, want: PluralFew},
+		{lang: "ru", n: 0, want: PluralMany},
+		{lang: "ru", n: 5, want: PluralMany},
+		{lang: "ru", n: 11, want: PluralMany},
+		{lang: "ru", n: 12, want: PluralMany},
+		{lang: "ru", n: 111, want: PluralMany},
+		{lang: "ru", n: -3, want: PluralFew},
+		{lang: "uk", n: 22, want: PluralFew},
+		{lang: "be", n: 31, want: PluralOne},
+		{lang: "pl", n: 1, want: PluralOne},
+		{lang: "pl", n: 21, want: PluralMany},
+		{lang: "pl", n: 22, want: PluralFew},
+		{lang: "ar", n: 0, want: PluralZero},
+		{lang: "ar", n: 1, want: PluralOne},
+		{lang: "ar", n: 2, want: PluralTwo},
+		{lang: "ar", n: 3, want: PluralFew},
+		{lang: "ar", n: 110, want: PluralFew},
+		{lang: "ar", n: 11, want: PluralMany},
+		{lang: "ar", n: 99, want: PluralMany},
+		{lang: "ar", n: 100, want: PluralOther},
+		{lang: "ar", n: 102, want: PluralOther},
+		{lang: "ja", n: 1, want: PluralOther},
+	}
+
+	for _, tt := range tests {
+		if got := PluralCategory(tt.lang, tt.n); got != tt.want {
+			t.Errorf("PluralCategory(%q, %d) = %q, want %q", tt.lang, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestI18n_T_Plural(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	enContent := `{
+		"books": {"one": "{count} book", "other": "{count} books"},
+		"positional": {"one": "%d book", "other": "%d books"}
+	}`
+
+	ruContent := `{
+		"books": {"one": "{count} книга", "few": "{count} книги", "many": "{count} книг", "other": "{count} книги"}
+	}`
+
+	arContent := `{
+		"books": {"zero": "zero", "one": "one", "two": "two", "few": "few {count}", "many": "many {count}", "other": "other {count}"}
+	}`
+
+	os.WriteFile(filepath.Join(tmpDir, "en.json"), []byte(enContent), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "ru.json"), []byte(ruContent), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "ar.json"), []byte(arContent), 0644)
+
+	i18n, err := NewI18n(tmpDir, "en")
+	if err != nil {
+		t.Fatalf("Failed to create i18n: %v", err)
+	}
+
+	tests := []struct {
+		lang     string
+		key      string
+		args     []interface{}
+		expected string
+	}{
+		{lang: "en", key: "books", args: []interface{}{Params{"count": 1}}, expected: "1 book"},
+		{lang: "en", key: "books", args: []interface{}{Params{"count": 0}}, expected: "0 books"},
+		{lang: "en", key: "books", args: []interface{}{Params{"count": int64(7)}}, expected: "7 books"},
+		{lang: "en", key: "books", args: nil, expected: "{count} books"},
+		{lang: "en", key: "positional", args: []interface{}{1}, expected: "1 book"},
+		{lang: "en", key: "positional", args: []interface{}{3}, expected: "3 books"},
+		{lang: "ru", key: "books", args: []interface{}{Params{"count": 1}}, expected: "1 книга"},
+		{lang: "ru", key: "books", args: []interface{}{Params{"count": 2}}, expected: "2 книги"},
+		{lang: "ru", key: "books", args: []interface{}{Params{"count": 5}}, expected: "5 книг"},
+		{lang: "ru", key: "books", args: []interface{}{Params{"count": 21}}, expected: "21 книга"},
+		{lang: "ru", key: "books", args: []interface{}{Params{"count": 14}}, expected: "14 книг"},
+		{lang: "ar", key: "books", args: []interface{}{Params{"count": 0}}, expected: "zero"},
+		{lang: "ar", key: "books", args: []interface{}{Params{"count": 1}}, expected: "one"},
+		{lang: "ar", key: "books", args: []interface{}{Params{"count": 2}}, expected: "two"},
+		{lang: "ar", key: "books", args: []interface{}{Params{"count": 3}}, expected: "few 3"},
+		{lang: "ar", key: "books", args: []interface{}{Params{"count": 11}}, expected: "many 11"},
+		{lang: "ar", key: "books", args: []interface{}{Params{"count": 100}}, expected: "other 100"},
+		// Forms missing in a language fall back to "other"
+		{lang: "fr", key: "books", args: []interface{}{Params{"count": 1}}, expected: "1 book"},
+	}
+
+	for _, tt := range tests {
+		if result := i18n.T(tt.lang, tt.key, tt.args...); result != tt.expected {
+			t.Errorf("T(%q, %q, %v) = %q, want %q", tt.lang, tt.key, tt.args, result, tt.expected)
+		}
+	}
+}
+
+func TestI18n_T_NamedPlaceholders(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	enContent := `{"sent": "{user} sent {count} books to {kindle}"}`
+	ruContent := `{"sent": "На {kindle} отправлено книг: {count} ({user})"}`
+
+	os.WriteFile(filepath.Join(tmpDir, "en.json"), []byte(enContent), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "ru.json"), []byte(ruContent), 0644)
+
+	i18n, err := NewI18n(tmpDir, "en")
+	if err != nil {
+		t.Fatalf("Failed to create i18n: %v", err)
+	}
+
+	params := Params{"user": "Alice", "count": 3, "kindle": "alice@kindle.com"}
+	if result := i18n.T("en", "sent", params); result != "Alice sent 3 books to alice@kindle.com" {
+		t.Errorf("T(en) = %q", result)
+	}
+	if result := i18n.T("ru", "sent", params); result != "На alice@kindle.com отправлено книг: 3 (Alice)" {
+		t.Errorf("T(ru) = %q", result)
+	}
+
+	// Unknown placeholders and stray braces are kept
+	if result := i18n.T("en", "sent", Params{"user": "{count}"}); result != "{count} sent {count} books to {kindle}" {
+		t.Errorf("T(partial) = %q", result)
+	}
+}
+
+func TestI18n_Locales(t *testing.T) {
+	i18n, err := NewI18n("locales", "en")
+	if err != nil {
+		t.Fatalf("Failed to load locales: %v", err)
+	}
+
+	tests := []struct {
+		lang     string
+		count    int
+		expected string
+	}{
+		{lang: "en", count: 1, expected: "📚 Your reading list (1 book):"},
+		{lang: "en", count: 2, expected: "📚 Your reading list (2 books):"},
+		{lang: "ru", count: 1, expected: "📚 В вашем списке чтения 1 книга:"},
+		{lang: "ru", count: 3, expected: "📚 В вашем списке чтения 3 книги:"},
+		{lang: "ru", count: 11, expected: "📚 В вашем списке чтения 11 книг:"},
+	}
+
+	for _, tt := range tests {
+		if result := i18n.T(tt.lang, "list_header", Params{"count": tt.count}); result != tt.expected {
+			t.Errorf("T(%q, list_header, %d) = %q, want %q", tt.lang, tt.count, result, tt.expected)
+		}
+	}
+}
+
+func TestI18n_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		en        string
+		ru        string
+		wantError []string
+	}{
+		{
+			name: "consistent",
+			en:   `{"greeting": "Hello, %s!", "books": {"one": "{count} book", "other": "{count} books"}}`,
+			ru:   `{"greeting": "Привет, %s!", "books": {"one": "{count} книга", "few": "{count} книги", "many": "{count} книг", "other": "{count} книги"}}`,
+		},
+		{
+			name:      "missing key",
+			en:        `{"greeting": "Hello, %s!", "bye": "Bye"}`,
+			ru:        `{"greeting": "Привет, %s!"}`,
+			wantError: []string{"ru: missing key bye"},
+		},
+		{
+			name:      "extra key",
+			en:        `{"greeting": "Hello, %s!"}`,
+			ru:        `{"greeting": "Привет, %s!", "bye": "Пока"}`,
+			wantError: []string{"ru: extra key bye"},
+		},
+		{
+			name:      "mismatched verbs",
+			en:        `{"settings": "Email: %s, books: %d"}`,
+			ru:        `{"settings": "Email: %s, книг: %s"}`,
+			wantError: []string{"ru: settings: format verbs %s %s, want %s %d"},
+		},
+		{
+			name:      "missing verb",
+			en:        `{"greeting": "Hello, %s!"}`,
+			ru:        `{"greeting": "Привет!"}`,
+			wantError: []string{"ru: greeting: format verbs (none), want %s"},
+		},
+		{
+			name:      "unknown placeholder",
+			en:        `{"books": {"one": "{count} book", "other": "{count} books"}}`,
+			ru:        `{"books": {"one": "{n} книга", "other": "{n} книги"}}`,
+			wantError: []string{"ru: books: unknown placeholder {n}"},
+		},
+		{
+			name:      "plural forms differ",
+			en:        `{"books": {"one": "%d book", "other": "books"}}`,
+			ru:        `{"books": {"one": "%d книга", "other": "%d книги"}}`,
+			wantError: []string{"en: books: plural forms have different format verbs"},
+		},
+		{
+			name:      "several problems",
+			en:        `{"a": "A", "b": "B %d"}`,
+			ru:        `{"b": "Б", "c": "В"}`,
+			wantError: []string{"ru: missing key a", "ru: b: format verbs (none), want %d", "ru: extra key c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			os.WriteFile(filepath.Join(tmpDir, "en.json"), []byte(tt.en), 0644)
+			os.WriteFile(filepath.Join(tmpDir, "ru.json"), []byte(tt.ru), 0644)
+
+			i18n, err := NewI18n(tmpDir, "en")
+			if err != nil {
+				t.Fatalf("Failed to create i18n: %v", err)
+			}
+
+			err = i18n.Validate()
+			if len(tt.wantError) == 0 {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() error = nil, want %v", tt.wantError)
+			}
+			for _, want := range tt.wantError {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestI18n_Validate_MissingDefault(t *testing.T) {
+	i18n := New("en")
+	if err := i18n.Validate(); err == nil {
+		t.Error("Expected error without default language, got nil")
+	}
+}
+
+// TestLocales_Valid keeps the shipped translations complete and consistent
+func TestLocales_Valid(t *testing.T) {
+	i18n, err := NewI18n("locales", "en")
+	if err != nil {
+		t.Fatalf("Failed to load locales: %v", err)
+	}
+
+	if err := i18n.Validate(); err != nil {
+		t.Errorf("Locales are invalid:\n%v", err)
+	}
+}
+
+func TestI18n_Reload(t *testing.T) {
+	tmpDir := t.TempDir()
+	override := filepath.Join(tmpDir, "en.json")
+	os.WriteFile(override, []byte(`{"welcome": "Hi"}`), 0644)
+
+	i18n, err := NewEmbedded(tmpDir, "en")
+	if err != nil {
+		t.Fatalf("NewEmbedded() error = %v", err)
+	}
+
+	// Translations stay usable while they are swapped
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for n := 0; n < 4; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					i18n.T("ru", "welcome")
+					i18n.GetSupportedLanguages()
+				}
+			}
+		}()
+	}
+
+	os.WriteFile(override, []byte(`{"welcome": "Hello"}`), 0644)
+	if err := i18n.Reload(); err != nil {
+		t.Errorf("Reload() error = %v", err)
+	}
+	if result := i18n.T("en", "welcome"); result != "Hello" {
+		t.Errorf("T() after reload = %q, want Hello", result)
+	}
+
+	// In strict mode broken or inconsistent files keep the previous translations
+	i18n.EnableStrictReload()
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "malformed JSON", file: "en.json", content: `{"welcome": `},
+		{name: "extra key", file: "ru.json", content: `{"no_such_key": "Ошибка"}`},
+		{name: "mismatched verbs", file: "ru.json", content: `{"welcome_back": "С возвращением!"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, tt.file)
+			os.WriteFile(path, []byte(tt.content), 0644)
+			defer os.Remove(path)
+			if tt.file == "en.json" {
+				defer os.WriteFile(path, []byte(`{"welcome": "Hello"}`), 0644)
+			}
+
+			if err := i18n.Reload(); err == nil {
+				t.Error("Reload() error = nil, want error")
+			}
+			if result := i18n.T("en", "welcome"); result != "Hello" {
+				t.Errorf("T() after failed reload = %q, want Hello", result)
+			}
+			if result := i18n.T("ru", "welcome_back", "Иван"); result != "👋 С возвращением, Иван!" {
+				t.Errorf("T(ru) after failed reload = %q", result)
+			}
+		})
+	}
+
+	close(stop)
+	wg.Wait()
+}
+
+func TestI18n_Reload_NotStrict(t *testing.T) {
+	tmpDir := t.TempDir()
+	i18n, err := NewEmbedded(tmpDir, "en")
+	if err != nil {
+		t.Fatalf("NewEmbedded() error = %v", err)
+	}
+
+	// Inconsistent files are applied, broken ones still are not
+	os.WriteFile(filepath.Join(tmpDir, "ru.json"), []byte(`{"welcome_back": "С возвращением!"}`), 0644)
+	if err := i18n.Reload(); err != nil {
+		t.Errorf("Reload() error = %v, want the translations applied", err)
+	}
+	if result := i18n.T("ru", "welcome_back", "Иван"); !strings.HasPrefix(result, "С возвращением!") {
+		t.Errorf("T(ru) after reload = %q, want the new message", result)
+	}
+
+	os.WriteFile(filepath.Join(tmpDir, "en.json"), []byte(`{"welcome": `), 0644)
+	if err := i18n.Reload(); err == nil {
+		t.Error("Reload() error = nil for malformed JSON")
+	}
+}
+
+func TestI18n_Watch(t *testing.T) {
+	tmpDir := t.TempDir()
+	override := filepath.Join(tmpDir, "en.json")
+	os.WriteFile(override, []byte(`{"welcome": "Hi"}`), 0644)
+
+	i18n, err := NewEmbedded(tmpDir, "en")
+	if err != nil {
+		t.Fatalf("NewEmbedded() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan error, 10)
+	go i18n.Watch(ctx, 10*time.Millisecond, func(err error) { reloaded <- err })
+
+	// Make sure the change is visible even on coarse file timestamps
+	time.Sleep(20 * time.Millisecond)
+	os.WriteFile(override, []byte(`{"welcome": "Hello there"}`), 0644)
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatalf("reload error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("change was not picked up")
+	}
+	if result := i18n.T("en", "welcome"); result != "Hello there" {
+		t.Errorf("T() after watch reload = %q, want Hello there", result)
+	}
+}
@@ -0,0 +1,112 @@
+package i18n
+
+import "strings"
+
+// CLDR plural categories. Messages with plural forms are JSON objects keyed
+// by category; "other" is required.
+const (
+	PluralZero  = "zero"
+	PluralOne   = "one"
+	PluralTwo   = "two"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// pluralCategories lists the valid plural categories
+var pluralCategories = map[string]bool{
+	PluralZero:  true,
+	PluralOne:   true,
+	PluralTwo:   true,
+	PluralFew:   true,
+	PluralMany:  true,
+	PluralOther: true,
+}
+
+// pluralRule returns the plural category of a non-negative integer
+type pluralRule func(n int) string
+
+// pluralRules holds the CLDR integer plural rules of languages that differ
+// from English. See https://www.unicode.org/cldr/charts/latest/supplemental/language_plural_rules.html
+var pluralRules = map[string]pluralRule{
+	"ru": eastSlavicPlural,
+	"uk": eastSlavicPlural,
+	"be": eastSlavicPlural,
+	"pl": polishPlural,
+	"ar": arabicPlural,
+	"ja": noPlural,
+	"ko": noPlural,
+	"zh": noPlural,
+}
+
+// PluralCategory returns the CLDR plural category of n in lang. Languages
+// without a rule use English's one/other.
+func PluralCategory(lang string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+
+	lang, _, _ = strings.Cut(strings.ToLower(lang), "-")
+	if rule, ok := pluralRules[lang]; ok {
+		return rule(n)
+	}
+	return englishPlural(n)
+}
+
+// englishPlural: 1 book, 2 books
+func englishPlural(n int) string {
+	if n == 1 {
+		return PluralOne
+	}
+	return PluralOther
+}
+
+// eastSlavicPlural: 1 книга, 2 книги, 5 книг, 21 книга
+func eastSlavicPlural(n int) string {
+	mod10, mod100 := n%10, n%100
+	switch {
+	case mod10 == 1 && mod100 != 11:
+		return PluralOne
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return PluralFew
+	default:
+		return PluralMany
+	}
+}
+
+// polishPlural: 1 książka, 2 książki, 5 książek, 22 książki
+func polishPlural(n int) string {
+	mod10, mod100 := n%10, n%100
+	switch {
+	case n == 1:
+		return PluralOne
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return PluralFew
+	default:
+		return PluralMany
+	}
+}
+
+// arabicPlural uses all six categories
+func arabicPlural(n int) string {
+	mod100 := n % 100
+	switch {
+	case n == 0:
+		return PluralZero
+	case n == 1:
+		return PluralOne
+	case n == 2:
+		return PluralTwo
+	case mod100 >= 3 && mod100 <= 10:
+		return PluralFew
+	case mod100 >= 11:
+		return PluralMany
+	default:
+		return PluralOther
+	}
+}
+
+// noPlural is for languages without plural forms
+func noPlural(int) string {
+	return PluralOther
+}
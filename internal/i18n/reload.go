@@ -0,0 +1,98 @@
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+)
+
+// EnableStrictReload makes Reload reject translations that fail Validate,
+// like I18N_STRICT does at startup
+func (i *I18n) EnableStrictReload() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.strict = true
+}
+
+// Reload reads the translations again from where they were loaded and
+// swaps them in at once. Translations that fail to load are rejected, and
+// the ones in use are kept. Translations that fail Validate are rejected too
+// in strict mode, and applied with a warning otherwise.
+func (i *I18n) Reload() error {
+	next := New(i.defaultLang)
+	if i.embedded {
+		if err := next.loadFS(embeddedFS()); err != nil {
+			return fmt.Errorf("failed to load embedded translations: %w", err)
+		}
+	}
+	if i.dir != "" {
+		if err := next.LoadTranslations(i.dir); err != nil {
+			return err
+		}
+	}
+	invalid := next.Validate()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if invalid != nil {
+		if i.strict {
+			return fmt.Errorf("invalid translations:\n%w", invalid)
+		}
+		logging.FromContext(context.Background()).Warn("Reloaded translations are incomplete or inconsistent", "error", invalid)
+	}
+	i.translations = next.translations
+
+	return nil
+}
+
+// Watch checks the translation directory for changes every interval and
+// reloads when a file is added, changed or removed, passing the result to
+// onReload. It returns when ctx is cancelled, and at once for embedded
+// translations without an override directory.
+func (i *I18n) Watch(ctx context.Context, interval time.Duration, onReload func(error)) {
+	if i.dir == "" {
+		return
+	}
+
+	last, _ := snapshot(i.dir)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			snap, err := snapshot(i.dir)
+			if err != nil || snap == last {
+				continue
+			}
+			last = snap
+			logging.Safely(ctx, "Panic while reloading translations", func() { onReload(i.Reload()) })
+		}
+	}
+}
+
+// snapshot describes the translation files in dir by name, size and
+// modification time, so any change to them changes the result
+func snapshot(dir string) (string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", filepath.Base(file), info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
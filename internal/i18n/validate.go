@@ -0,0 +1,169 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var (
+	// verbPattern matches fmt verbs such as %s, %d, %5.2f and %[2]s
+	verbPattern = regexp.MustCompile(`%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z%]`)
+	// placeholderPattern matches named placeholders such as {count}
+	placeholderPattern = regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+)
+
+// Validate compares every language with the default one. It reports keys
+// missing from or unknown to the default language, fmt verbs that differ
+// from the default language's, and named placeholders the default language
+// does not have.
+func (i *I18n) Validate() error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	reference, ok := i.translations[i.defaultLang]
+	if !ok {
+		return fmt.Errorf("default language %s is not loaded", i.defaultLang)
+	}
+
+	var errs []error
+
+	// Plural forms of one message take the same positional arguments
+	for _, key := range sortedKeys(reference) {
+		if err := checkForms(reference[key]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", i.defaultLang, key, err))
+		}
+	}
+
+	for _, lang := range i.languages() {
+		if lang == i.defaultLang {
+			continue
+		}
+		translations := i.translations[lang]
+
+		for _, key := range sortedKeys(reference) {
+			msg, ok := translations[key]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: missing key %s", lang, key))
+				continue
+			}
+			if err := checkForms(msg); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", lang, key, err))
+				continue
+			}
+			if err := compareMessages(reference[key], msg); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", lang, key, err))
+			}
+		}
+
+		for _, key := range sortedKeys(translations) {
+			if _, ok := reference[key]; !ok {
+				errs = append(errs, fmt.Errorf("%s: extra key %s", lang, key))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkForms checks that the plural forms of a message use the same verbs
+func checkForms(msg message) error {
+	var want []string
+	for n, text := range msg.texts() {
+		verbs := formatVerbs(text)
+		if n == 0 {
+			want = verbs
+			continue
+		}
+		if !slices.Equal(verbs, want) {
+			return fmt.Errorf("plural forms have different format verbs: %s and %s", describeVerbs(want), describeVerbs(verbs))
+		}
+	}
+	return nil
+}
+
+// compareMessages checks that a translation takes the same arguments as the
+// reference message
+func compareMessages(reference, translation message) error {
+	want, got := formatVerbs(reference.texts()[0]), formatVerbs(translation.texts()[0])
+	if !slices.Equal(got, want) {
+		return fmt.Errorf("format verbs %s, want %s", describeVerbs(got), describeVerbs(want))
+	}
+
+	known := make(map[string]bool)
+	for _, text := range reference.texts() {
+		for _, name := range placeholders(text) {
+			known[name] = true
+		}
+	}
+	for _, text := range translation.texts() {
+		for _, name := range placeholders(text) {
+			if !known[name] {
+				return fmt.Errorf("unknown placeholder {%s}", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// texts returns the text of a plain message or every plural form, in a
+// stable order
+func (m message) texts() []string {
+	if m.forms == nil {
+		return []string{m.text}
+	}
+
+	categories := make([]string, 0, len(m.forms))
+	for category := range m.forms {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	texts := make([]string, 0, len(categories))
+	for _, category := range categories {
+		texts = append(texts, m.forms[category])
+	}
+	return texts
+}
+
+// formatVerbs returns the fmt verbs in text, ignoring %%
+func formatVerbs(text string) []string {
+	var verbs []string
+	for _, verb := range verbPattern.FindAllString(text, -1) {
+		if verb != "%%" {
+			verbs = append(verbs, verb)
+		}
+	}
+	return verbs
+}
+
+// placeholders returns the names of the {placeholders} in text
+func placeholders(text string) []string {
+	var names []string
+	for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// describeVerbs formats verbs for error messages
+func describeVerbs(verbs []string) string {
+	if len(verbs) == 0 {
+		return "(none)"
+	}
+	return strings.Join(verbs, " ")
+}
+
+// sortedKeys returns the keys of translations in order
+func sortedKeys(translations map[string]message) []string {
+	keys := make([]string, 0, len(translations))
+	for key := range translations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,176 @@
+package kindle
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// acsAPIVersion is the Azure Communication Services email API version
+const acsAPIVersion = "2023-03-31"
+
+// ACSClient sends emails through the Azure Communication Services REST API
+type ACSClient struct {
+	endpoint  *url.URL
+	accessKey []byte
+	sender    string
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewACSClient creates an email client from an ACS connection string
+// ("endpoint=https://...;accesskey=...") and the sender address.
+// A nil client uses a client with a 60s timeout.
+func NewACSClient(connectionString, sender string, client *http.Client) (*ACSClient, error) {
+	var endpoint, accessKey string
+	for _, part := range strings.Split(connectionString, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "endpoint":
+			endpoint = strings.TrimSpace(value)
+		case "accesskey":
+			accessKey = strings.TrimSpace(value)
+		}
+	}
+	if endpoint == "" || accessKey == "" {
+		return nil, fmt.Errorf("invalid ACS connection string: endpoint and accesskey are required")
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid ACS endpoint: %s", endpoint)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(accessKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACS access key: %w", err)
+	}
+
+	if sender == "" {
+		return nil, fmt.Errorf("sender address is required")
+	}
+
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+
+	return &ACSClient{
+		endpoint:  u,
+		accessKey: key,
+		sender:    sender,
+		client:    client,
+		now:       time.Now,
+	}, nil
+}
+
+type acsAddress struct {
+	Address string `json:"address"`
+}
+
+type acsAttachment struct {
+	Name            string `json:"name"`
+	ContentType     string `json:"contentType"`
+	ContentInBase64 string `json:"contentInBase64"`
+}
+
+type acsMessage struct {
+	SenderAddress string `json:"senderAddress"`
+	Recipients    struct {
+		To []acsAddress `json:"to"`
+	} `json:"recipients"`
+	Content struct {
+		Subject   string `json:"subject"`
+		PlainText string `json:"plainText"`
+	} `json:"content"`
+	Attachments []acsAttachment `json:"attachments,omitempty"`
+}
+
+// Send queues the email for delivery. ACS accepts the message asynchronously,
+// so a nil error means the message was accepted, not delivered.
+func (c *ACSClient) Send(ctx context.Context, email *Email) error {
+	var msg acsMessage
+	msg.SenderAddress = c.sender
+	msg.Recipients.To = []acsAddress{{Address: email.To}}
+	msg.Content.Subject = email.Subject
+	msg.Content.PlainText = email.Body
+	for _, a := range email.Attachments {
+		msg.Attachments = append(msg.Attachments, acsAttachment{
+			Name:            a.Name,
+			ContentType:     a.ContentType,
+			ContentInBase64: base64.StdEncoding.EncodeToString(a.Data),
+		})
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode email: %w", err)
+	}
+
+	u := *c.endpoint
+	u.Path = "/emails:send"
+	u.RawQuery = "api-version=" + acsAPIVersion
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create email request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.sign(req, body)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("failed to send email: %s", errorMessage(resp))
+	}
+
+	return nil
+}
+
+// sign adds HMAC-SHA256 authentication headers to an ACS request
+func (c *ACSClient) sign(req *http.Request, body []byte) {
+	hash := sha256.Sum256(body)
+	contentHash := base64.StdEncoding.EncodeToString(hash[:])
+	date := c.now().UTC().Format(http.TimeFormat)
+
+	stringToSign := fmt.Sprintf("%s\n%s\n%s;%s;%s", req.Method, req.URL.RequestURI(), date, req.URL.Host, contentHash)
+	mac := hmac.New(sha256.New, c.accessKey)
+	mac.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	req.Header.Set("x-ms-date", date)
+	req.Header.Set("x-ms-content-sha256", contentHash)
+	req.Header.Set("Authorization", "HMAC-SHA256 SignedHeaders=x-ms-date;host;x-ms-content-sha256&Signature="+signature)
+}
+
+// errorMessage extracts the error from an ACS error response
+func errorMessage(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	var acsErr struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &acsErr) == nil && acsErr.Error.Code != "" {
+		return fmt.Sprintf("%s: %s (%s)", resp.Status, acsErr.Error.Message, acsErr.Error.Code)
+	}
+
+	return resp.Status
+}
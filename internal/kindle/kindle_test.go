@@ -0,0 +1,156 @@
+package kindle
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeEmailClient records sent emails
+type fakeEmailClient struct {
+	sent []*Email
+	err  error
+}
+
+func (c *fakeEmailClient) Send(ctx context.Context, email *Email) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.sent = append(c.sent, email)
+	return nil
+}
+
+func TestSender_SendToKindle(t *testing.T) {
+	client := &fakeEmailClient{}
+	sender := NewSender(client)
+
+	book := Attachment{Name: "book.epub", ContentType: "application/epub+zip", Data: []byte("epub")}
+	if err := sender.SendToKindle(context.Background(), "reader@kindle.com", book); err != nil {
+		t.Fatalf("SendToKindle() error = %v", err)
+	}
+
+	if len(client.sent) != 1 {
+		t.Fatalf("Sent %d emails, want 1", len(client.sent))
+	}
+	email := client.sent[0]
+	if email.To != "reader@kindle.com" {
+		t.Errorf("To = %v, want reader@kindle.com", email.To)
+	}
+	if len(email.Attachments) != 1 || email.Attachments[0].Name != "book.epub" {
+		t.Errorf("Attachments = %+v, want book.epub", email.Attachments)
+	}
+
+	large := Attachment{Name: "large.pdf", Data: make([]byte, MaxAttachmentSize+1)}
+	if err := sender.SendToKindle(context.Background(), "reader@kindle.com", large); !errors.Is(err, ErrAttachmentTooLarge) {
+		t.Errorf("SendToKindle() error = %v, want %v", err, ErrAttachmentTooLarge)
+	}
+
+	client.err = errors.New("mail server down")
+	if err := sender.SendToKindle(context.Background(), "reader@kindle.com", book); err == nil {
+		t.Error("Expected error when the email client fails, got nil")
+	}
+}
+
+func TestNewACSClient_InvalidConnectionString(t *testing.T) {
+	tests := []struct {
+		name             string
+		connectionString string
+		sender           string
+	}{
+		{name: "empty", connectionString: "", sender: "bot@example.com"},
+		{name: "missing key", connectionString: "endpoint=https://acs.example.com/", sender: "bot@example.com"},
+		{name: "key not base64", connectionString: "endpoint=https://acs.example.com/;accesskey=not base64!", sender: "bot@example.com"},
+		{name: "missing sender", connectionString: "endpoint=https://acs.example.com/;accesskey=c2VjcmV0", sender: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewACSClient(tt.connectionString, tt.sender, nil); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestACSClient_Send(t *testing.T) {
+	key := []byte("secret")
+	date := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/emails:send" {
+			t.Errorf("Request = %s %s, want POST /emails:send", r.Method, r.URL.Path)
+		}
+		if r.URL.Query().Get("api-version") != acsAPIVersion {
+			t.Errorf("api-version = %v, want %v", r.URL.Query().Get("api-version"), acsAPIVersion)
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		// Verify the HMAC signature the way ACS does
+		hash := sha256.Sum256(body)
+		contentHash := base64.StdEncoding.EncodeToString(hash[:])
+		if r.Header.Get("x-ms-content-sha256") != contentHash {
+			t.Errorf("x-ms-content-sha256 = %v, want %v", r.Header.Get("x-ms-content-sha256"), contentHash)
+		}
+		if r.Header.Get("x-ms-date") != date.Format(http.TimeFormat) {
+			t.Errorf("x-ms-date = %v, want %v", r.Header.Get("x-ms-date"), date.Format(http.TimeFormat))
+		}
+		mac := hmac.New(sha256.New, key)
+		fmt.Fprintf(mac, "POST\n%s\n%s;%s;%s", r.URL.RequestURI(), r.Header.Get("x-ms-date"), r.Host, contentHash)
+		want := "HMAC-SHA256 SignedHeaders=x-ms-date;host;x-ms-content-sha256&Signature=" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		if r.Header.Get("Authorization") != want {
+			t.Errorf("Authorization = %v, want %v", r.Header.Get("Authorization"), want)
+		}
+
+		var msg acsMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatalf("Failed to decode message: %v", err)
+		}
+		if msg.SenderAddress != "bot@example.com" || msg.Recipients.To[0].Address != "reader@kindle.com" {
+			t.Errorf("Message = %+v, want sender and recipient set", msg)
+		}
+		if len(msg.Attachments) != 1 || msg.Attachments[0].ContentInBase64 != base64.StdEncoding.EncodeToString([]byte("epub")) {
+			t.Errorf("Attachments = %+v, want base64 encoded book", msg.Attachments)
+		}
+
+		if strings.Contains(msg.Content.Subject, "reject") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": {"code": "InvalidRecipient", "message": "Recipient rejected"}}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	connectionString := "endpoint=" + server.URL + "/;accesskey=" + base64.StdEncoding.EncodeToString(key)
+	client, err := NewACSClient(connectionString, "bot@example.com", server.Client())
+	if err != nil {
+		t.Fatalf("NewACSClient() error = %v", err)
+	}
+	client.now = func() time.Time { return date }
+
+	email := &Email{
+		To:          "reader@kindle.com",
+		Subject:     "book.epub",
+		Attachments: []Attachment{{Name: "book.epub", ContentType: "application/epub+zip", Data: []byte("epub")}},
+	}
+	if err := client.Send(context.Background(), email); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	email.Subject = "reject"
+	err = client.Send(context.Background(), email)
+	if err == nil || !strings.Contains(err.Error(), "InvalidRecipient") {
+		t.Errorf("Send() error = %v, want InvalidRecipient", err)
+	}
+}
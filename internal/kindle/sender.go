@@ -0,0 +1,88 @@
+package kindle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/tracing"
+)
+
+// MaxAttachmentSize is the largest attachment Kindle accepts by email
+const MaxAttachmentSize = 50 * 1024 * 1024
+
+// ErrAttachmentTooLarge is returned for attachments over MaxAttachmentSize
+var ErrAttachmentTooLarge = errors.New("attachment too large for Kindle")
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Email is a message to send
+type Email struct {
+	To          string
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+// EmailClient sends emails
+type EmailClient interface {
+	Send(ctx context.Context, email *Email) error
+}
+
+// Sender delivers books to Kindle devices by email
+type Sender struct {
+	client EmailClient
+}
+
+// NewSender creates a Kindle sender
+func NewSender(client EmailClient) *Sender {
+	return &Sender{
+		client: client,
+	}
+}
+
+// TestDocument returns a small text document for checking that emails from
+// the sender reach a Kindle
+func TestDocument(text string) Attachment {
+	return Attachment{
+		Name:        "Flibusta Kindle Bot test.txt",
+		ContentType: "text/plain; charset=utf-8",
+		Data:        []byte(text),
+	}
+}
+
+// SendToKindle emails the book to a Kindle address
+func (s *Sender) SendToKindle(ctx context.Context, to string, book Attachment) (err error) {
+	ctx, span := tracing.Start(ctx, "kindle.Send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("book.file", book.Name),
+			attribute.Int("book.size", len(book.Data)),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if len(book.Data) > MaxAttachmentSize {
+		return ErrAttachmentTooLarge
+	}
+
+	// Kindle ignores the body and names the document after the attachment
+	if err := s.client.Send(ctx, &Email{
+		To:          to,
+		Subject:     book.Name,
+		Body:        "Sent by Flibusta Kindle Bot",
+		Attachments: []Attachment{book},
+	}); err != nil {
+		return fmt.Errorf("failed to send %s to Kindle: %w", book.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is a recovered panic with the stack it happened on
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+// NewPanicError captures the current stack; call it from the deferred
+// function that recovered value
+func NewPanicError(value interface{}) *PanicError {
+	return &PanicError{Value: value, Stack: debug.Stack()}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// NewIncidentID returns a short random ID that ties what users or admins
+// see to the log entry with the full error
+func NewIncidentID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// LogError logs err as msg with its incident ID, and the stack for panics
+func LogError(ctx context.Context, msg string, err error, incident string) {
+	logger := FromContext(ctx).With("incident", incident)
+
+	var p *PanicError
+	if errors.As(err, &p) {
+		logger.Error(msg, "panic", p.Value, "stack", string(p.Stack))
+		return
+	}
+	logger.Error(msg, "error", err)
+}
+
+// Safely runs fn, logging a panic in it as msg with an incident ID instead
+// of letting it crash the bot. Background loops run each unit of work (a
+// scheduled run, a queued job, one follower's notification) this way, so a
+// panic in one does not stop the loop or the units after it.
+func Safely(ctx context.Context, msg string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			LogError(ctx, msg, NewPanicError(r), NewIncidentID())
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,166 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Redacted replaces secret values in log output
+const Redacted = "[REDACTED]"
+
+type contextKey struct{}
+
+// Options configures a logger
+type Options struct {
+	Level   string   // "debug", "info", "warn" or "error"
+	Format  string   // "json" or "text"
+	Secrets []string // Values that must never appear in log output
+
+	// Redactor is used instead of Secrets when secrets can change at runtime
+	Redactor *Redactor
+}
+
+// New creates a structured logger writing to w
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	redactor := opts.Redactor
+	if redactor == nil {
+		redactor = NewRedactor(opts.Secrets)
+	}
+
+	handlerOpts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactor.replaceAttr,
+	}
+
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "json", "":
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	case "text":
+		handler = slog.NewTextHandler(w, handlerOpts)
+	default:
+		return nil, fmt.Errorf("invalid log format: %s (must be 'json' or 'text')", opts.Format)
+	}
+
+	return slog.New(handler), nil
+}
+
+// ParseLevel converts a LOG_LEVEL value to a slog level
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("invalid log level: %s (must be 'debug', 'info', 'warn', or 'error')", level)
+	}
+}
+
+// WithLogger returns a copy of ctx carrying the given logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// With returns a copy of ctx whose logger has the given fields attached
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+// FromContext returns the logger carried by ctx, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+// Redactor masks secret values in log attributes. It is safe for concurrent use.
+type Redactor struct {
+	mu       sync.Mutex
+	secrets  []string
+	replacer atomic.Pointer[strings.Replacer]
+}
+
+// NewRedactor creates a redactor masking the given secrets
+func NewRedactor(secrets []string) *Redactor {
+	r := &Redactor{}
+	r.Add(secrets...)
+	return r
+}
+
+// Add masks additional secrets, e.g. after they were rotated
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, secret := range secrets {
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	if len(r.secrets) == 0 {
+		return
+	}
+
+	pairs := make([]string, 0, len(r.secrets)*2)
+	for _, secret := range r.secrets {
+		pairs = append(pairs, secret, Redacted)
+	}
+	r.replacer.Store(strings.NewReplacer(pairs...))
+}
+
+// Redact masks the secrets in s
+func (r *Redactor) Redact(s string) string {
+	if replacer := r.replacer.Load(); replacer != nil {
+		return replacer.Replace(s)
+	}
+	return s
+}
+
+// replaceAttr is used as slog.HandlerOptions.ReplaceAttr
+func (r *Redactor) replaceAttr(_ []string, a slog.Attr) slog.Attr {
+	replacer := r.replacer.Load()
+	if replacer == nil {
+		return a
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(replacer.Replace(a.Value.String()))
+	case slog.KindAny:
+		// Errors often embed request URLs containing the bot token
+		switch v := a.Value.Any().(type) {
+		case error:
+			a.Value = slog.StringValue(replacer.Replace(v.Error()))
+		case fmt.Stringer:
+			a.Value = slog.StringValue(replacer.Replace(v.String()))
+		default:
+			// Slices, maps and structs can hold secrets too, such as
+			// connection strings in error lists. They keep their structure
+			// unless there is something to mask.
+			s := fmt.Sprintf("%+v", v)
+			if redacted := replacer.Replace(s); redacted != s {
+				a.Value = slog.StringValue(redacted)
+			}
+		}
+	}
+
+	return a
+}
@@ -0,0 +1,237 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew_Formats(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		wantErr bool
+		check   func(t *testing.T, output string)
+	}{
+		{
+			name:   "json",
+			format: "json",
+			check: func(t *testing.T, output string) {
+				var entry map[string]interface{}
+				if err := json.Unmarshal([]byte(output), &entry); err != nil {
+					t.Fatalf("Output is not JSON: %v", err)
+				}
+				if entry["msg"] != "hello" {
+					t.Errorf("msg = %v, want %v", entry["msg"], "hello")
+				}
+			},
+		},
+		{
+			name:   "text",
+			format: "text",
+			check: func(t *testing.T, output string) {
+				if !strings.Contains(output, "msg=hello") {
+					t.Errorf("Output %q does not look like text format", output)
+				}
+			},
+		},
+		{
+			name:    "invalid",
+			format:  "xml",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := New(&buf, Options{Level: "info", Format: tt.format})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			logger.Info("hello")
+			tt.check(t, buf.String())
+		})
+	}
+}
+
+func TestNew_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Options{Level: "warn", Format: "text"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	logger.Info("hidden")
+	logger.Warn("shown")
+
+	output := buf.String()
+	if strings.Contains(output, "hidden") {
+		t.Error("Info message should be filtered at warn level")
+	}
+	if !strings.Contains(output, "shown") {
+		t.Error("Warn message should be logged at warn level")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		level   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{level: "debug", want: slog.LevelDebug},
+		{level: "info", want: slog.LevelInfo},
+		{level: "", want: slog.LevelInfo},
+		{level: "WARN", want: slog.LevelWarn},
+		{level: "error", want: slog.LevelError},
+		{level: "verbose", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			got, err := ParseLevel(tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLevel(%q) error = %v, wantErr %v", tt.level, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseLevel(%q) = %v, want %v", tt.level, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_RedactsSecrets(t *testing.T) {
+	const token = "123456:ABC-secret-token"
+
+	var buf bytes.Buffer
+	logger, err := New(&buf, Options{Level: "debug", Format: "json", Secrets: []string{token, ""}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	requestErr := errors.New(`Post "https://api.telegram.org/bot` + token + `/getUpdates": timeout`)
+	logger.With("token", token).Error("request failed "+token, "error", requestErr, "url", "https://api.telegram.org/bot"+token)
+
+	output := buf.String()
+	if strings.Contains(output, token) {
+		t.Errorf("Output contains secret: %s", output)
+	}
+	if !strings.Contains(output, Redacted) {
+		t.Errorf("Output does not contain redaction marker: %s", output)
+	}
+}
+
+func TestNew_RedactsComposites(t *testing.T) {
+	const secret = "AccountKey=c2VjcmV0"
+
+	var buf bytes.Buffer
+	logger, err := New(&buf, Options{Format: "json", Secrets: []string{secret}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	type settings struct{ ConnectionString string }
+	logger.Info("maintenance",
+		"errors", []string{"failed to connect: Endpoint=sb://x;" + secret},
+		"settings", settings{ConnectionString: secret},
+		"counts", map[string]int{"users": 2},
+	)
+
+	output := buf.String()
+	if strings.Contains(output, secret) {
+		t.Errorf("Output contains secret: %s", output)
+	}
+
+	// Values without secrets keep their structure
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if _, ok := entry["counts"].(map[string]interface{}); !ok {
+		t.Errorf("counts = %v, want an object", entry["counts"])
+	}
+}
+
+func TestRedactor_Add(t *testing.T) {
+	const oldSecret, newSecret = "old-secret", "rotated-secret"
+
+	var buf bytes.Buffer
+	redactor := NewRedactor([]string{oldSecret})
+	logger, err := New(&buf, Options{Format: "text", Redactor: redactor})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Secrets added after the logger was created are masked too
+	redactor.Add(newSecret)
+	logger.Info("secrets", "old", oldSecret, "new", newSecret)
+
+	output := buf.String()
+	if strings.Contains(output, oldSecret) || strings.Contains(output, newSecret) {
+		t.Errorf("Output contains secret: %s", output)
+	}
+}
+
+func TestContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Options{Level: "info", Format: "json"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx := WithLogger(context.Background(), logger)
+	ctx = With(ctx, "update_id", 42, "chat_id", int64(12345))
+
+	FromContext(ctx).Info("handled")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Output is not JSON: %v", err)
+	}
+	if entry["update_id"] != float64(42) {
+		t.Errorf("update_id = %v, want %v", entry["update_id"], 42)
+	}
+	if entry["chat_id"] != float64(12345) {
+		t.Errorf("chat_id = %v, want %v", entry["chat_id"], 12345)
+	}
+}
+
+func TestFromContext_Default(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("FromContext() without logger should return slog.Default()")
+	}
+}
+
+func TestSafely(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Options{Level: "info", Format: "json"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx := WithLogger(context.Background(), logger)
+
+	Safely(ctx, "Panic in test", func() { panic("boom") })
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Output is not JSON: %v", err)
+	}
+	if entry["msg"] != "Panic in test" || entry["panic"] != "boom" {
+		t.Errorf("entry = %v, want the panic", entry)
+	}
+	if id, _ := entry["incident"].(string); len(id) != 8 {
+		t.Errorf("incident = %v, want an 8 character ID", entry["incident"])
+	}
+	if stack, _ := entry["stack"].(string); !strings.Contains(stack, "TestSafely") {
+		t.Errorf("stack = %q, want the panicking function", stack)
+	}
+}
@@ -0,0 +1,123 @@
+// Package maintenance runs periodic housekeeping: marking inactive users and
+// purging expired search sessions.
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/search"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
+)
+
+// Report summarizes a maintenance run
+type Report struct {
+	Trigger              string        `json:"trigger"` // What started the run, e.g. "schedule" or "admin"
+	StartedAt            time.Time     `json:"started_at"`
+	Duration             time.Duration `json:"duration"`
+	UsersDeactivated     int           `json:"users_deactivated"`
+	SearchContextsPurged int           `json:"search_contexts_purged"`
+	Errors               []string      `json:"errors,omitempty"`
+}
+
+// Service performs maintenance runs. Runs never overlap.
+type Service struct {
+	users         *user.Manager
+	contexts      *search.ContextStore
+	inactiveAfter time.Duration
+
+	mu   sync.Mutex // Held for the duration of a run
+	last atomic.Pointer[Report]
+	now  func() time.Time
+}
+
+// NewService creates a maintenance service. Users not seen for inactiveAfter
+// are marked inactive. contexts may be nil and a zero duration disables its step.
+func NewService(users *user.Manager, contexts *search.ContextStore, inactiveAfter time.Duration) *Service {
+	return &Service{
+		users:         users,
+		contexts:      contexts,
+		inactiveAfter: inactiveAfter,
+		now:           time.Now,
+	}
+}
+
+// Run performs every maintenance step and returns the report. A failing step
+// is recorded in the report and does not stop the others.
+func (s *Service) Run(ctx context.Context, trigger string) *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &Report{Trigger: trigger, StartedAt: s.now()}
+	fail := func(step string, err error) {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", step, err))
+	}
+
+	if s.inactiveAfter > 0 {
+		n, err := s.users.DeactivateInactiveUsers(ctx, report.StartedAt.Add(-s.inactiveAfter))
+		report.UsersDeactivated = n
+		if err != nil {
+			fail("deactivate users", err)
+		}
+	}
+
+	if s.contexts != nil {
+		report.SearchContextsPurged = s.contexts.PurgeExpired()
+	}
+
+	report.Duration = s.now().Sub(report.StartedAt)
+	s.last.Store(report)
+
+	logger := logging.FromContext(ctx)
+	args := []any{
+		"trigger", report.Trigger,
+		"duration", report.Duration,
+		"users_deactivated", report.UsersDeactivated,
+		"search_contexts_purged", report.SearchContextsPurged,
+	}
+	if len(report.Errors) > 0 {
+		logger.Warn("Maintenance finished with errors", append(args, "errors", report.Errors)...)
+	} else {
+		logger.Info("Maintenance finished", args...)
+	}
+
+	return report
+}
+
+// LastReport returns the report of the most recent run, or nil
+func (s *Service) LastReport() *Report {
+	return s.last.Load()
+}
+
+// Name identifies maintenance in health reports
+func (s *Service) Name() string {
+	return "maintenance"
+}
+
+// Check fails when the most recent run had errors, so they show up in
+// health reports. The errors themselves are only logged.
+func (s *Service) Check(_ context.Context) error {
+	if report := s.LastReport(); report != nil && len(report.Errors) > 0 {
+		return fmt.Errorf("run at %s had %d errors", report.StartedAt.UTC().Format(time.RFC3339), len(report.Errors))
+	}
+	return nil
+}
+
+// Start runs maintenance every interval until ctx is cancelled
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			logging.Safely(ctx, "Panic in scheduled maintenance", func() { s.Run(ctx, "schedule") })
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/search"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+func TestService_Run(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	repo := user.NewMemoryRepository()
+	repo.SaveUser(ctx, &models.User{TelegramID: 1, IsActive: true, LastActive: now})
+	repo.SaveUser(ctx, &models.User{TelegramID: 2, IsActive: true, LastActive: now.Add(-100 * 24 * time.Hour)})
+
+	contexts := search.NewContextStore(-time.Minute) // Sessions expire immediately
+	contexts.Set(1, "tolstoy", nil)
+
+	svc := NewService(user.NewManager(repo), contexts, 90*24*time.Hour)
+	if svc.LastReport() != nil {
+		t.Fatal("LastReport() before the first run is not nil")
+	}
+
+	report := svc.Run(ctx, "test")
+
+	if report.Trigger != "test" {
+		t.Errorf("Trigger = %q, want test", report.Trigger)
+	}
+	if report.UsersDeactivated != 1 {
+		t.Errorf("UsersDeactivated = %d, want 1", report.UsersDeactivated)
+	}
+	if report.SearchContextsPurged != 1 {
+		t.Errorf("SearchContextsPurged = %d, want 1", report.SearchContextsPurged)
+	}
+	if len(report.Errors) != 0 {
+		t.Errorf("Errors = %v, want none", report.Errors)
+	}
+	if svc.LastReport() != report {
+		t.Error("LastReport() does not return the last run's report")
+	}
+
+	u, _ := repo.GetUser(ctx, 2)
+	if u.IsActive {
+		t.Error("user 2 is still active")
+	}
+}
+
+// failingRepository fails to deactivate users
+type failingRepository struct {
+	user.Repository
+}
+
+func (r failingRepository) DeactivateUsers(ctx context.Context, since time.Time) (int, error) {
+	return 0, errors.New("database down")
+}
+
+func TestService_Check(t *testing.T) {
+	svc := NewService(user.NewManager(failingRepository{user.NewMemoryRepository()}), nil, time.Hour)
+
+	if err := svc.Check(context.Background()); err != nil {
+		t.Errorf("Check() before the first run error = %v", err)
+	}
+
+	svc.Run(context.Background(), "test")
+	if err := svc.Check(context.Background()); err == nil {
+		t.Error("Check() after a failed run error = nil")
+	}
+}
+
+func TestService_Start(t *testing.T) {
+	svc := NewService(user.NewManager(user.NewMemoryRepository()), nil, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		svc.Start(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for svc.LastReport() == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if report := svc.LastReport(); report == nil || report.Trigger != "schedule" {
+		t.Errorf("LastReport() = %+v, want a scheduled run", report)
+	}
+}
@@ -0,0 +1,212 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "flibusta_bot"
+
+// Metrics holds the Prometheus collectors exported by the bot.
+// A nil *Metrics is valid and records nothing.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	updates          *prometheus.CounterVec
+	commands         *prometheus.CounterVec
+	callbacks        *prometheus.CounterVec
+	rejected         *prometheus.CounterVec
+	searches         *prometheus.CounterVec
+	searchDuration   prometheus.Histogram
+	downloads        *prometheus.CounterVec
+	downloadDuration prometheus.Histogram
+	deliveries       *prometheus.CounterVec
+	repositoryErrors *prometheus.CounterVec
+}
+
+// New creates the bot metrics on a dedicated registry
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		updates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "updates_total",
+			Help:      "Telegram updates received, by update type.",
+		}, []string{"type"}),
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "commands_total",
+			Help:      "Bot commands received, by command.",
+		}, []string{"command"}),
+		callbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "callbacks_total",
+			Help:      "Inline button presses, by action.",
+		}, []string{"action"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rejected_updates_total",
+			Help:      "Messages and button presses not handled, by reason (banned, rate_limited).",
+		}, []string{"reason"}),
+		searches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "searches_total",
+			Help:      "Book searches performed, by result (found, empty, error).",
+		}, []string{"result"}),
+		searchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "search_duration_seconds",
+			Help:      "Book search latency.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		downloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "downloads_total",
+			Help:      "Book downloads, by format and result (success, error).",
+		}, []string{"format", "result"}),
+		downloadDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "download_duration_seconds",
+			Help:      "Book download latency.",
+			Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+		}),
+		deliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "deliveries_total",
+			Help:      "Book deliveries, by target (email, telegram), result (success, failure) and failure reason.",
+		}, []string{"target", "result", "reason"}),
+		repositoryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "repository_errors_total",
+			Help:      "User repository errors, by operation.",
+		}, []string{"operation"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.updates,
+		m.commands,
+		m.callbacks,
+		m.rejected,
+		m.searches,
+		m.searchDuration,
+		m.downloads,
+		m.downloadDuration,
+		m.deliveries,
+		m.repositoryErrors,
+	)
+
+	return m
+}
+
+// Handler returns the HTTP handler serving the /metrics endpoint
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// RegisterActiveUsers exports a gauge whose value is computed by fn on every scrape
+func (m *Metrics) RegisterActiveUsers(fn func() float64) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_users",
+		Help:      "Users active within the last 24 hours.",
+	}, fn))
+}
+
+// ObserveUpdate records a received Telegram update
+func (m *Metrics) ObserveUpdate(updateType string) {
+	if m == nil {
+		return
+	}
+	m.updates.WithLabelValues(updateType).Inc()
+}
+
+// ObserveCommand records a received bot command
+func (m *Metrics) ObserveCommand(command string) {
+	if m == nil {
+		return
+	}
+	m.commands.WithLabelValues(command).Inc()
+}
+
+// ObserveCallback records an inline button press
+func (m *Metrics) ObserveCallback(action string) {
+	if m == nil {
+		return
+	}
+	m.callbacks.WithLabelValues(action).Inc()
+}
+
+// UpdateRejected records a message or button press that was not handled
+func (m *Metrics) UpdateRejected(reason string) {
+	if m == nil {
+		return
+	}
+	m.rejected.WithLabelValues(reason).Inc()
+}
+
+// ObserveSearch records a completed search with its latency and result count
+func (m *Metrics) ObserveSearch(duration time.Duration, results int, err error) {
+	if m == nil {
+		return
+	}
+
+	result := "found"
+	switch {
+	case err != nil:
+		result = "error"
+	case results == 0:
+		result = "empty"
+	}
+
+	m.searches.WithLabelValues(result).Inc()
+	m.searchDuration.Observe(duration.Seconds())
+}
+
+// ObserveDownload records a completed book download
+func (m *Metrics) ObserveDownload(format string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+
+	m.downloads.WithLabelValues(format, result).Inc()
+	m.downloadDuration.Observe(duration.Seconds())
+}
+
+// DeliverySucceeded records a successful book delivery to target
+func (m *Metrics) DeliverySucceeded(target string) {
+	if m == nil {
+		return
+	}
+	m.deliveries.WithLabelValues(target, "success", "").Inc()
+}
+
+// DeliveryFailed records a failed book delivery to target with a short
+// failure reason such as "too_large", "invalid_format" or "send_error"
+func (m *Metrics) DeliveryFailed(target, reason string) {
+	if m == nil {
+		return
+	}
+	m.deliveries.WithLabelValues(target, "failure", reason).Inc()
+}
+
+// RepositoryError records a failed repository operation
+func (m *Metrics) RepositoryError(operation string) {
+	if m == nil {
+		return
+	}
+	m.repositoryErrors.WithLabelValues(operation).Inc()
+}
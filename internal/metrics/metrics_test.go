@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// failingRepository fails every write
+type failingRepository struct {
+	user.Repository
+}
+
+func (r *failingRepository) SaveUser(ctx context.Context, u *models.User) error {
+	return errors.New("database unavailable")
+}
+
+func TestMetrics_Observe(t *testing.T) {
+	m := New()
+
+	m.ObserveUpdate("message")
+	m.ObserveUpdate("message")
+	m.ObserveUpdate("callback_query")
+	m.ObserveCommand("start")
+	m.ObserveCallback("book")
+	m.UpdateRejected("rate_limited")
+	m.ObserveSearch(100*time.Millisecond, 5, nil)
+	m.ObserveSearch(50*time.Millisecond, 0, nil)
+	m.ObserveSearch(time.Second, 0, errors.New("timeout"))
+	m.ObserveDownload("epub", 2*time.Second, nil)
+	m.DeliverySucceeded("email")
+	m.DeliveryFailed("telegram", "too_large")
+	m.RepositoryError("get_user")
+
+	tests := []struct {
+		name  string
+		value float64
+		want  float64
+	}{
+		{"message updates", testutil.ToFloat64(m.updates.WithLabelValues("message")), 2},
+		{"callback updates", testutil.ToFloat64(m.updates.WithLabelValues("callback_query")), 1},
+		{"start commands", testutil.ToFloat64(m.commands.WithLabelValues("start")), 1},
+		{"book callbacks", testutil.ToFloat64(m.callbacks.WithLabelValues("book")), 1},
+		{"rate limited", testutil.ToFloat64(m.rejected.WithLabelValues("rate_limited")), 1},
+		{"found searches", testutil.ToFloat64(m.searches.WithLabelValues("found")), 1},
+		{"empty searches", testutil.ToFloat64(m.searches.WithLabelValues("empty")), 1},
+		{"failed searches", testutil.ToFloat64(m.searches.WithLabelValues("error")), 1},
+		{"downloads", testutil.ToFloat64(m.downloads.WithLabelValues("epub", "success")), 1},
+		{"delivered", testutil.ToFloat64(m.deliveries.WithLabelValues("email", "success", "")), 1},
+		{"delivery failures", testutil.ToFloat64(m.deliveries.WithLabelValues("telegram", "failure", "too_large")), 1},
+		{"repository errors", testutil.ToFloat64(m.repositoryErrors.WithLabelValues("get_user")), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.value != tt.want {
+				t.Errorf("value = %v, want %v", tt.value, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetrics_NilIsNoop(t *testing.T) {
+	var m *Metrics
+
+	// None of these should panic
+	m.ObserveUpdate("message")
+	m.ObserveCommand("start")
+	m.ObserveCallback("book")
+	m.UpdateRejected("banned")
+	m.ObserveSearch(time.Second, 1, nil)
+	m.ObserveDownload("epub", time.Second, nil)
+	m.DeliverySucceeded("email")
+	m.DeliveryFailed("email", "send_error")
+	m.RepositoryError("get_user")
+	m.RegisterActiveUsers(func() float64 { return 0 })
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	m := New()
+	m.ObserveUpdate("message")
+	m.RegisterActiveUsers(func() float64 { return 7 })
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, _ := io.ReadAll(rec.Body)
+	output := string(body)
+
+	for _, want := range []string{
+		`flibusta_bot_updates_total{type="message"} 1`,
+		`flibusta_bot_active_users 7`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("metrics output does not contain %q", want)
+		}
+	}
+}
+
+func TestInstrumentRepository(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	repo := InstrumentRepository(user.NewMemoryRepository(), m)
+
+	// Not found is expected and should not be counted
+	if _, err := repo.GetUser(ctx, 1); !errors.Is(err, user.ErrUserNotFound) {
+		t.Fatalf("GetUser() error = %v, want ErrUserNotFound", err)
+	}
+	if got := testutil.ToFloat64(m.repositoryErrors.WithLabelValues("get_user")); got != 0 {
+		t.Errorf("get_user errors = %v, want 0", got)
+	}
+
+	// Real failures are counted
+	failing := InstrumentRepository(&failingRepository{Repository: user.NewMemoryRepository()}, m)
+	if err := failing.SaveUser(ctx, &models.User{TelegramID: 1}); err == nil {
+		t.Fatal("SaveUser() should fail")
+	}
+	if got := testutil.ToFloat64(m.repositoryErrors.WithLabelValues("save_user")); got != 1 {
+		t.Errorf("save_user errors = %v, want 1", got)
+	}
+
+	manager := user.NewManager(repo)
+	if _, err := manager.GetOrCreateUser(ctx, 1, "test", "Test", "User", "en"); err != nil {
+		t.Fatalf("GetOrCreateUser() error = %v", err)
+	}
+
+	count, err := repo.CountActiveUsers(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("CountActiveUsers() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("CountActiveUsers() = %v, want %v", count, 1)
+	}
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// instrumentedRepository counts errors returned by a user repository
+type instrumentedRepository struct {
+	repo    user.Repository
+	metrics *Metrics
+}
+
+// InstrumentRepository wraps repo so that its errors are counted by operation.
+// ErrUserNotFound is an expected outcome and is not counted.
+func InstrumentRepository(repo user.Repository, m *Metrics) user.Repository {
+	return &instrumentedRepository{
+		repo:    repo,
+		metrics: m,
+	}
+}
+
+func (r *instrumentedRepository) observe(operation string, err error) error {
+	if err != nil && !errors.Is(err, user.ErrUserNotFound) {
+		r.metrics.RepositoryError(operation)
+	}
+	return err
+}
+
+// GetUser retrieves a user by Telegram ID
+func (r *instrumentedRepository) GetUser(ctx context.Context, telegramID int64) (*models.User, error) {
+	u, err := r.repo.GetUser(ctx, telegramID)
+	return u, r.observe("get_user", err)
+}
+
+// SaveUser creates or updates a user
+func (r *instrumentedRepository) SaveUser(ctx context.Context, u *models.User) error {
+	return r.observe("save_user", r.repo.SaveUser(ctx, u))
+}
+
+// ImportUser creates or replaces a user, keeping its UpdatedAt
+func (r *instrumentedRepository) ImportUser(ctx context.Context, u *models.User) error {
+	return r.observe("import_user", r.repo.ImportUser(ctx, u))
+}
+
+// UpdatePreferences updates user preferences
+func (r *instrumentedRepository) UpdatePreferences(ctx context.Context, telegramID int64, prefs *models.Preferences) error {
+	return r.observe("update_preferences", r.repo.UpdatePreferences(ctx, telegramID, prefs))
+}
+
+// MarkKindleVerified marks the user's Kindle verified
+func (r *instrumentedRepository) MarkKindleVerified(ctx context.Context, telegramID int64, email string, at time.Time) error {
+	err := r.repo.MarkKindleVerified(ctx, telegramID, email, at)
+	if errors.Is(err, user.ErrKindleEmailChanged) {
+		return err // An expected outcome, like ErrUserNotFound
+	}
+	return r.observe("mark_kindle_verified", err)
+}
+
+// IncrementBooksSent increments the books sent counter
+func (r *instrumentedRepository) IncrementBooksSent(ctx context.Context, telegramID int64) error {
+	return r.observe("increment_books_sent", r.repo.IncrementBooksSent(ctx, telegramID))
+}
+
+// UpdateLastActive updates the last active timestamp
+func (r *instrumentedRepository) UpdateLastActive(ctx context.Context, telegramID int64) error {
+	return r.observe("update_last_active", r.repo.UpdateLastActive(ctx, telegramID))
+}
+
+// DeactivateUsers marks users not seen since the given time as inactive
+func (r *instrumentedRepository) DeactivateUsers(ctx context.Context, since time.Time) (int, error) {
+	n, err := r.repo.DeactivateUsers(ctx, since)
+	return n, r.observe("deactivate_users", err)
+}
+
+// CountActiveUsers counts users active since the given time
+func (r *instrumentedRepository) CountActiveUsers(ctx context.Context, since time.Time) (int, error) {
+	n, err := r.repo.CountActiveUsers(ctx, since)
+	return n, r.observe("count_active_users", err)
+}
+
+// ListUsers returns all users ordered by Telegram ID
+func (r *instrumentedRepository) ListUsers(ctx context.Context) ([]*models.User, error) {
+	users, err := r.repo.ListUsers(ctx)
+	return users, r.observe("list_users", err)
+}
+
+// DeleteUser removes a user
+func (r *instrumentedRepository) DeleteUser(ctx context.Context, telegramID int64) error {
+	return r.observe("delete_user", r.repo.DeleteUser(ctx, telegramID))
+}
+
+// Ping checks that the storage backend is reachable
+func (r *instrumentedRepository) Ping(ctx context.Context) error {
+	return r.observe("ping", r.repo.Ping(ctx))
+}
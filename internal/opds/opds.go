@@ -0,0 +1,199 @@
+// Package opds reads Flibusta's OPDS catalog.
+package opds
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/tracing"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// maxFeedSize limits how much of a feed is read
+const maxFeedSize = 10 * 1024 * 1024
+
+var (
+	bookPath   = regexp.MustCompile(`^/b/(\d+)`)
+	authorPath = regexp.MustCompile(`^(?:/opds)?/a(?:uthor)?/(\d+)`)
+	seriesPath = regexp.MustCompile(`^(?:/opds/sequencebooks|/s)/(\d+)`)
+
+	// seriesTitle matches the title of a series link: Все книги серии "Name"
+	seriesTitle = regexp.MustCompile(`^[^"«]*["«](.+)["»]\s*$`)
+)
+
+// Entity is an author or series in the catalog
+type Entity struct {
+	ID   string
+	Name string
+}
+
+// Release is a book listed in a feed
+type Release struct {
+	BookID  string
+	Title   string
+	Authors []Entity
+	Series  []Entity
+}
+
+// Client reads feeds from the Flibusta site
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewClient creates a client for the Flibusta site at baseURL.
+// A nil client uses a client with a 30s timeout.
+func NewClient(baseURL string, client *http.Client) *Client {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  client,
+	}
+}
+
+// NewArrivals returns a page of the books most recently added to Flibusta,
+// newest first. Page 0 has the newest books; a page past the end is empty.
+func (c *Client) NewArrivals(ctx context.Context, page int) (releases []Release, err error) {
+	ctx, span := tracing.Start(ctx, "opds.NewArrivals", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.Int("opds.page", page)))
+	defer func() { tracing.End(span, err) }()
+
+	releases, err = c.fetchFeed(ctx, fmt.Sprintf("/opds/new/%d/new", page))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch new arrivals page %d: %w", page, err)
+	}
+	span.SetAttributes(attribute.Int("opds.releases", len(releases)))
+
+	return releases, nil
+}
+
+// Search returns the books whose title matches query, as Flibusta ranks
+// them. The books have no format, so the destination's format is sent.
+// It implements search.Searcher.
+func (c *Client) Search(ctx context.Context, query string) (books []models.Book, err error) {
+	ctx, span := tracing.Start(ctx, "opds.Search", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { tracing.End(span, err) }()
+
+	params := url.Values{"searchType": {"books"}, "searchTerm": {query}}
+	releases, err := c.fetchFeed(ctx, "/opds/search?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to search books: %w", err)
+	}
+	span.SetAttributes(attribute.Int("opds.releases", len(releases)))
+
+	books = make([]models.Book, 0, len(releases))
+	for _, r := range releases {
+		authors := make([]string, len(r.Authors))
+		for i, a := range r.Authors {
+			authors[i] = a.Name
+		}
+		book := models.Book{
+			ID:     r.BookID,
+			Title:  r.Title,
+			Author: strings.Join(authors, ", "),
+			URL:    c.baseURL + "/b/" + r.BookID,
+		}
+		if len(r.Authors) > 0 {
+			book.AuthorID = r.Authors[0].ID
+		}
+		if len(r.Series) > 0 {
+			book.Series, book.SeriesID = r.Series[0].Name, r.Series[0].ID
+		}
+		books = append(books, book)
+	}
+
+	return books, nil
+}
+
+// fetchFeed reads the feed at path, relative to the site
+func (c *Client) fetchFeed(ctx context.Context, path string) ([]Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create feed request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return ParseFeed(io.LimitReader(resp.Body, maxFeedSize))
+}
+
+// feed is the part of an OPDS acquisition feed we read
+type feed struct {
+	Entries []struct {
+		ID      string `xml:"id"`
+		Title   string `xml:"title"`
+		Authors []struct {
+			Name string `xml:"name"`
+			URI  string `xml:"uri"`
+		} `xml:"author"`
+		Links []struct {
+			Href  string `xml:"href,attr"`
+			Rel   string `xml:"rel,attr"`
+			Title string `xml:"title,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+// ParseFeed reads the book entries of an OPDS feed. Entries without a book
+// ID are skipped.
+func ParseFeed(r io.Reader) ([]Release, error) {
+	var f feed
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to parse feed: %w", err)
+	}
+
+	var releases []Release
+	for _, e := range f.Entries {
+		release := Release{Title: strings.TrimSpace(e.Title)}
+		if id, ok := strings.CutPrefix(e.ID, "tag:book:"); ok {
+			release.BookID = id
+		}
+
+		for _, a := range e.Authors {
+			if m := authorPath.FindStringSubmatch(a.URI); m != nil {
+				release.Authors = append(release.Authors, Entity{ID: m[1], Name: strings.TrimSpace(a.Name)})
+			}
+		}
+
+		for _, l := range e.Links {
+			if m := bookPath.FindStringSubmatch(l.Href); m != nil && release.BookID == "" {
+				release.BookID = m[1]
+			}
+			if m := seriesPath.FindStringSubmatch(l.Href); m != nil && l.Rel == "related" {
+				name := l.Title
+				if t := seriesTitle.FindStringSubmatch(l.Title); t != nil {
+					name = t[1]
+				}
+				release.Series = append(release.Series, Entity{ID: m[1], Name: name})
+			}
+		}
+
+		if release.BookID == "" {
+			continue
+		}
+		releases = append(releases, release)
+	}
+
+	return releases, nil
+}
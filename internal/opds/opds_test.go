@@ -0,0 +1,120 @@
+package opds
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+const testFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/terms/">
+  <id>tag:new:0</id>
+  <title>Новые книги</title>
+  <entry>
+    <id>tag:book:700</id>
+    <title>Пикник на обочине</title>
+    <author><name>Аркадий Стругацкий</name><uri>/a/3156</uri></author>
+    <author><name>Борис Стругацкий</name><uri>/a/3157</uri></author>
+    <link href="/opds/sequencebooks/1234" rel="related" type="application/atom+xml" title="Все книги серии &quot;Миры братьев Стругацких&quot;"/>
+    <link href="/opds/author/3156" rel="related" type="application/atom+xml" title="Все книги автора Аркадий Стругацкий"/>
+    <link href="/b/700/epub" rel="http://opds-spec.org/acquisition/open-access" type="application/epub+zip"/>
+  </entry>
+  <entry>
+    <id>urn:other:1</id>
+    <title>Без тега</title>
+    <link href="/b/701/fb2" rel="http://opds-spec.org/acquisition/open-access" type="application/fb2+zip"/>
+  </entry>
+  <entry>
+    <id>tag:author:5</id>
+    <title>Not a book</title>
+  </entry>
+</feed>`
+
+func TestParseFeed(t *testing.T) {
+	releases, err := ParseFeed(strings.NewReader(testFeed))
+	if err != nil {
+		t.Fatalf("ParseFeed() error = %v", err)
+	}
+
+	want := []Release{
+		{
+			BookID:  "700",
+			Title:   "Пикник на обочине",
+			Authors: []Entity{{ID: "3156", Name: "Аркадий Стругацкий"}, {ID: "3157", Name: "Борис Стругацкий"}},
+			Series:  []Entity{{ID: "1234", Name: "Миры братьев Стругацких"}},
+		},
+		{BookID: "701", Title: "Без тега"},
+	}
+	if !reflect.DeepEqual(releases, want) {
+		t.Errorf("ParseFeed() = %+v, want %+v", releases, want)
+	}
+
+	if _, err := ParseFeed(strings.NewReader("<feed")); err == nil {
+		t.Error("ParseFeed() accepted a broken feed")
+	}
+}
+
+func TestClient_NewArrivals(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/opds/new/1/new" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/atom+xml")
+		_, _ = w.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+
+	releases, err := NewClient(server.URL+"/", server.Client()).NewArrivals(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("NewArrivals() error = %v", err)
+	}
+	if len(releases) != 2 {
+		t.Errorf("NewArrivals() returned %d releases, want 2", len(releases))
+	}
+
+	if _, err := NewClient(server.URL+"/missing", server.Client()).NewArrivals(context.Background(), 1); err == nil {
+		t.Error("NewArrivals() error = nil for a missing feed")
+	}
+}
+
+func TestClient_Search(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/opds/search" || r.URL.Query().Get("searchType") != "books" || r.URL.Query().Get("searchTerm") != "пикник на обочине" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/atom+xml")
+		_, _ = w.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+
+	books, err := NewClient(server.URL, server.Client()).Search(context.Background(), "пикник на обочине")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(books) != 2 {
+		t.Fatalf("Search() returned %d books, want 2", len(books))
+	}
+	want := models.Book{
+		ID:       "700",
+		Title:    "Пикник на обочине",
+		Author:   "Аркадий Стругацкий, Борис Стругацкий",
+		AuthorID: "3156",
+		Series:   "Миры братьев Стругацких",
+		SeriesID: "1234",
+		URL:      server.URL + "/b/700",
+	}
+	if books[0] != want {
+		t.Errorf("Search()[0] = %+v, want %+v", books[0], want)
+	}
+
+	if _, err := NewClient(server.URL, server.Client()).Search(context.Background(), "other"); err == nil {
+		t.Error("Search() error = nil for a failed search")
+	}
+}
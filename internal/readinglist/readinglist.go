@@ -0,0 +1,74 @@
+// Package readinglist keeps the books users saved to send later.
+package readinglist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+var (
+	// ErrAlreadySaved is returned when the book is already on the list
+	ErrAlreadySaved = errors.New("book already on the reading list")
+	// ErrListFull is returned when the list holds the maximum number of books
+	ErrListFull = errors.New("reading list is full")
+)
+
+// Manager handles reading list business logic
+type Manager struct {
+	repo     Repository
+	maxBooks int
+}
+
+// NewManager creates a reading list manager allowing up to maxBooks per user
+func NewManager(repo Repository, maxBooks int) *Manager {
+	return &Manager{
+		repo:     repo,
+		maxBooks: maxBooks,
+	}
+}
+
+// Add saves a book to a user's list
+func (m *Manager) Add(ctx context.Context, telegramID int64, book *models.SavedBook) error {
+	if book.AddedAt.IsZero() {
+		book.AddedAt = time.Now()
+	}
+
+	err := m.repo.AddBook(ctx, telegramID, book, m.maxBooks)
+	if err != nil && !errors.Is(err, ErrAlreadySaved) && !errors.Is(err, ErrListFull) {
+		return fmt.Errorf("failed to save book: %w", err)
+	}
+	return err
+}
+
+// List returns a user's saved books, oldest first
+func (m *Manager) List(ctx context.Context, telegramID int64) ([]models.SavedBook, error) {
+	return m.repo.ListBooks(ctx, telegramID)
+}
+
+// Remove removes a book from a user's list
+func (m *Manager) Remove(ctx context.Context, telegramID int64, bookID string) error {
+	return m.repo.RemoveBook(ctx, telegramID, bookID)
+}
+
+// Name identifies the reading list in data exports
+func (m *Manager) Name() string {
+	return "reading_list"
+}
+
+// ExportUserData returns a user's saved books, or nil if there are none
+func (m *Manager) ExportUserData(ctx context.Context, telegramID int64) (interface{}, error) {
+	books, err := m.repo.ListBooks(ctx, telegramID)
+	if err != nil || len(books) == 0 {
+		return nil, err
+	}
+	return books, nil
+}
+
+// DeleteUserData removes a user's reading list
+func (m *Manager) DeleteUserData(ctx context.Context, telegramID int64) error {
+	return m.repo.DeleteList(ctx, telegramID)
+}
@@ -0,0 +1,97 @@
+package readinglist
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+var _ user.DataHolder = (*Manager)(nil)
+
+func TestManager(t *testing.T) {
+	m := NewManager(NewMemoryRepository(), 2)
+	ctx := context.Background()
+
+	if err := m.Add(ctx, 1, &models.SavedBook{BookID: "42", Title: "Dune"}); err != nil {
+		t.Fatalf("Add(42) error = %v", err)
+	}
+	if err := m.Add(ctx, 1, &models.SavedBook{BookID: "42"}); !errors.Is(err, ErrAlreadySaved) {
+		t.Errorf("Add(42) again error = %v, want ErrAlreadySaved", err)
+	}
+	if err := m.Add(ctx, 1, &models.SavedBook{BookID: "7", Format: "fb2"}); err != nil {
+		t.Fatalf("Add(7) error = %v", err)
+	}
+	if err := m.Add(ctx, 1, &models.SavedBook{BookID: "8"}); !errors.Is(err, ErrListFull) {
+		t.Errorf("Add(8) error = %v, want ErrListFull", err)
+	}
+
+	// Lists are per user
+	if err := m.Add(ctx, 2, &models.SavedBook{BookID: "42"}); err != nil {
+		t.Fatalf("Add(42) for user 2 error = %v", err)
+	}
+
+	books, err := m.List(ctx, 1)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(books) != 2 || books[0].BookID != "42" || books[1].BookID != "7" || books[0].AddedAt.IsZero() {
+		t.Errorf("List() = %+v, want 42 then 7 with AddedAt", books)
+	}
+
+	if books[1].Format != "fb2" {
+		t.Errorf("List()[1].Format = %q, want fb2", books[1].Format)
+	}
+
+	if err := m.Remove(ctx, 1, "42"); err != nil {
+		t.Fatalf("Remove(42) error = %v", err)
+	}
+	if books, _ := m.List(ctx, 1); len(books) != 1 || books[0].BookID != "7" {
+		t.Errorf("List() after Remove(42) = %+v, want only 7", books)
+	}
+	if err := m.Remove(ctx, 1, "42"); err != nil {
+		t.Errorf("Remove(42) again error = %v", err)
+	}
+
+	if err := m.DeleteUserData(ctx, 1); err != nil {
+		t.Fatalf("DeleteUserData() error = %v", err)
+	}
+	if data, _ := m.ExportUserData(ctx, 1); data != nil {
+		t.Errorf("ExportUserData(1) after delete = %v, want nil", data)
+	}
+	if data, _ := m.ExportUserData(ctx, 2); data == nil {
+		t.Error("ExportUserData(2) = nil, want the list")
+	}
+}
+
+func TestManager_Add_Concurrent(t *testing.T) {
+	m := NewManager(NewMemoryRepository(), 5)
+	ctx := context.Background()
+
+	// Concurrent adds of one book save it once, and never exceed the limit
+	var wg sync.WaitGroup
+	for n := 0; n < 20; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			m.Add(ctx, 1, &models.SavedBook{BookID: "42"})
+			m.Add(ctx, 1, &models.SavedBook{BookID: strconv.Itoa(n)})
+		}(n)
+	}
+	wg.Wait()
+
+	books, _ := m.List(ctx, 1)
+	saved := 0
+	for _, book := range books {
+		if book.BookID == "42" {
+			saved++
+		}
+	}
+	if len(books) != 5 || saved != 1 {
+		t.Errorf("List() = %d books with 42 saved %d times, want 5 and once", len(books), saved)
+	}
+}
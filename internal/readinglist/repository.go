@@ -0,0 +1,95 @@
+package readinglist
+
+import (
+	"context"
+	"sync"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// Repository stores users' reading lists
+type Repository interface {
+	// ListBooks returns a user's saved books, oldest first
+	ListBooks(ctx context.Context, telegramID int64) ([]models.SavedBook, error)
+
+	// AddBook appends a book to a user's list holding fewer than maxBooks.
+	// It fails with ErrAlreadySaved or ErrListFull, checked together with
+	// the append so concurrent adds cannot exceed the limit.
+	AddBook(ctx context.Context, telegramID int64, book *models.SavedBook, maxBooks int) error
+
+	// RemoveBook removes a book from a user's list; removing a missing book
+	// is not an error
+	RemoveBook(ctx context.Context, telegramID int64, bookID string) error
+
+	// DeleteList removes a user's whole list
+	DeleteList(ctx context.Context, telegramID int64) error
+}
+
+// MemoryRepository keeps reading lists in process memory. It is the only
+// Repository so far, so lists are lost when the bot restarts.
+type MemoryRepository struct {
+	lists map[int64][]models.SavedBook
+	mu    sync.RWMutex
+}
+
+// NewMemoryRepository creates a new in-memory repository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		lists: make(map[int64][]models.SavedBook),
+	}
+}
+
+// ListBooks returns a user's saved books, oldest first
+func (r *MemoryRepository) ListBooks(ctx context.Context, telegramID int64) ([]models.SavedBook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Return a copy to prevent external modifications
+	return append([]models.SavedBook(nil), r.lists[telegramID]...), nil
+}
+
+// AddBook appends a book to a user's list holding fewer than maxBooks
+func (r *MemoryRepository) AddBook(ctx context.Context, telegramID int64, book *models.SavedBook, maxBooks int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, saved := range r.lists[telegramID] {
+		if saved.BookID == book.BookID {
+			return ErrAlreadySaved
+		}
+	}
+	if len(r.lists[telegramID]) >= maxBooks {
+		return ErrListFull
+	}
+
+	r.lists[telegramID] = append(r.lists[telegramID], *book)
+	return nil
+}
+
+// RemoveBook removes a book from a user's list
+func (r *MemoryRepository) RemoveBook(ctx context.Context, telegramID int64, bookID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := r.lists[telegramID][:0]
+	for _, book := range r.lists[telegramID] {
+		if book.BookID != bookID {
+			list = append(list, book)
+		}
+	}
+	if len(list) == 0 {
+		delete(r.lists, telegramID)
+	} else {
+		r.lists[telegramID] = list
+	}
+	return nil
+}
+
+// DeleteList removes a user's whole list
+func (r *MemoryRepository) DeleteList(ctx context.Context, telegramID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.lists, telegramID)
+	return nil
+}
@@ -0,0 +1,110 @@
+package search
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// ContextStore keeps each user's active search session in memory.
+// Sessions expire after the store's TTL; expired sessions are ignored by Get
+// and removed by PurgeExpired.
+type ContextStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	contexts map[int64]*models.SearchContext
+	now      func() time.Time
+}
+
+// NewContextStore creates a store whose sessions live for ttl
+func NewContextStore(ttl time.Duration) *ContextStore {
+	return &ContextStore{
+		ttl:      ttl,
+		contexts: make(map[int64]*models.SearchContext),
+		now:      time.Now,
+	}
+}
+
+// Set starts a new search session for a user, replacing any previous one
+func (s *ContextStore) Set(telegramID int64, query string, results []models.Book) *models.SearchContext {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	sc := &models.SearchContext{
+		Query:     query,
+		Results:   results,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	s.contexts[telegramID] = sc
+
+	return sc
+}
+
+// Get returns a user's active search session
+func (s *ContextStore) Get(telegramID int64) (*models.SearchContext, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc, ok := s.contexts[telegramID]
+	if !ok || !s.now().Before(sc.ExpiresAt) {
+		return nil, false
+	}
+	return sc, true
+}
+
+// Delete ends a user's search session
+func (s *ContextStore) Delete(telegramID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.contexts, telegramID)
+}
+
+// PurgeExpired removes expired sessions and returns how many were removed
+func (s *ContextStore) PurgeExpired() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	purged := 0
+	for id, sc := range s.contexts {
+		if !now.Before(sc.ExpiresAt) {
+			delete(s.contexts, id)
+			purged++
+		}
+	}
+
+	return purged
+}
+
+// Len returns the number of stored sessions, including expired ones
+func (s *ContextStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.contexts)
+}
+
+// Name implements user.DataHolder
+func (s *ContextStore) Name() string {
+	return "search"
+}
+
+// ExportUserData implements user.DataHolder
+func (s *ContextStore) ExportUserData(ctx context.Context, telegramID int64) (interface{}, error) {
+	sc, ok := s.Get(telegramID)
+	if !ok {
+		return nil, nil
+	}
+	return sc, nil
+}
+
+// DeleteUserData implements user.DataHolder
+func (s *ContextStore) DeleteUserData(ctx context.Context, telegramID int64) error {
+	s.Delete(telegramID)
+	return nil
+}
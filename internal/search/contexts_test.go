@@ -0,0 +1,53 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+var _ user.DataHolder = (*ContextStore)(nil)
+
+func TestContextStore(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	store := NewContextStore(10 * time.Minute)
+	store.now = func() time.Time { return now }
+
+	store.Set(1, "tolstoy", []models.Book{{ID: "1", Title: "War and Peace"}})
+	now = now.Add(5 * time.Minute)
+	store.Set(2, "chekhov", nil)
+
+	if sc, ok := store.Get(1); !ok || sc.Query != "tolstoy" {
+		t.Fatalf("Get(1) = %v, %v, want tolstoy session", sc, ok)
+	}
+
+	// The first session expires, the second is still active
+	now = now.Add(5 * time.Minute)
+	if _, ok := store.Get(1); ok {
+		t.Error("Get(1) returned an expired session")
+	}
+	if _, ok := store.Get(2); !ok {
+		t.Error("Get(2) did not return the active session")
+	}
+
+	if purged := store.PurgeExpired(); purged != 1 {
+		t.Errorf("PurgeExpired() = %d, want 1", purged)
+	}
+	if store.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", store.Len())
+	}
+
+	ctx := context.Background()
+	if data, _ := store.ExportUserData(ctx, 2); data == nil {
+		t.Error("ExportUserData(2) = nil, want the session")
+	}
+	if err := store.DeleteUserData(ctx, 2); err != nil {
+		t.Fatalf("DeleteUserData() error = %v", err)
+	}
+	if data, _ := store.ExportUserData(ctx, 2); data != nil {
+		t.Errorf("ExportUserData(2) after delete = %v, want nil", data)
+	}
+}
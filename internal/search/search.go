@@ -0,0 +1,91 @@
+package search
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// Searcher finds books matching a free-text query
+type Searcher interface {
+	Search(ctx context.Context, query string) ([]models.Book, error)
+}
+
+// cacheEntry holds the results of one query
+type cacheEntry struct {
+	books   []models.Book
+	expires time.Time
+}
+
+// Cache is a Searcher remembering results for a while, so paging through
+// inline results or repeating a popular query does not hit Flibusta again.
+// Failed searches are not cached.
+type Cache struct {
+	searcher   Searcher
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	now     func() time.Time
+}
+
+// NewCache wraps searcher, keeping up to maxEntries queries for ttl
+func NewCache(searcher Searcher, ttl time.Duration, maxEntries int) *Cache {
+	return &Cache{
+		searcher:   searcher,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]cacheEntry),
+		now:        time.Now,
+	}
+}
+
+// Search returns cached results for query, searching on a miss. Queries
+// differing only in case or spacing share an entry.
+func (c *Cache) Search(ctx context.Context, query string) ([]models.Book, error) {
+	key := strings.ToLower(strings.Join(strings.Fields(query), " "))
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.books, nil
+	}
+
+	books, err := c.searcher.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[key] = cacheEntry{books: books, expires: c.now().Add(c.ttl)}
+
+	return books, nil
+}
+
+// evict removes expired entries, or the one expiring first if none has
+// expired. The caller must hold c.mu.
+func (c *Cache) evict() {
+	now := c.now()
+	oldest := ""
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldest == "" || entry.expires.Before(c.entries[oldest].expires) {
+			oldest = key
+		}
+	}
+	if len(c.entries) >= c.maxEntries && oldest != "" {
+		delete(c.entries, oldest)
+	}
+}
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// countingSearcher returns one book per query and counts the calls
+type countingSearcher struct {
+	calls int
+	err   error
+}
+
+func (s *countingSearcher) Search(ctx context.Context, query string) ([]models.Book, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []models.Book{{ID: "1", Title: query}}, nil
+}
+
+func TestCache_Search(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	searcher := &countingSearcher{}
+	cache := NewCache(searcher, time.Minute, 2)
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	cache.Search(ctx, "Tolstoy")
+	if books, _ := cache.Search(ctx, "  tolstoy "); len(books) != 1 || books[0].Title != "Tolstoy" {
+		t.Errorf("Search() = %v, want cached Tolstoy", books)
+	}
+	if searcher.calls != 1 {
+		t.Errorf("searcher called %d times, want 1", searcher.calls)
+	}
+
+	// Expired entries are searched again
+	now = now.Add(time.Minute)
+	cache.Search(ctx, "tolstoy")
+	if searcher.calls != 2 {
+		t.Errorf("searcher called %d times after expiry, want 2", searcher.calls)
+	}
+
+	// The entry expiring first makes room for new queries
+	now = now.Add(time.Second)
+	cache.Search(ctx, "chekhov")
+	cache.Search(ctx, "gogol")
+	if len(cache.entries) != 2 {
+		t.Errorf("cache holds %d entries, want 2", len(cache.entries))
+	}
+	if _, ok := cache.entries["tolstoy"]; ok {
+		t.Error("oldest entry was not evicted")
+	}
+
+	// Failures are not cached
+	searcher.err = errors.New("flibusta down")
+	if _, err := cache.Search(ctx, "pushkin"); err == nil {
+		t.Error("Search() error = nil, want the searcher's error")
+	}
+	if _, ok := cache.entries["pushkin"]; ok {
+		t.Error("failed search was cached")
+	}
+}
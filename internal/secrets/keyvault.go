@@ -0,0 +1,205 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// keyVaultAPIVersion is the Key Vault REST API version used for secret reads
+	keyVaultAPIVersion = "7.4"
+
+	// keyVaultResource is the token audience for Key Vault
+	keyVaultResource = "https://vault.azure.net"
+
+	// imdsEndpoint is the Azure Instance Metadata Service token endpoint,
+	// used when no App Service / Container Apps identity endpoint is set
+	imdsEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+	// tokenExpiryMargin renews tokens before they expire
+	tokenExpiryMargin = 5 * time.Minute
+)
+
+// Provider fetches secret values
+type Provider interface {
+	GetSecret(ctx context.Context, ref Reference) (string, error)
+}
+
+// TokenSource provides bearer tokens for Key Vault requests
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// KeyVaultClient reads secrets through the Key Vault REST API
+type KeyVaultClient struct {
+	tokens TokenSource
+	client *http.Client
+}
+
+// NewKeyVaultClient creates a Key Vault client. A nil client uses a client with a 30s timeout.
+func NewKeyVaultClient(tokens TokenSource, client *http.Client) *KeyVaultClient {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	return &KeyVaultClient{
+		tokens: tokens,
+		client: client,
+	}
+}
+
+// GetSecret returns the value of the referenced secret
+func (c *KeyVaultClient) GetSecret(ctx context.Context, ref Reference) (string, error) {
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get Key Vault token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref.String()+"?api-version="+keyVaultAPIVersion, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Key Vault request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", ref.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get secret %s: %s", ref.Name, errorMessage(resp))
+	}
+
+	var secret struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("failed to decode secret %s: %w", ref.Name, err)
+	}
+
+	return secret.Value, nil
+}
+
+// errorMessage extracts the error from an Azure error response
+func errorMessage(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	var azureErr struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &azureErr) == nil && azureErr.Error.Code != "" {
+		return fmt.Sprintf("%s: %s (%s)", resp.Status, azureErr.Error.Message, azureErr.Error.Code)
+	}
+
+	return resp.Status
+}
+
+// ManagedIdentityTokenSource gets Key Vault tokens from the Azure managed
+// identity endpoint and caches them until shortly before they expire
+type ManagedIdentityTokenSource struct {
+	endpoint string
+	header   string // X-IDENTITY-HEADER value; empty when using IMDS
+	clientID string
+	client   *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewManagedIdentityTokenSource creates a token source for the managed identity
+// of the current App Service or Container App (IDENTITY_ENDPOINT and
+// IDENTITY_HEADER), falling back to IMDS on virtual machines. clientID selects
+// a user-assigned identity; leave it empty for the system-assigned one.
+// A nil client uses a client with a 30s timeout.
+func NewManagedIdentityTokenSource(clientID string, client *http.Client) *ManagedIdentityTokenSource {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	ts := &ManagedIdentityTokenSource{
+		endpoint: os.Getenv("IDENTITY_ENDPOINT"),
+		header:   os.Getenv("IDENTITY_HEADER"),
+		clientID: clientID,
+		client:   client,
+	}
+	if ts.endpoint == "" {
+		ts.endpoint = imdsEndpoint
+	}
+
+	return ts
+}
+
+// Token returns a cached token or requests a new one
+func (ts *ManagedIdentityTokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != "" && time.Now().Before(ts.expires.Add(-tokenExpiryMargin)) {
+		return ts.token, nil
+	}
+
+	query := url.Values{"resource": {keyVaultResource}}
+	if ts.header != "" {
+		query.Set("api-version", "2019-08-01")
+	} else {
+		query.Set("api-version", "2018-02-01")
+	}
+	if ts.clientID != "" {
+		query.Set("client_id", ts.clientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	if ts.header != "" {
+		req.Header.Set("X-IDENTITY-HEADER", ts.header)
+	} else {
+		req.Header.Set("Metadata", "true")
+	}
+
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request managed identity token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to request managed identity token: %s", errorMessage(resp))
+	}
+
+	var token struct {
+		AccessToken string          `json:"access_token"`
+		ExpiresOn   json.RawMessage `json:"expires_on"` // Unix seconds, as a string or a number
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode managed identity token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("managed identity endpoint returned an empty token")
+	}
+
+	expiresOn, err := strconv.ParseInt(strings.Trim(string(token.ExpiresOn), `"`), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid managed identity token expiry: %s", token.ExpiresOn)
+	}
+
+	ts.token = token.AccessToken
+	ts.expires = time.Unix(expiresOn, 0)
+
+	return ts.token, nil
+}
@@ -0,0 +1,148 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeVault is a local stand-in for the Key Vault secrets API
+type fakeVault struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	secrets  map[string]string
+	requests int
+}
+
+func newFakeVault(t *testing.T, token string) *fakeVault {
+	t.Helper()
+
+	v := &fakeVault{secrets: make(map[string]string)}
+	v.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.requests++
+
+		if r.URL.Query().Get("api-version") != keyVaultAPIVersion {
+			t.Errorf("api-version = %v, want %v", r.URL.Query().Get("api-version"), keyVaultAPIVersion)
+		}
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, "/secrets/")
+		value, ok := v.secrets[strings.Split(name, "/")[0]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]string{"code": "SecretNotFound", "message": "Secret not found: " + name},
+			})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"value": value, "id": v.URL + r.URL.Path})
+	}))
+	t.Cleanup(v.Close)
+
+	return v
+}
+
+func (v *fakeVault) set(name, value string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.secrets[name] = value
+}
+
+func (v *fakeVault) ref(name string) string {
+	return "@Microsoft.KeyVault(SecretUri=" + v.URL + "/secrets/" + name + ")"
+}
+
+type staticToken string
+
+func (s staticToken) Token(context.Context) (string, error) { return string(s), nil }
+
+func TestKeyVaultClient_GetSecret(t *testing.T) {
+	vault := newFakeVault(t, "vault-token")
+	vault.set("telegram-bot-token", "123456:token")
+
+	client := NewKeyVaultClient(staticToken("vault-token"), vault.Client())
+
+	ref, err := ParseReference(vault.ref("telegram-bot-token"))
+	if err != nil {
+		t.Fatalf("ParseReference() error = %v", err)
+	}
+
+	got, err := client.GetSecret(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("GetSecret() error = %v", err)
+	}
+	if got != "123456:token" {
+		t.Errorf("GetSecret() = %v, want %v", got, "123456:token")
+	}
+
+	// Missing secrets report the vault error
+	ref.Name = "missing"
+	_, err = client.GetSecret(context.Background(), ref)
+	if err == nil || !strings.Contains(err.Error(), "SecretNotFound") {
+		t.Errorf("GetSecret() error = %v, want SecretNotFound", err)
+	}
+
+	// Requests with a wrong token are rejected
+	client = NewKeyVaultClient(staticToken("wrong"), vault.Client())
+	ref.Name = "telegram-bot-token"
+	if _, err := client.GetSecret(context.Background(), ref); err == nil {
+		t.Error("Expected error for unauthorized request, got nil")
+	}
+}
+
+func TestManagedIdentityTokenSource(t *testing.T) {
+	requests := 0
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.Header.Get("X-IDENTITY-HEADER") != "identity-secret" {
+			t.Errorf("X-IDENTITY-HEADER = %v, want identity-secret", r.Header.Get("X-IDENTITY-HEADER"))
+		}
+		if r.URL.Query().Get("resource") != keyVaultResource {
+			t.Errorf("resource = %v, want %v", r.URL.Query().Get("resource"), keyVaultResource)
+		}
+		if r.URL.Query().Get("client_id") != "client-id" {
+			t.Errorf("client_id = %v, want client-id", r.URL.Query().Get("client_id"))
+		}
+
+		expiresOn := time.Now().Add(time.Hour).Unix()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "vault-token",
+			"expires_on":   strconv.FormatInt(expiresOn, 10), // Azure returns a string
+		})
+	}))
+	defer identity.Close()
+
+	t.Setenv("IDENTITY_ENDPOINT", identity.URL)
+	t.Setenv("IDENTITY_HEADER", "identity-secret")
+
+	ts := NewManagedIdentityTokenSource("client-id", identity.Client())
+
+	for i := 0; i < 2; i++ {
+		token, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+		if token != "vault-token" {
+			t.Errorf("Token() = %v, want vault-token", token)
+		}
+	}
+
+	// The second call is served from the cache
+	if requests != 1 {
+		t.Errorf("Token requests = %d, want 1", requests)
+	}
+}
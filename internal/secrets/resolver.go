@@ -0,0 +1,132 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+)
+
+// Resolver resolves Key Vault references and keeps the resolved values
+// up to date. It is safe for concurrent use.
+type Resolver struct {
+	provider Provider
+
+	mu        sync.RWMutex
+	resolved  map[string]*resolvedSecret // Keyed by setting name
+	listeners []func(name, value string)
+}
+
+type resolvedSecret struct {
+	ref   Reference
+	value string
+}
+
+// NewResolver creates a resolver fetching secrets from provider
+func NewResolver(provider Provider) *Resolver {
+	return &Resolver{
+		provider: provider,
+		resolved: make(map[string]*resolvedSecret),
+	}
+}
+
+// Resolve returns value unchanged unless it is a Key Vault reference. References
+// are fetched and tracked under name so that Refresh can pick up new versions.
+func (r *Resolver) Resolve(ctx context.Context, name, value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+
+	ref, err := ParseReference(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	secret, err := r.provider.GetSecret(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", name, err)
+	}
+
+	r.mu.Lock()
+	r.resolved[name] = &resolvedSecret{ref: ref, value: secret}
+	r.mu.Unlock()
+
+	return secret, nil
+}
+
+// Get returns the current value of a resolved setting
+func (r *Resolver) Get(name string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.resolved[name]
+	if !ok {
+		return "", false
+	}
+	return s.value, true
+}
+
+// OnChange registers fn to be called when Refresh finds a new value
+func (r *Resolver) OnChange(fn func(name, value string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.listeners = append(r.listeners, fn)
+}
+
+// Refresh fetches every resolved secret again. Secrets that fail to refresh
+// keep their previous value.
+func (r *Resolver) Refresh(ctx context.Context) error {
+	r.mu.RLock()
+	refs := make(map[string]Reference, len(r.resolved))
+	for name, s := range r.resolved {
+		refs[name] = s.ref
+	}
+	r.mu.RUnlock()
+
+	var errs []error
+	for name, ref := range refs {
+		value, err := r.provider.GetSecret(ctx, ref)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to refresh %s: %w", name, err))
+			continue
+		}
+
+		r.mu.Lock()
+		s := r.resolved[name]
+		changed := s.value != value
+		s.value = value
+		listeners := r.listeners
+		r.mu.Unlock()
+
+		if changed {
+			for _, fn := range listeners {
+				fn(name, value)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Run refreshes secrets every interval until ctx is cancelled
+func (r *Resolver) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			logging.Safely(ctx, "Panic while refreshing secrets", func() {
+				if err := r.Refresh(ctx); err != nil {
+					logging.FromContext(ctx).Warn("Failed to refresh secrets", "error", err)
+				}
+			})
+		}
+	}
+}
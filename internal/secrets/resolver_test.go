@@ -0,0 +1,82 @@
+package secrets
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResolver(t *testing.T) {
+	vault := newFakeVault(t, "vault-token")
+	vault.set("webhook-secret", "first")
+
+	resolver := NewResolver(NewKeyVaultClient(staticToken("vault-token"), vault.Client()))
+	ctx := context.Background()
+
+	// Plain values are returned unchanged without contacting the vault
+	got, err := resolver.Resolve(ctx, "DB_PASSWORD", "plain")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if got != "plain" {
+		t.Errorf("Resolve() = %v, want plain", got)
+	}
+	if _, ok := resolver.Get("DB_PASSWORD"); ok {
+		t.Error("Plain values should not be tracked")
+	}
+
+	got, err = resolver.Resolve(ctx, "WEBHOOK_SECRET", vault.ref("webhook-secret"))
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if got != "first" {
+		t.Errorf("Resolve() = %v, want first", got)
+	}
+
+	var changes []string
+	resolver.OnChange(func(name, value string) {
+		changes = append(changes, name+"="+value)
+	})
+
+	// Refresh without changes does not notify
+	if err := resolver.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Changes = %v, want none", changes)
+	}
+
+	// A rotated secret is picked up and reported
+	vault.set("webhook-secret", "second")
+	if err := resolver.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if value, _ := resolver.Get("WEBHOOK_SECRET"); value != "second" {
+		t.Errorf("Get() = %v, want second", value)
+	}
+	if len(changes) != 1 || changes[0] != "WEBHOOK_SECRET=second" {
+		t.Errorf("Changes = %v, want [WEBHOOK_SECRET=second]", changes)
+	}
+
+	// A failed refresh keeps the last known value
+	vault.mu.Lock()
+	delete(vault.secrets, "webhook-secret")
+	vault.mu.Unlock()
+	if err := resolver.Refresh(ctx); err == nil {
+		t.Error("Expected refresh error, got nil")
+	}
+	if value, _ := resolver.Get("WEBHOOK_SECRET"); value != "second" {
+		t.Errorf("Get() = %v, want second", value)
+	}
+}
+
+func TestResolver_Errors(t *testing.T) {
+	vault := newFakeVault(t, "vault-token")
+	resolver := NewResolver(NewKeyVaultClient(staticToken("vault-token"), vault.Client()))
+
+	if _, err := resolver.Resolve(context.Background(), "COSMOS_KEY", vault.ref("missing")); err == nil {
+		t.Error("Expected error for missing secret, got nil")
+	}
+	if _, err := resolver.Resolve(context.Background(), "COSMOS_KEY", "@Microsoft.KeyVault(VaultName=kv)"); err == nil {
+		t.Error("Expected error for invalid reference, got nil")
+	}
+}
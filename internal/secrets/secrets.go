@@ -0,0 +1,112 @@
+package secrets
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// referencePrefix marks a Key Vault reference, using the same syntax as
+// Azure App Service and Container Apps
+const referencePrefix = "@Microsoft.KeyVault("
+
+// Reference identifies a secret in Azure Key Vault
+type Reference struct {
+	VaultURL string // e.g. https://my-vault.vault.azure.net
+	Name     string
+	Version  string // Empty for the latest version
+}
+
+// String returns the secret URI
+func (r Reference) String() string {
+	uri := r.VaultURL + "/secrets/" + r.Name
+	if r.Version != "" {
+		uri += "/" + r.Version
+	}
+	return uri
+}
+
+// IsReference reports whether value is a Key Vault reference
+func IsReference(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), referencePrefix)
+}
+
+// ParseReference parses a Key Vault reference in one of the forms
+//
+//	@Microsoft.KeyVault(SecretUri=https://my-vault.vault.azure.net/secrets/name/version)
+//	@Microsoft.KeyVault(VaultName=my-vault;SecretName=name;SecretVersion=version)
+//
+// The version is optional in both forms.
+func ParseReference(value string) (Reference, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, referencePrefix) || !strings.HasSuffix(value, ")") {
+		return Reference{}, fmt.Errorf("not a Key Vault reference: must look like %sSecretUri=...)", referencePrefix)
+	}
+
+	params := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(value, referencePrefix), ")"), ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(val)
+	}
+
+	if uri := params["secreturi"]; uri != "" {
+		return parseSecretURI(uri)
+	}
+
+	if params["vaultname"] == "" || params["secretname"] == "" {
+		return Reference{}, fmt.Errorf("invalid Key Vault reference: SecretUri or VaultName and SecretName are required")
+	}
+
+	return Reference{
+		VaultURL: "https://" + params["vaultname"] + ".vault.azure.net",
+		Name:     params["secretname"],
+		Version:  params["secretversion"],
+	}, nil
+}
+
+// parseSecretURI parses https://{vault}/secrets/{name}[/{version}]
+func parseSecretURI(uri string) (Reference, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Reference{}, fmt.Errorf("invalid Key Vault secret URI: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		// Requests carry a bearer token, so never send them in clear text
+		return Reference{}, fmt.Errorf("invalid Key Vault secret URI: %s (must be https)", uri)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] != "secrets" || parts[1] == "" {
+		return Reference{}, fmt.Errorf("invalid Key Vault secret URI: %s (must be https://{vault}/secrets/{name}[/{version}])", uri)
+	}
+
+	ref := Reference{
+		VaultURL: u.Scheme + "://" + u.Host,
+		Name:     parts[1],
+	}
+	if len(parts) == 3 {
+		ref.Version = parts[2]
+	}
+
+	return ref, nil
+}
+
+// ReadFile reads a secret from a file, as mounted by Docker and Kubernetes
+// secrets. Trailing newlines are removed.
+func ReadFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	value := strings.TrimRight(string(data), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Reference
+		wantErr bool
+	}{
+		{
+			name:  "secret uri",
+			value: "@Microsoft.KeyVault(SecretUri=https://bot-kv.vault.azure.net/secrets/telegram-bot-token/)",
+			want:  Reference{VaultURL: "https://bot-kv.vault.azure.net", Name: "telegram-bot-token"},
+		},
+		{
+			name:  "secret uri with version",
+			value: "@Microsoft.KeyVault(SecretUri=https://bot-kv.vault.azure.net/secrets/telegram-bot-token/abc123)",
+			want:  Reference{VaultURL: "https://bot-kv.vault.azure.net", Name: "telegram-bot-token", Version: "abc123"},
+		},
+		{
+			name:  "vault and secret name",
+			value: "@Microsoft.KeyVault(VaultName=bot-kv;SecretName=db-password;SecretVersion=v2)",
+			want:  Reference{VaultURL: "https://bot-kv.vault.azure.net", Name: "db-password", Version: "v2"},
+		},
+		{
+			name:    "plain http uri",
+			value:   "@Microsoft.KeyVault(SecretUri=http://bot-kv.vault.azure.net/secrets/token)",
+			wantErr: true,
+		},
+		{
+			name:    "not a secret path",
+			value:   "@Microsoft.KeyVault(SecretUri=https://bot-kv.vault.azure.net/keys/token)",
+			wantErr: true,
+		},
+		{
+			name:    "missing secret name",
+			value:   "@Microsoft.KeyVault(VaultName=bot-kv)",
+			wantErr: true,
+		},
+		{
+			name:    "plain value",
+			value:   "123456:token",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReference(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseReference() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsReference(t *testing.T) {
+	if !IsReference(" @Microsoft.KeyVault(VaultName=kv;SecretName=token)") {
+		t.Error("IsReference() = false for a Key Vault reference")
+	}
+	if IsReference("123456:token") {
+		t.Error("IsReference() = true for a plain value")
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "token")
+	if err := os.WriteFile(path, []byte("123456:token\n"), 0600); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+
+	got, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if got != "123456:token" {
+		t.Errorf("ReadFile() = %q, want %q", got, "123456:token")
+	}
+
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+	if _, err := ReadFile(empty); err == nil {
+		t.Error("Expected error for empty secret file, got nil")
+	}
+
+	if _, err := ReadFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected error for missing secret file, got nil")
+	}
+}
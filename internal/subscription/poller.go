@@ -0,0 +1,184 @@
+package subscription
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/opds"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// maxArrivalPages bounds the feed pages read in one poll, should the books
+// of the last poll have scrolled out of reach
+const maxArrivalPages = 10
+
+// Source lists the books recently added to Flibusta, a page at a time
+// starting at page 0
+type Source interface {
+	NewArrivals(ctx context.Context, page int) ([]opds.Release, error)
+}
+
+// Notifier tells a user about a new book from an entity they follow
+type Notifier interface {
+	NotifyRelease(ctx context.Context, telegramID int64, follow *models.Follow, release *opds.Release) error
+}
+
+// Poller checks the new arrivals feed for books from followed entities
+type Poller struct {
+	follows  *Manager
+	source   Source
+	notifier Notifier
+
+	mu     sync.Mutex
+	primed bool
+	seen   map[string]bool // Book IDs read in the last poll
+}
+
+// NewPoller creates a poller notifying followers through notifier
+func NewPoller(follows *Manager, source Source, notifier Notifier) *Poller {
+	return &Poller{
+		follows:  follows,
+		source:   source,
+		notifier: notifier,
+		seen:     make(map[string]bool),
+	}
+}
+
+// Poll fetches the feed and notifies followers about books not seen in the
+// previous poll. Pages are read until one reaches the books of the previous
+// poll, up to maxArrivalPages. The first poll only records the newest page,
+// so a restart does not announce old books again. Returns the number of
+// notifications sent.
+func (p *Poller) Poll(ctx context.Context) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	releases, err := p.newReleases(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	seen := make(map[string]bool, len(releases))
+	notified := 0
+	for i := range releases {
+		// A book can show up twice when the feed moves between pages
+		if seen[releases[i].BookID] {
+			continue
+		}
+		seen[releases[i].BookID] = true
+		if p.primed && !p.seen[releases[i].BookID] {
+			notified += p.announce(ctx, &releases[i])
+		}
+	}
+
+	// Books leave the feed as newer ones arrive and never come back
+	p.seen = seen
+	p.primed = true
+
+	return notified, nil
+}
+
+// newReleases reads feed pages until one holds a book of the last poll
+func (p *Poller) newReleases(ctx context.Context) ([]opds.Release, error) {
+	var releases []opds.Release
+	for page := 0; page < maxArrivalPages; page++ {
+		batch, err := p.source.NewArrivals(ctx, page)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, batch...)
+
+		if !p.primed || len(batch) == 0 {
+			return releases, nil
+		}
+		for _, r := range batch {
+			if p.seen[r.BookID] {
+				return releases, nil
+			}
+		}
+	}
+
+	logging.FromContext(ctx).Warn("New arrivals did not reach the last poll", "pages", maxArrivalPages)
+	return releases, nil
+}
+
+// announce notifies each follower of the release's authors and series once
+func (p *Poller) announce(ctx context.Context, release *opds.Release) int {
+	logger := logging.FromContext(ctx).With("book_id", release.BookID)
+
+	type entity struct {
+		kind string
+		opds.Entity
+	}
+	var entities []entity
+	for _, a := range release.Authors {
+		entities = append(entities, entity{models.FollowAuthor, a})
+	}
+	for _, s := range release.Series {
+		entities = append(entities, entity{models.FollowSeries, s})
+	}
+
+	notified := make(map[int64]bool)
+	for _, e := range entities {
+		followers, err := p.follows.repo.ListFollowers(ctx, e.kind, e.ID)
+		if err != nil {
+			logger.Error("Failed to list followers", "kind", e.kind, "entity_id", e.ID, "error", err)
+			continue
+		}
+
+		for _, f := range followers {
+			if notified[f.TelegramID] {
+				continue
+			}
+			notified[f.TelegramID] = true
+
+			// Follows made from a link have no name until the first release
+			if f.Follow.Name == "" && e.Name != "" {
+				f.Follow.Name = e.Name
+				if err := p.follows.repo.SaveFollow(ctx, f.TelegramID, &f.Follow); err != nil {
+					logger.Warn("Failed to save follow name", "error", err)
+				}
+			}
+
+			ctx := logging.With(ctx, "book_id", release.BookID, "user_id", f.TelegramID)
+			logging.Safely(ctx, "Panic while notifying follower", func() {
+				if err := p.notifier.NotifyRelease(ctx, f.TelegramID, &f.Follow, release); err != nil {
+					logging.FromContext(ctx).Warn("Failed to notify follower", "error", err)
+				}
+			})
+		}
+	}
+
+	return len(notified)
+}
+
+// Start polls every interval until ctx is cancelled
+func (p *Poller) Start(ctx context.Context, interval time.Duration) {
+	logging.Safely(ctx, "Panic while polling new arrivals", func() { p.tick(ctx, false) })
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			logging.Safely(ctx, "Panic while polling new arrivals", func() { p.tick(ctx, true) })
+		}
+	}
+}
+
+// tick polls once, logging the outcome
+func (p *Poller) tick(ctx context.Context, logResult bool) {
+	n, err := p.Poll(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to poll new arrivals", "error", err)
+		return
+	}
+	if logResult {
+		logging.FromContext(ctx).Info("Polled new arrivals", "notifications", n)
+	}
+}
@@ -0,0 +1,121 @@
+package subscription
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// Follower is a user following an entity
+type Follower struct {
+	TelegramID int64
+	Follow     models.Follow
+}
+
+// Repository stores what users follow
+type Repository interface {
+	// ListFollows returns a user's follows, oldest first
+	ListFollows(ctx context.Context, telegramID int64) ([]models.Follow, error)
+
+	// SaveFollow creates or updates a follow
+	SaveFollow(ctx context.Context, telegramID int64, follow *models.Follow) error
+
+	// RemoveFollow removes a follow; removing a missing follow is not an error
+	RemoveFollow(ctx context.Context, telegramID int64, kind, entityID string) error
+
+	// ListFollowers returns the users following an entity, ordered by Telegram ID
+	ListFollowers(ctx context.Context, kind, entityID string) ([]Follower, error)
+
+	// DeleteFollows removes all of a user's follows
+	DeleteFollows(ctx context.Context, telegramID int64) error
+}
+
+// MemoryRepository keeps follows in process memory. It is the only
+// Repository so far, so follows are lost when the bot restarts.
+type MemoryRepository struct {
+	follows map[int64][]models.Follow
+	mu      sync.RWMutex
+}
+
+// NewMemoryRepository creates a new in-memory repository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		follows: make(map[int64][]models.Follow),
+	}
+}
+
+// ListFollows returns a user's follows, oldest first
+func (r *MemoryRepository) ListFollows(ctx context.Context, telegramID int64) ([]models.Follow, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Return a copy to prevent external modifications
+	return append([]models.Follow(nil), r.follows[telegramID]...), nil
+}
+
+// SaveFollow creates or updates a follow
+func (r *MemoryRepository) SaveFollow(ctx context.Context, telegramID int64, follow *models.Follow) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	follows := r.follows[telegramID]
+	for i := range follows {
+		if follows[i].Kind == follow.Kind && follows[i].EntityID == follow.EntityID {
+			follows[i] = *follow
+			return nil
+		}
+	}
+	r.follows[telegramID] = append(follows, *follow)
+	return nil
+}
+
+// RemoveFollow removes a follow
+func (r *MemoryRepository) RemoveFollow(ctx context.Context, telegramID int64, kind, entityID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	follows := r.follows[telegramID][:0]
+	for _, f := range r.follows[telegramID] {
+		if f.Kind != kind || f.EntityID != entityID {
+			follows = append(follows, f)
+		}
+	}
+	if len(follows) == 0 {
+		delete(r.follows, telegramID)
+	} else {
+		r.follows[telegramID] = follows
+	}
+	return nil
+}
+
+// ListFollowers returns the users following an entity
+func (r *MemoryRepository) ListFollowers(ctx context.Context, kind, entityID string) ([]Follower, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var followers []Follower
+	for telegramID, follows := range r.follows {
+		for _, f := range follows {
+			if f.Kind == kind && f.EntityID == entityID {
+				followers = append(followers, Follower{TelegramID: telegramID, Follow: f})
+			}
+		}
+	}
+
+	sort.Slice(followers, func(i, j int) bool {
+		return followers[i].TelegramID < followers[j].TelegramID
+	})
+
+	return followers, nil
+}
+
+// DeleteFollows removes all of a user's follows
+func (r *MemoryRepository) DeleteFollows(ctx context.Context, telegramID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.follows, telegramID)
+	return nil
+}
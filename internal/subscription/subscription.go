@@ -0,0 +1,126 @@
+// Package subscription lets users follow authors and series and tells them
+// about new books on Flibusta.
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+var (
+	// ErrAlreadyFollowing is returned when the user already follows the entity
+	ErrAlreadyFollowing = errors.New("already following")
+	// ErrNotFollowing is returned when the user does not follow the entity
+	ErrNotFollowing = errors.New("not following")
+	// ErrTooManyFollows is returned when the user follows the maximum number of entities
+	ErrTooManyFollows = errors.New("too many follows")
+	// ErrInvalidLink is returned for links that are not Flibusta author or series pages
+	ErrInvalidLink = errors.New("not a Flibusta author or series link")
+)
+
+// entityLink matches Flibusta author (/a/<id>) and series (/s/<id>) pages
+var entityLink = regexp.MustCompile(`(?:^|/)(a|s)/(\d+)/?$`)
+
+// ParseLink returns the kind and ID of the entity a Flibusta author or
+// series link points to, such as https://flibusta.is/a/3156
+func ParseLink(link string) (kind, entityID string, err error) {
+	m := entityLink.FindStringSubmatch(link)
+	if m == nil {
+		return "", "", ErrInvalidLink
+	}
+	if m[1] == "a" {
+		return models.FollowAuthor, m[2], nil
+	}
+	return models.FollowSeries, m[2], nil
+}
+
+// Manager handles follow business logic
+type Manager struct {
+	repo       Repository
+	maxFollows int
+}
+
+// NewManager creates a follow manager allowing up to maxFollows per user
+func NewManager(repo Repository, maxFollows int) *Manager {
+	return &Manager{
+		repo:       repo,
+		maxFollows: maxFollows,
+	}
+}
+
+// Follow starts following an entity
+func (m *Manager) Follow(ctx context.Context, telegramID int64, follow *models.Follow) error {
+	follows, err := m.repo.ListFollows(ctx, telegramID)
+	if err != nil {
+		return fmt.Errorf("failed to get follows: %w", err)
+	}
+	for _, f := range follows {
+		if f.Kind == follow.Kind && f.EntityID == follow.EntityID {
+			return ErrAlreadyFollowing
+		}
+	}
+	if len(follows) >= m.maxFollows {
+		return ErrTooManyFollows
+	}
+
+	if follow.CreatedAt.IsZero() {
+		follow.CreatedAt = time.Now()
+	}
+	if err := m.repo.SaveFollow(ctx, telegramID, follow); err != nil {
+		return fmt.Errorf("failed to save follow: %w", err)
+	}
+	return nil
+}
+
+// Unfollow stops following an entity
+func (m *Manager) Unfollow(ctx context.Context, telegramID int64, kind, entityID string) error {
+	return m.repo.RemoveFollow(ctx, telegramID, kind, entityID)
+}
+
+// List returns a user's follows, oldest first
+func (m *Manager) List(ctx context.Context, telegramID int64) ([]models.Follow, error) {
+	return m.repo.ListFollows(ctx, telegramID)
+}
+
+// ToggleAutoDeliver switches automatic delivery of new books from an entity
+// and returns the new setting
+func (m *Manager) ToggleAutoDeliver(ctx context.Context, telegramID int64, kind, entityID string) (bool, error) {
+	follows, err := m.repo.ListFollows(ctx, telegramID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get follows: %w", err)
+	}
+	for i := range follows {
+		if follows[i].Kind == kind && follows[i].EntityID == entityID {
+			follows[i].AutoDeliver = !follows[i].AutoDeliver
+			if err := m.repo.SaveFollow(ctx, telegramID, &follows[i]); err != nil {
+				return false, fmt.Errorf("failed to save follow: %w", err)
+			}
+			return follows[i].AutoDeliver, nil
+		}
+	}
+	return false, ErrNotFollowing
+}
+
+// Name identifies follows in data exports
+func (m *Manager) Name() string {
+	return "follows"
+}
+
+// ExportUserData returns a user's follows, or nil if there are none
+func (m *Manager) ExportUserData(ctx context.Context, telegramID int64) (interface{}, error) {
+	follows, err := m.repo.ListFollows(ctx, telegramID)
+	if err != nil || len(follows) == 0 {
+		return nil, err
+	}
+	return follows, nil
+}
+
+// DeleteUserData removes a user's follows
+func (m *Manager) DeleteUserData(ctx context.Context, telegramID int64) error {
+	return m.repo.DeleteFollows(ctx, telegramID)
+}
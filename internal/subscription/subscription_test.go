@@ -0,0 +1,248 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/opds"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/user"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+var _ user.DataHolder = (*Manager)(nil)
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		link     string
+		wantKind string
+		wantID   string
+		wantErr  bool
+	}{
+		{link: "https://flibusta.is/a/3156", wantKind: models.FollowAuthor, wantID: "3156"},
+		{link: "http://flibusta.is/s/1234/", wantKind: models.FollowSeries, wantID: "1234"},
+		{link: "/a/1", wantKind: models.FollowAuthor, wantID: "1"},
+		{link: "https://flibusta.is/b/700", wantErr: true},
+		{link: "https://flibusta.is/a/abc", wantErr: true},
+		{link: "tolkien", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		kind, id, err := ParseLink(tt.link)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidLink) {
+				t.Errorf("ParseLink(%q) error = %v, want ErrInvalidLink", tt.link, err)
+			}
+			continue
+		}
+		if err != nil || kind != tt.wantKind || id != tt.wantID {
+			t.Errorf("ParseLink(%q) = %q, %q, %v, want %q, %q", tt.link, kind, id, err, tt.wantKind, tt.wantID)
+		}
+	}
+}
+
+func TestManager(t *testing.T) {
+	m := NewManager(NewMemoryRepository(), 2)
+	ctx := context.Background()
+
+	if err := m.Follow(ctx, 1, &models.Follow{Kind: models.FollowAuthor, EntityID: "3156"}); err != nil {
+		t.Fatalf("Follow() error = %v", err)
+	}
+	if err := m.Follow(ctx, 1, &models.Follow{Kind: models.FollowAuthor, EntityID: "3156"}); !errors.Is(err, ErrAlreadyFollowing) {
+		t.Errorf("Follow() again error = %v, want ErrAlreadyFollowing", err)
+	}
+	// The same ID as a series is a different entity
+	if err := m.Follow(ctx, 1, &models.Follow{Kind: models.FollowSeries, EntityID: "3156"}); err != nil {
+		t.Fatalf("Follow(series) error = %v", err)
+	}
+	if err := m.Follow(ctx, 1, &models.Follow{Kind: models.FollowSeries, EntityID: "1"}); !errors.Is(err, ErrTooManyFollows) {
+		t.Errorf("Follow() over the limit error = %v, want ErrTooManyFollows", err)
+	}
+
+	if on, err := m.ToggleAutoDeliver(ctx, 1, models.FollowSeries, "3156"); err != nil || !on {
+		t.Errorf("ToggleAutoDeliver() = %v, %v, want true", on, err)
+	}
+	if _, err := m.ToggleAutoDeliver(ctx, 1, models.FollowSeries, "1"); !errors.Is(err, ErrNotFollowing) {
+		t.Errorf("ToggleAutoDeliver() for a missing follow error = %v, want ErrNotFollowing", err)
+	}
+
+	if err := m.Unfollow(ctx, 1, models.FollowAuthor, "3156"); err != nil {
+		t.Fatalf("Unfollow() error = %v", err)
+	}
+	follows, _ := m.List(ctx, 1)
+	if len(follows) != 1 || follows[0].Kind != models.FollowSeries || !follows[0].AutoDeliver {
+		t.Errorf("List() = %+v, want the auto-delivered series", follows)
+	}
+
+	if err := m.DeleteUserData(ctx, 1); err != nil {
+		t.Fatalf("DeleteUserData() error = %v", err)
+	}
+	if data, _ := m.ExportUserData(ctx, 1); data != nil {
+		t.Errorf("ExportUserData() after delete = %v, want nil", data)
+	}
+}
+
+// fakeSource serves a settable feed, in pages of pageSize releases when set
+type fakeSource struct {
+	releases []opds.Release
+	pageSize int
+	err      error
+	pages    []int // Pages requested
+}
+
+func (s *fakeSource) NewArrivals(ctx context.Context, page int) ([]opds.Release, error) {
+	s.pages = append(s.pages, page)
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	size := s.pageSize
+	if size == 0 {
+		size = len(s.releases) + 1
+	}
+	start := min(page*size, len(s.releases))
+	return s.releases[start:min(start+size, len(s.releases))], nil
+}
+
+// notification is a call to fakeNotifier
+type notification struct {
+	telegramID int64
+	follow     models.Follow
+	bookID     string
+}
+
+// fakeNotifier records notifications, panicking for user panicFor
+type fakeNotifier struct {
+	sent     []notification
+	panicFor int64
+}
+
+func (n *fakeNotifier) NotifyRelease(ctx context.Context, telegramID int64, follow *models.Follow, release *opds.Release) error {
+	if telegramID == n.panicFor {
+		panic("notifier bug")
+	}
+	n.sent = append(n.sent, notification{telegramID, *follow, release.BookID})
+	return nil
+}
+
+func TestPoller_Poll(t *testing.T) {
+	follows := NewManager(NewMemoryRepository(), 10)
+	ctx := context.Background()
+	follows.Follow(ctx, 1, &models.Follow{Kind: models.FollowAuthor, EntityID: "10"})
+	follows.Follow(ctx, 1, &models.Follow{Kind: models.FollowSeries, EntityID: "20"})
+	follows.Follow(ctx, 2, &models.Follow{Kind: models.FollowSeries, EntityID: "20", Name: "Custom"})
+
+	old := opds.Release{BookID: "1", Authors: []opds.Entity{{ID: "10", Name: "Author"}}}
+	source := &fakeSource{releases: []opds.Release{old}}
+	notifier := &fakeNotifier{}
+	poller := NewPoller(follows, source, notifier)
+
+	// The first poll only records what is already there
+	if n, err := poller.Poll(ctx); err != nil || n != 0 {
+		t.Fatalf("first Poll() = %d, %v, want 0", n, err)
+	}
+
+	// User 1 follows both the author and the series, but hears about the book once
+	source.releases = []opds.Release{
+		{
+			BookID:  "2",
+			Authors: []opds.Entity{{ID: "10", Name: "Author"}},
+			Series:  []opds.Entity{{ID: "20", Name: "Series"}},
+		},
+		{BookID: "3", Authors: []opds.Entity{{ID: "99"}}},
+		old,
+	}
+	n, err := poller.Poll(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("Poll() = %d, %v, want 2", n, err)
+	}
+	if len(notifier.sent) != 2 {
+		t.Fatalf("sent %d notifications, want 2: %+v", len(notifier.sent), notifier.sent)
+	}
+	if got := notifier.sent[0]; got.telegramID != 1 || got.bookID != "2" || got.follow.Kind != models.FollowAuthor || got.follow.Name != "Author" {
+		t.Errorf("first notification = %+v, want book 2 to user 1 via the author", got)
+	}
+	if got := notifier.sent[1]; got.telegramID != 2 || got.follow.Name != "Custom" {
+		t.Errorf("second notification = %+v, want user 2 with the name kept", got)
+	}
+
+	// Names learnt from the feed are saved
+	list, _ := follows.List(ctx, 1)
+	if list[0].Name != "Author" {
+		t.Errorf("follow name = %q, want Author", list[0].Name)
+	}
+
+	// Nothing new, nothing sent
+	if n, _ := poller.Poll(ctx); n != 0 {
+		t.Errorf("repeated Poll() = %d, want 0", n)
+	}
+
+	source.err = errors.New("flibusta down")
+	if _, err := poller.Poll(ctx); err == nil {
+		t.Error("Poll() error = nil, want the feed error")
+	}
+}
+
+func TestPoller_Poll_Pages(t *testing.T) {
+	follows := NewManager(NewMemoryRepository(), 10)
+	ctx := context.Background()
+	follows.Follow(ctx, 1, &models.Follow{Kind: models.FollowAuthor, EntityID: "10"})
+
+	release := func(id string) opds.Release {
+		return opds.Release{BookID: id, Authors: []opds.Entity{{ID: "10"}}}
+	}
+	source := &fakeSource{releases: []opds.Release{release("1"), release("2")}, pageSize: 2}
+	notifier := &fakeNotifier{}
+	poller := NewPoller(follows, source, notifier)
+	if _, err := poller.Poll(ctx); err != nil {
+		t.Fatalf("first Poll() error = %v", err)
+	}
+
+	// Five books arrive, pushing the last poll's books to the third page
+	source.releases = []opds.Release{release("7"), release("6"), release("5"), release("4"), release("3"), release("1"), release("2")}
+	source.pages = nil
+	n, err := poller.Poll(ctx)
+	if err != nil || n != 5 {
+		t.Fatalf("Poll() = %d, %v, want 5", n, err)
+	}
+	if len(source.pages) != 3 {
+		t.Errorf("read pages %v, want 0 to 2", source.pages)
+	}
+
+	// A feed that never reaches the last poll stops at the page limit
+	source.releases = nil
+	for i := 0; i < 2*(maxArrivalPages+1); i++ {
+		source.releases = append(source.releases, release(fmt.Sprint(100+i)))
+	}
+	source.pages = nil
+	if n, err := poller.Poll(ctx); err != nil || n != 2*maxArrivalPages {
+		t.Errorf("Poll() = %d, %v, want %d", n, err, 2*maxArrivalPages)
+	}
+	if len(source.pages) != maxArrivalPages {
+		t.Errorf("read %d pages, want %d", len(source.pages), maxArrivalPages)
+	}
+}
+
+func TestPoller_Poll_NotifierPanics(t *testing.T) {
+	follows := NewManager(NewMemoryRepository(), 10)
+	ctx := context.Background()
+	follows.Follow(ctx, 1, &models.Follow{Kind: models.FollowAuthor, EntityID: "10"})
+	follows.Follow(ctx, 2, &models.Follow{Kind: models.FollowAuthor, EntityID: "10"})
+
+	source := &fakeSource{}
+	notifier := &fakeNotifier{panicFor: 1}
+	poller := NewPoller(follows, source, notifier)
+	if _, err := poller.Poll(ctx); err != nil {
+		t.Fatalf("first Poll() error = %v", err)
+	}
+
+	// A panic notifying one follower does not keep the others from hearing
+	source.releases = []opds.Release{{BookID: "2", Authors: []opds.Entity{{ID: "10"}}}}
+	if _, err := poller.Poll(ctx); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0].telegramID != 2 {
+		t.Errorf("sent = %+v, want a notification to user 2", notifier.sent)
+	}
+}
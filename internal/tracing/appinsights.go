@@ -0,0 +1,260 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultIngestionEndpoint is used when the connection string has no IngestionEndpoint
+const defaultIngestionEndpoint = "https://dc.services.visualstudio.com"
+
+// AppInsightsExporter exports spans to the Application Insights ingestion
+// (track) API. Server spans become requests, all other spans become dependencies.
+type AppInsightsExporter struct {
+	instrumentationKey string
+	endpoint           string
+	client             *http.Client
+
+	mu       sync.Mutex
+	shutdown bool
+}
+
+// NewAppInsightsExporter creates an exporter from an Application Insights
+// connection string or a bare instrumentation key. A nil client uses a
+// client with a 10 second timeout.
+func NewAppInsightsExporter(connectionString string, client *http.Client) (*AppInsightsExporter, error) {
+	ikey, endpoint, err := parseConnectionString(connectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &AppInsightsExporter{
+		instrumentationKey: ikey,
+		endpoint:           strings.TrimSuffix(endpoint, "/") + "/v2.1/track",
+		client:             client,
+	}, nil
+}
+
+// parseConnectionString extracts the instrumentation key and ingestion endpoint
+func parseConnectionString(connectionString string) (ikey, endpoint string, err error) {
+	endpoint = defaultIngestionEndpoint
+
+	if !strings.Contains(connectionString, "=") {
+		// Bare instrumentation key
+		ikey = strings.TrimSpace(connectionString)
+	} else {
+		for _, part := range strings.Split(connectionString, ";") {
+			key, value, ok := strings.Cut(part, "=")
+			if !ok {
+				continue
+			}
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "instrumentationkey":
+				ikey = strings.TrimSpace(value)
+			case "ingestionendpoint":
+				endpoint = strings.TrimSpace(value)
+			}
+		}
+	}
+
+	if ikey == "" {
+		return "", "", fmt.Errorf("application insights instrumentation key is required")
+	}
+
+	return ikey, endpoint, nil
+}
+
+// envelope is an Application Insights telemetry item
+type envelope struct {
+	Name string            `json:"name"`
+	Time string            `json:"time"`
+	IKey string            `json:"iKey"`
+	Tags map[string]string `json:"tags"`
+	Data envelopeData      `json:"data"`
+}
+
+type envelopeData struct {
+	BaseType string      `json:"baseType"`
+	BaseData interface{} `json:"baseData"`
+}
+
+type requestData struct {
+	Ver          int               `json:"ver"`
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Duration     string            `json:"duration"`
+	ResponseCode string            `json:"responseCode"`
+	Success      bool              `json:"success"`
+	Properties   map[string]string `json:"properties,omitempty"`
+}
+
+type dependencyData struct {
+	Ver        int               `json:"ver"`
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Duration   string            `json:"duration"`
+	ResultCode string            `json:"resultCode"`
+	Success    bool              `json:"success"`
+	Type       string            `json:"type"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// ExportSpans sends spans to Application Insights
+func (e *AppInsightsExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	shutdown := e.shutdown
+	e.mu.Unlock()
+	if shutdown || len(spans) == 0 {
+		return nil
+	}
+
+	envelopes := make([]envelope, 0, len(spans))
+	for _, span := range spans {
+		envelopes = append(envelopes, e.toEnvelope(span))
+	}
+
+	body, err := json.Marshal(envelopes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telemetry: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send telemetry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Partial success (206) means some items were rejected; nothing to retry here
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("application insights returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// Shutdown stops the exporter; later exports are dropped
+func (e *AppInsightsExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shutdown = true
+	return nil
+}
+
+// toEnvelope converts a span to a request or dependency telemetry item
+func (e *AppInsightsExporter) toEnvelope(span sdktrace.ReadOnlySpan) envelope {
+	sc := span.SpanContext()
+	success := span.Status().Code != codes.Error
+	duration := formatDuration(span.EndTime().Sub(span.StartTime()))
+
+	properties := make(map[string]string, len(span.Attributes()))
+	for _, attr := range span.Attributes() {
+		properties[string(attr.Key)] = attr.Value.Emit()
+	}
+	if desc := span.Status().Description; desc != "" {
+		properties["error"] = desc
+	}
+
+	tags := map[string]string{
+		"ai.operation.id":   sc.TraceID().String(),
+		"ai.operation.name": span.Name(),
+	}
+	if parent := span.Parent(); parent.IsValid() {
+		tags["ai.operation.parentId"] = parent.SpanID().String()
+	}
+	for _, attr := range span.Resource().Attributes() {
+		if attr.Key == semconv.ServiceNameKey {
+			tags["ai.cloud.role"] = attr.Value.AsString()
+		}
+	}
+
+	resultCode := "0"
+	if !success {
+		resultCode = "1"
+	}
+
+	env := envelope{
+		Time: span.StartTime().UTC().Format(time.RFC3339Nano),
+		IKey: e.instrumentationKey,
+		Tags: tags,
+	}
+
+	ikey := strings.ReplaceAll(e.instrumentationKey, "-", "")
+	if span.SpanKind() == trace.SpanKindServer {
+		env.Name = "Microsoft.ApplicationInsights." + ikey + ".Request"
+		env.Data = envelopeData{
+			BaseType: "RequestData",
+			BaseData: requestData{
+				Ver:          2,
+				ID:           sc.SpanID().String(),
+				Name:         span.Name(),
+				Duration:     duration,
+				ResponseCode: resultCode,
+				Success:      success,
+				Properties:   properties,
+			},
+		}
+		return env
+	}
+
+	dependencyType := "InProc"
+	if span.SpanKind() == trace.SpanKindClient {
+		dependencyType = "HTTP"
+	}
+
+	env.Name = "Microsoft.ApplicationInsights." + ikey + ".RemoteDependency"
+	env.Data = envelopeData{
+		BaseType: "RemoteDependencyData",
+		BaseData: dependencyData{
+			Ver:        2,
+			ID:         sc.SpanID().String(),
+			Name:       span.Name(),
+			Duration:   duration,
+			ResultCode: resultCode,
+			Success:    success,
+			Type:       dependencyType,
+			Properties: properties,
+		},
+	}
+	return env
+}
+
+// formatDuration formats d as the "d.hh:mm:ss.ffffff" timespan used by Application Insights
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+	d -= minutes * time.Minute
+	seconds := d / time.Second
+	d -= seconds * time.Second
+	micros := d / time.Microsecond
+
+	return fmt.Sprintf("%d.%02d:%02d:%02d.%06d", days, hours, minutes, seconds, micros)
+}
@@ -0,0 +1,202 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestParseConnectionString(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		wantKey      string
+		wantEndpoint string
+		wantErr      bool
+	}{
+		{
+			name:         "bare instrumentation key",
+			input:        "00000000-0000-0000-0000-000000000001",
+			wantKey:      "00000000-0000-0000-0000-000000000001",
+			wantEndpoint: defaultIngestionEndpoint,
+		},
+		{
+			name:         "connection string",
+			input:        "InstrumentationKey=abc;IngestionEndpoint=https://westeurope-5.in.applicationinsights.azure.com/;LiveEndpoint=https://westeurope.livediagnostics.monitor.azure.com/",
+			wantKey:      "abc",
+			wantEndpoint: "https://westeurope-5.in.applicationinsights.azure.com/",
+		},
+		{
+			name:         "connection string without endpoint",
+			input:        "InstrumentationKey=abc",
+			wantKey:      "abc",
+			wantEndpoint: defaultIngestionEndpoint,
+		},
+		{
+			name:    "empty",
+			input:   "",
+			wantErr: true,
+		},
+		{
+			name:    "connection string without key",
+			input:   "IngestionEndpoint=https://example.com",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, endpoint, err := parseConnectionString(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseConnectionString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if key != tt.wantKey {
+				t.Errorf("key = %v, want %v", key, tt.wantKey)
+			}
+			if endpoint != tt.wantEndpoint {
+				t.Errorf("endpoint = %v, want %v", endpoint, tt.wantEndpoint)
+			}
+		})
+	}
+}
+
+func TestAppInsightsExporter_ExportSpans(t *testing.T) {
+	var received []map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2.1/track" {
+			t.Errorf("Request path = %v, want /v2.1/track", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode telemetry: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	exporter, err := NewAppInsightsExporter("InstrumentationKey=test-key;IngestionEndpoint="+server.URL, server.Client())
+	if err != nil {
+		t.Fatalf("NewAppInsightsExporter() error = %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracer := provider.Tracer("test")
+
+	ctx, parent := tracer.Start(context.Background(), "telegram.update", trace.WithSpanKind(trace.SpanKindServer))
+	_, child := tracer.Start(ctx, "kindle.send")
+	End(child, errors.New("smtp timeout"))
+	End(parent, nil)
+
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	// The syncer exports each span as it ends: child first, then parent
+	if len(received) != 1 {
+		t.Fatalf("Last batch contains %d items, want 1", len(received))
+	}
+
+	request := received[0]
+	if request["name"] != "Microsoft.ApplicationInsights.testkey.Request" {
+		t.Errorf("name = %v", request["name"])
+	}
+	if request["iKey"] != "test-key" {
+		t.Errorf("iKey = %v, want test-key", request["iKey"])
+	}
+	data := request["data"].(map[string]interface{})
+	if data["baseType"] != "RequestData" {
+		t.Errorf("baseType = %v, want RequestData", data["baseType"])
+	}
+	baseData := data["baseData"].(map[string]interface{})
+	if baseData["success"] != true {
+		t.Errorf("success = %v, want true", baseData["success"])
+	}
+}
+
+func TestAppInsightsExporter_Dependency(t *testing.T) {
+	exporter, err := NewAppInsightsExporter("key", nil)
+	if err != nil {
+		t.Fatalf("NewAppInsightsExporter() error = %v", err)
+	}
+
+	var spans []sdktrace.ReadOnlySpan
+	recorder := spanRecorder(func(s sdktrace.ReadOnlySpan) { spans = append(spans, s) })
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	_, child := provider.Tracer("test").Start(ctx, "download")
+	End(child, errors.New("not found"))
+	End(parent, nil)
+
+	env := exporter.toEnvelope(spans[0])
+	if env.Data.BaseType != "RemoteDependencyData" {
+		t.Fatalf("baseType = %v, want RemoteDependencyData", env.Data.BaseType)
+	}
+	dep := env.Data.BaseData.(dependencyData)
+	if dep.Success {
+		t.Error("Failed span should not be successful")
+	}
+	if dep.Properties["error"] != "not found" {
+		t.Errorf("error property = %v, want %v", dep.Properties["error"], "not found")
+	}
+	if env.Tags["ai.operation.parentId"] != parent.SpanContext().SpanID().String() {
+		t.Errorf("parentId = %v, want %v", env.Tags["ai.operation.parentId"], parent.SpanContext().SpanID())
+	}
+}
+
+func TestAppInsightsExporter_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	exporter, err := NewAppInsightsExporter("InstrumentationKey=k;IngestionEndpoint="+server.URL, server.Client())
+	if err != nil {
+		t.Fatalf("NewAppInsightsExporter() error = %v", err)
+	}
+
+	var spans []sdktrace.ReadOnlySpan
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder(func(s sdktrace.ReadOnlySpan) { spans = append(spans, s) })))
+	_, span := provider.Tracer("test").Start(context.Background(), "op")
+	span.End()
+
+	if err := exporter.ExportSpans(context.Background(), spans); err == nil {
+		t.Error("Expected error for 400 response, got nil")
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0.00:00:00.000000"},
+		{1500 * time.Millisecond, "0.00:00:01.500000"},
+		{26*time.Hour + 3*time.Minute + 4*time.Second + 5*time.Microsecond, "1.02:03:04.000005"},
+		{-time.Second, "0.00:00:00.000000"},
+	}
+
+	for _, tt := range tests {
+		if got := formatDuration(tt.d); got != tt.want {
+			t.Errorf("formatDuration(%v) = %v, want %v", tt.d, got, tt.want)
+		}
+	}
+}
+
+// spanRecorder is a span processor calling fn for every ended span
+type spanRecorder func(sdktrace.ReadOnlySpan)
+
+func (r spanRecorder) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+func (r spanRecorder) OnEnd(s sdktrace.ReadOnlySpan)                   { r(s) }
+func (r spanRecorder) Shutdown(context.Context) error                  { return nil }
+func (r spanRecorder) ForceFlush(context.Context) error                { return nil }
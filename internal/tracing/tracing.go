@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies spans created by this application
+const instrumentationName = "github.com/stpatrick2016/flibusta_kindle_bot"
+
+// Exporter names accepted in Options.Exporter
+const (
+	ExporterNone        = "none"
+	ExporterOTLP        = "otlp"
+	ExporterAppInsights = "appinsights"
+)
+
+// Options configures tracing
+type Options struct {
+	ServiceName    string
+	ServiceVersion string
+	Exporter       string  // "none", "otlp" or "appinsights"
+	SampleRatio    float64 // Fraction of traces to sample, 0..1
+
+	// OTLPEndpoint is the OTLP/HTTP base URL (e.g. http://localhost:4318).
+	// When empty the standard OTEL_EXPORTER_OTLP_* variables are used.
+	OTLPEndpoint string
+
+	// AppInsightsConnectionString is an Application Insights connection string
+	// ("InstrumentationKey=...;IngestionEndpoint=...") or a bare instrumentation key
+	AppInsightsConnectionString string
+}
+
+// Shutdown flushes pending spans and stops the exporter
+type Shutdown func(ctx context.Context) error
+
+// Setup installs the global tracer provider and returns its shutdown function.
+// With the "none" exporter tracing is a no-op.
+func Setup(ctx context.Context, opts Options) (Shutdown, error) {
+	var exporter sdktrace.SpanExporter
+	switch opts.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var clientOpts []otlptracehttp.Option
+		if opts.OTLPEndpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpointURL(opts.OTLPEndpoint))
+		}
+		exp, err := otlptracehttp.New(ctx, clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		exporter = exp
+	case ExporterAppInsights:
+		exp, err := NewAppInsightsExporter(opts.AppInsightsConnectionString, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Application Insights exporter: %w", err)
+		}
+		exporter = exp
+	default:
+		return nil, fmt.Errorf("invalid tracing exporter: %s (must be 'none', 'otlp', or 'appinsights')", opts.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+		semconv.ServiceVersion(opts.ServiceVersion),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span using the global tracer provider
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End records err on span (if any) and ends it. Use with a named error return:
+//
+//	ctx, span := tracing.Start(ctx, "user.SetKindleEmail")
+//	defer func() { tracing.End(span, err) }()
+func End(span trace.Span, err error) {
+	if err != nil {
+		RecordError(span, err)
+	}
+	span.End()
+}
+
+// RecordError marks span as failed with err. End does this for returned
+// errors; use it for errors that are handled instead.
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
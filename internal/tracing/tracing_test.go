@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector is a minimal OTLP/HTTP trace receiver
+type collector struct {
+	mu    sync.Mutex
+	spans map[string]int // span name -> status code
+}
+
+func newCollector(t *testing.T) (*collector, *httptest.Server) {
+	c := &collector{spans: make(map[string]int)}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var req coltracepb.ExportTraceServiceRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			t.Errorf("Failed to decode OTLP request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		c.mu.Lock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, span := range ss.Spans {
+					c.spans[span.Name] = int(span.GetStatus().GetCode())
+				}
+			}
+		}
+		c.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	return c, server
+}
+
+func resetGlobalProvider(t *testing.T) {
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+}
+
+func TestSetup_OTLP(t *testing.T) {
+	resetGlobalProvider(t)
+	c, server := newCollector(t)
+	ctx := context.Background()
+
+	shutdown, err := Setup(ctx, Options{
+		ServiceName:  "test-bot",
+		Exporter:     ExporterOTLP,
+		SampleRatio:  1,
+		OTLPEndpoint: server.URL,
+	})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	// Create a parent span with a failing child
+	parentCtx, parent := Start(ctx, "telegram.update")
+	_, child := Start(parentCtx, "user.GetOrCreateUser")
+	End(child, errors.New("repository unavailable"))
+	End(parent, nil)
+
+	if err := shutdown(ctx); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.spans["telegram.update"]; !ok {
+		t.Error("Collector did not receive telegram.update span")
+	}
+	status, ok := c.spans["user.GetOrCreateUser"]
+	if !ok {
+		t.Fatal("Collector did not receive user.GetOrCreateUser span")
+	}
+	// OTLP status code 2 is STATUS_CODE_ERROR
+	if status != 2 {
+		t.Errorf("Child span status = %v, want error", status)
+	}
+}
+
+func TestSetup_None(t *testing.T) {
+	resetGlobalProvider(t)
+
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterNone})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown() error = %v", err)
+	}
+}
+
+func TestSetup_InvalidExporter(t *testing.T) {
+	resetGlobalProvider(t)
+
+	if _, err := Setup(context.Background(), Options{Exporter: "zipkin"}); err == nil {
+		t.Error("Expected error for invalid exporter, got nil")
+	}
+}
+
+func TestSetup_AppInsightsRequiresKey(t *testing.T) {
+	resetGlobalProvider(t)
+
+	if _, err := Setup(context.Background(), Options{Exporter: ExporterAppInsights}); err == nil {
+		t.Error("Expected error for missing instrumentation key, got nil")
+	}
+}
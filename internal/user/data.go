@@ -0,0 +1,132 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/tracing"
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+// DataHolder is implemented by stores that keep per-user data outside the
+// user repository (caches, histories, ...). Registered holders are included
+// in data exports and erased when a user deletes their data.
+type DataHolder interface {
+	// Name identifies the holder's section in a data export
+	Name() string
+
+	// ExportUserData returns the data held about a user, or nil if there is none
+	ExportUserData(ctx context.Context, telegramID int64) (interface{}, error)
+
+	// DeleteUserData erases the data held about a user
+	DeleteUserData(ctx context.Context, telegramID int64) error
+}
+
+// UserData is everything stored about a user
+type UserData struct {
+	ExportedAt  time.Time              `json:"exported_at"`
+	Profile     Profile                `json:"profile"`
+	Preferences models.Preferences     `json:"preferences"`
+	Activity    Activity               `json:"activity"`
+	Sections    map[string]interface{} `json:"sections,omitempty"` // Keyed by DataHolder name
+}
+
+// Profile is the Telegram profile stored for a user
+type Profile struct {
+	TelegramID int64  `json:"telegram_id"`
+	Username   string `json:"username"`
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+}
+
+// Activity is the usage history stored for a user
+type Activity struct {
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	LastActive time.Time `json:"last_active"`
+	BooksSent  int       `json:"books_sent"`
+	IsActive   bool      `json:"is_active"`
+
+	KindleVerified   bool      `json:"kindle_verified"`
+	KindleVerifiedAt time.Time `json:"kindle_verified_at"`
+	IsBanned         bool      `json:"is_banned"`
+}
+
+// RegisterDataHolder adds a store to data exports and deletions
+func (m *Manager) RegisterDataHolder(h DataHolder) {
+	m.holders = append(m.holders, h)
+}
+
+// ExportUserData collects everything stored about a user
+func (m *Manager) ExportUserData(ctx context.Context, telegramID int64) (data *UserData, err error) {
+	ctx, span := startSpan(ctx, "user.ExportUserData", telegramID)
+	defer func() { tracing.End(span, err) }()
+
+	u, err := m.repo.GetUser(ctx, telegramID)
+	if err != nil {
+		return nil, err
+	}
+
+	data = &UserData{
+		ExportedAt: time.Now().UTC(),
+		Profile: Profile{
+			TelegramID: u.TelegramID,
+			Username:   u.Username,
+			FirstName:  u.FirstName,
+			LastName:   u.LastName,
+		},
+		Preferences: models.Preferences{
+			KindleEmail: u.KindleEmail,
+			Language:    u.Language,
+		},
+		Activity: Activity{
+			CreatedAt:  u.CreatedAt,
+			UpdatedAt:  u.UpdatedAt,
+			LastActive: u.LastActive,
+			BooksSent:  u.BooksSent,
+			IsActive:   u.IsActive,
+			IsBanned:   u.IsBanned,
+
+			KindleVerified:   u.KindleVerified,
+			KindleVerifiedAt: u.KindleVerifiedAt,
+		},
+	}
+
+	for _, h := range m.holders {
+		section, err := h.ExportUserData(ctx, telegramID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s data: %w", h.Name(), err)
+		}
+		if section == nil {
+			continue
+		}
+		if data.Sections == nil {
+			data.Sections = make(map[string]interface{})
+		}
+		data.Sections[h.Name()] = section
+	}
+
+	return data, nil
+}
+
+// DeleteUserData erases a user from the repository and every registered
+// data holder. All stores are attempted even if one fails.
+func (m *Manager) DeleteUserData(ctx context.Context, telegramID int64) (err error) {
+	ctx, span := startSpan(ctx, "user.DeleteUserData", telegramID)
+	defer func() { tracing.End(span, err) }()
+
+	var errs []error
+	for _, h := range m.holders {
+		if err := h.DeleteUserData(ctx, telegramID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete %s data: %w", h.Name(), err))
+		}
+	}
+
+	if err := m.repo.DeleteUser(ctx, telegramID); err != nil {
+		errs = append(errs, fmt.Errorf("failed to delete user: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,116 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// fakeHolder is a DataHolder keeping one value per user
+type fakeHolder struct {
+	data      map[int64]string
+	deleteErr error
+}
+
+func (h *fakeHolder) Name() string { return "history" }
+
+func (h *fakeHolder) ExportUserData(ctx context.Context, telegramID int64) (interface{}, error) {
+	if v, ok := h.data[telegramID]; ok {
+		return v, nil
+	}
+	return nil, nil
+}
+
+func (h *fakeHolder) DeleteUserData(ctx context.Context, telegramID int64) error {
+	if h.deleteErr != nil {
+		return h.deleteErr
+	}
+	delete(h.data, telegramID)
+	return nil
+}
+
+func TestManager_ExportUserData(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager(NewMemoryRepository())
+	holder := &fakeHolder{data: map[int64]string{1: "searched: tolstoy"}}
+	manager.RegisterDataHolder(holder)
+
+	for _, id := range []int64{1, 2} {
+		if _, err := manager.GetOrCreateUser(ctx, id, "user", "First", "Last", "ru"); err != nil {
+			t.Fatalf("GetOrCreateUser() error = %v", err)
+		}
+	}
+	if err := manager.SetKindleEmail(ctx, 1, "reader@kindle.com"); err != nil {
+		t.Fatalf("SetKindleEmail() error = %v", err)
+	}
+
+	data, err := manager.ExportUserData(ctx, 1)
+	if err != nil {
+		t.Fatalf("ExportUserData() error = %v", err)
+	}
+	if data.Profile.TelegramID != 1 || data.Profile.Username != "user" {
+		t.Errorf("Profile = %+v", data.Profile)
+	}
+	if data.Preferences.KindleEmail != "reader@kindle.com" || data.Preferences.Language != "ru" {
+		t.Errorf("Preferences = %+v", data.Preferences)
+	}
+	if data.Sections["history"] != "searched: tolstoy" {
+		t.Errorf("Sections = %v, want history section", data.Sections)
+	}
+
+	// Holders without data for the user are left out
+	data, err = manager.ExportUserData(ctx, 2)
+	if err != nil {
+		t.Fatalf("ExportUserData() error = %v", err)
+	}
+	if data.Sections != nil {
+		t.Errorf("Sections = %v, want none", data.Sections)
+	}
+
+	if _, err := manager.ExportUserData(ctx, 3); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("ExportUserData() of missing user error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestManager_DeleteUserData(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		deleteErr error
+		wantErr   bool
+	}{
+		{name: "deletes everywhere"},
+		{name: "holder fails", deleteErr: errors.New("storage down"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(NewMemoryRepository())
+			holder := &fakeHolder{data: map[int64]string{1: "history"}, deleteErr: tt.deleteErr}
+			manager.RegisterDataHolder(holder)
+
+			if _, err := manager.GetOrCreateUser(ctx, 1, "user", "First", "Last", "en"); err != nil {
+				t.Fatalf("GetOrCreateUser() error = %v", err)
+			}
+
+			err := manager.DeleteUserData(ctx, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeleteUserData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// The user is removed from the repository even if a holder fails
+			if _, err := manager.GetUser(ctx, 1); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("GetUser() error = %v, want ErrUserNotFound", err)
+			}
+			if _, ok := holder.data[1]; ok == (tt.deleteErr == nil) {
+				t.Errorf("holder data present = %v", ok)
+			}
+		})
+	}
+
+	// Deleting an unknown user is not an error
+	if err := NewManager(NewMemoryRepository()).DeleteUserData(ctx, 42); err != nil {
+		t.Errorf("DeleteUserData() of missing user error = %v", err)
+	}
+}
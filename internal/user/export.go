@@ -0,0 +1,316 @@
+package user
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+const (
+	// ExportFormat identifies user export files
+	ExportFormat = "flibusta-kindle-bot/users"
+
+	// ExportVersion is the schema version written by Export
+	ExportVersion = 1
+
+	// maxRecordSize bounds a single JSONL line
+	maxRecordSize = 1024 * 1024
+)
+
+// ConflictPolicy decides what Import does with users that already exist
+// in the target repository with different data
+type ConflictPolicy string
+
+const (
+	// ConflictSkip keeps the existing user
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite replaces the existing user with the imported one
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictNewer keeps whichever version was updated last
+	ConflictNewer ConflictPolicy = "newer"
+)
+
+// ParseConflictPolicy validates a conflict policy name
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(s); p {
+	case ConflictSkip, ConflictOverwrite, ConflictNewer:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid conflict policy: %s (must be 'skip', 'overwrite', or 'newer')", s)
+	}
+}
+
+// exportHeader is the first line of an export file
+type exportHeader struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// userRecordV1 is one user in a version 1 export. It is kept separate from
+// models.User so that model changes do not silently change the file format.
+type userRecordV1 struct {
+	TelegramID  int64     `json:"telegram_id"`
+	Username    string    `json:"username,omitempty"`
+	FirstName   string    `json:"first_name,omitempty"`
+	LastName    string    `json:"last_name,omitempty"`
+	KindleEmail string    `json:"kindle_email,omitempty"`
+	Language    string    `json:"language,omitempty"`
+	BooksSent   int       `json:"books_sent"`
+	IsActive    bool      `json:"is_active"`
+	IsBanned    bool      `json:"is_banned"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	LastActive  time.Time `json:"last_active"`
+
+	// Optional fields, absent in files written by older releases
+	KindleVerifiedAt *time.Time `json:"kindle_verified_at,omitempty"`
+}
+
+func newUserRecordV1(u *models.User) userRecordV1 {
+	var verifiedAt *time.Time
+	if u.KindleVerified {
+		t := u.KindleVerifiedAt
+		verifiedAt = &t
+	}
+
+	return userRecordV1{
+		TelegramID:  u.TelegramID,
+		Username:    u.Username,
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		KindleEmail: u.KindleEmail,
+		Language:    u.Language,
+		BooksSent:   u.BooksSent,
+		IsActive:    u.IsActive,
+		IsBanned:    u.IsBanned,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		LastActive:  u.LastActive,
+
+		KindleVerifiedAt: verifiedAt,
+	}
+}
+
+func (r userRecordV1) user() *models.User {
+	u := &models.User{
+		ID:          r.TelegramID,
+		TelegramID:  r.TelegramID,
+		Username:    r.Username,
+		FirstName:   r.FirstName,
+		LastName:    r.LastName,
+		KindleEmail: r.KindleEmail,
+		Language:    r.Language,
+		BooksSent:   r.BooksSent,
+		IsActive:    r.IsActive,
+		IsBanned:    r.IsBanned,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+		LastActive:  r.LastActive,
+	}
+	if r.KindleVerifiedAt != nil {
+		u.KindleVerified = true
+		u.KindleVerifiedAt = *r.KindleVerifiedAt
+	}
+	return u
+}
+
+// Export writes every user in repo to w as JSONL: a header line followed by
+// one user per line. It returns the number of users written.
+func Export(ctx context.Context, repo Repository, w io.Writer) (int, error) {
+	users, err := repo.ListUsers(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(exportHeader{Format: ExportFormat, Version: ExportVersion, ExportedAt: time.Now().UTC()}); err != nil {
+		return 0, fmt.Errorf("failed to write export header: %w", err)
+	}
+
+	for i, u := range users {
+		if err := enc.Encode(newUserRecordV1(u)); err != nil {
+			return i, fmt.Errorf("failed to write user %d: %w", u.TelegramID, err)
+		}
+	}
+
+	return len(users), nil
+}
+
+// ImportOptions controls Import
+type ImportOptions struct {
+	// DryRun reports what would change without writing to the repository
+	DryRun bool
+
+	// OnConflict decides how existing users with different data are handled.
+	// Defaults to ConflictSkip.
+	OnConflict ConflictPolicy
+}
+
+// Conflict describes an imported user that differs from the existing one
+type Conflict struct {
+	TelegramID int64    `json:"telegram_id"`
+	Fields     []string `json:"fields"`     // Fields whose values differ
+	Resolution string   `json:"resolution"` // "kept" or "overwritten"
+}
+
+// RecordError describes a line that could not be imported
+type RecordError struct {
+	Line int    `json:"line"`
+	Err  string `json:"error"`
+}
+
+// ImportReport summarizes an import
+type ImportReport struct {
+	DryRun    bool          `json:"dry_run"`
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Unchanged int           `json:"unchanged"`
+	Skipped   int           `json:"skipped"`
+	Conflicts []Conflict    `json:"conflicts,omitempty"`
+	Errors    []RecordError `json:"errors,omitempty"`
+}
+
+// Import reads an export written by Export into repo. Invalid records are
+// reported and skipped; the returned error is for unreadable input and
+// repository failures, in which case the report covers the records handled so far.
+func Import(ctx context.Context, repo Repository, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	if opts.OnConflict == "" {
+		opts.OnConflict = ConflictSkip
+	}
+	report := &ImportReport{DryRun: opts.DryRun}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return report, fmt.Errorf("failed to read export: %w", err)
+		}
+		return report, errors.New("export is empty")
+	}
+
+	var header exportHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Format != ExportFormat {
+		return report, fmt.Errorf("not a user export: first line must be a %s header", ExportFormat)
+	}
+	if header.Version != ExportVersion {
+		return report, fmt.Errorf("unsupported export version %d (this build reads version %d)", header.Version, ExportVersion)
+	}
+
+	seen := make(map[int64]int) // Telegram ID -> line
+	for line := 2; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record userRecordV1
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			report.Errors = append(report.Errors, RecordError{Line: line, Err: fmt.Sprintf("invalid record: %v", err)})
+			continue
+		}
+		if record.TelegramID == 0 {
+			report.Errors = append(report.Errors, RecordError{Line: line, Err: "telegram_id is required"})
+			continue
+		}
+		if first, ok := seen[record.TelegramID]; ok {
+			report.Errors = append(report.Errors, RecordError{Line: line, Err: fmt.Sprintf("duplicate telegram_id %d (first on line %d)", record.TelegramID, first)})
+			continue
+		}
+		seen[record.TelegramID] = line
+
+		if err := importRecord(ctx, repo, record, opts, report); err != nil {
+			return report, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return report, fmt.Errorf("failed to read export: %w", err)
+	}
+
+	return report, nil
+}
+
+// importRecord saves one record according to the conflict policy
+func importRecord(ctx context.Context, repo Repository, record userRecordV1, opts ImportOptions, report *ImportReport) error {
+	existing, err := repo.GetUser(ctx, record.TelegramID)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		report.Created++
+		return saveRecord(ctx, repo, record, opts)
+	case err != nil:
+		return fmt.Errorf("failed to get user %d: %w", record.TelegramID, err)
+	}
+
+	fields := diffRecords(newUserRecordV1(existing), record)
+	if len(fields) == 0 {
+		report.Unchanged++
+		return nil
+	}
+
+	overwrite := opts.OnConflict == ConflictOverwrite ||
+		(opts.OnConflict == ConflictNewer && record.UpdatedAt.After(existing.UpdatedAt))
+
+	conflict := Conflict{TelegramID: record.TelegramID, Fields: fields, Resolution: "kept"}
+	if overwrite {
+		conflict.Resolution = "overwritten"
+		report.Updated++
+	} else {
+		report.Skipped++
+	}
+	report.Conflicts = append(report.Conflicts, conflict)
+
+	if !overwrite {
+		return nil
+	}
+	return saveRecord(ctx, repo, record, opts)
+}
+
+func saveRecord(ctx context.Context, repo Repository, record userRecordV1, opts ImportOptions) error {
+	if opts.DryRun {
+		return nil
+	}
+	// Keep updated_at, which the newer conflict policy compares later
+	if err := repo.ImportUser(ctx, record.user()); err != nil {
+		return fmt.Errorf("failed to save user %d: %w", record.TelegramID, err)
+	}
+	return nil
+}
+
+// diffRecords lists the fields that differ between two records, ignoring
+// updated_at, which changes on every save
+func diffRecords(a, b userRecordV1) []string {
+	var fields []string
+	add := func(name string, differs bool) {
+		if differs {
+			fields = append(fields, name)
+		}
+	}
+
+	add("username", a.Username != b.Username)
+	add("first_name", a.FirstName != b.FirstName)
+	add("last_name", a.LastName != b.LastName)
+	add("kindle_email", a.KindleEmail != b.KindleEmail)
+	add("language", a.Language != b.Language)
+	add("books_sent", a.BooksSent != b.BooksSent)
+	add("kindle_verified_at", !equalTimes(a.KindleVerifiedAt, b.KindleVerifiedAt))
+	add("is_active", a.IsActive != b.IsActive)
+	add("is_banned", a.IsBanned != b.IsBanned)
+	add("created_at", !a.CreatedAt.Equal(b.CreatedAt))
+	add("last_active", !a.LastActive.Equal(b.LastActive))
+
+	return fields
+}
+
+// equalTimes reports whether two optional times are equal
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,279 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
+)
+
+func seedRepository(t *testing.T, users ...*models.User) *MemoryRepository {
+	t.Helper()
+
+	repo := NewMemoryRepository()
+	for _, u := range users {
+		if err := repo.SaveUser(context.Background(), u); err != nil {
+			t.Fatalf("SaveUser() error = %v", err)
+		}
+	}
+	return repo
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	source := seedRepository(t,
+		&models.User{ID: 2, TelegramID: 2, FirstName: "Bob", KindleEmail: "bob@kindle.com", Language: "en", BooksSent: 3, CreatedAt: created, KindleVerified: true, KindleVerifiedAt: created},
+		&models.User{ID: 1, TelegramID: 1, FirstName: "Alice", Language: "ru", IsActive: true, CreatedAt: created},
+	)
+
+	var buf bytes.Buffer
+	n, err := Export(ctx, source, &buf)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Export() = %d, want 2", n)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Export wrote %d lines, want header and 2 users:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], `"version":1`) || !strings.Contains(lines[0], ExportFormat) {
+		t.Errorf("Header = %s, want format and version", lines[0])
+	}
+	// Users are exported in Telegram ID order
+	if !strings.Contains(lines[1], "Alice") {
+		t.Errorf("First user = %s, want Alice", lines[1])
+	}
+
+	target := NewMemoryRepository()
+	report, err := Import(ctx, target, &buf, ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if report.Created != 2 || len(report.Errors) != 0 {
+		t.Errorf("Report = %+v, want 2 created and no errors", report)
+	}
+
+	bob, err := target.GetUser(ctx, 2)
+	if err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+	if bob.KindleEmail != "bob@kindle.com" || bob.BooksSent != 3 || !bob.CreatedAt.Equal(created) || bob.ID != 2 {
+		t.Errorf("Imported user = %+v, want fields preserved", bob)
+	}
+	if !bob.KindleVerified || !bob.KindleVerifiedAt.Equal(created) {
+		t.Errorf("Imported verification = %v at %v, want verified at %v", bob.KindleVerified, bob.KindleVerifiedAt, created)
+	}
+
+	alice, _ := target.GetUser(ctx, 1)
+	if alice.KindleVerified {
+		t.Error("Imported unverified user as verified")
+	}
+}
+
+func TestImport_Conflicts(t *testing.T) {
+	ctx := context.Background()
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Now().Add(time.Hour)
+
+	export := strings.Join([]string{
+		`{"format":"flibusta-kindle-bot/users","version":1}`,
+		`{"telegram_id":1,"first_name":"Alice","kindle_email":"alice@kindle.com"}`,
+		`{"telegram_id":2,"first_name":"Bob","kindle_email":"bob-new@kindle.com","updated_at":"` + newer.Format(time.RFC3339) + `"}`,
+		`{"telegram_id":3,"first_name":"Carol","kindle_email":"carol@kindle.com","updated_at":"` + older.Format(time.RFC3339) + `"}`,
+		`{"telegram_id":4,"first_name":"Dave"}`,
+	}, "\n")
+
+	tests := []struct {
+		name        string
+		policy      ConflictPolicy
+		wantUpdated int
+		wantSkipped int
+		wantEmails  map[int64]string
+	}{
+		{
+			name:        "skip",
+			policy:      ConflictSkip,
+			wantSkipped: 2,
+			wantEmails:  map[int64]string{2: "bob@kindle.com", 3: "carol-old@kindle.com"},
+		},
+		{
+			name:        "overwrite",
+			policy:      ConflictOverwrite,
+			wantUpdated: 2,
+			wantEmails:  map[int64]string{2: "bob-new@kindle.com", 3: "carol@kindle.com"},
+		},
+		{
+			name:        "newer",
+			policy:      ConflictNewer,
+			wantUpdated: 1,
+			wantSkipped: 1,
+			wantEmails:  map[int64]string{2: "bob-new@kindle.com", 3: "carol-old@kindle.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := seedRepository(t,
+				&models.User{TelegramID: 1, ID: 1, FirstName: "Alice", KindleEmail: "alice@kindle.com"},
+				&models.User{TelegramID: 2, ID: 2, FirstName: "Bob", KindleEmail: "bob@kindle.com"},
+				&models.User{TelegramID: 3, ID: 3, FirstName: "Carol", KindleEmail: "carol-old@kindle.com"},
+			)
+
+			report, err := Import(ctx, repo, strings.NewReader(export), ImportOptions{OnConflict: tt.policy})
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+
+			if report.Created != 1 || report.Unchanged != 1 {
+				t.Errorf("Created = %d, Unchanged = %d, want 1 and 1", report.Created, report.Unchanged)
+			}
+			if report.Updated != tt.wantUpdated || report.Skipped != tt.wantSkipped {
+				t.Errorf("Updated = %d, Skipped = %d, want %d and %d", report.Updated, report.Skipped, tt.wantUpdated, tt.wantSkipped)
+			}
+			if len(report.Conflicts) != 2 {
+				t.Fatalf("Conflicts = %+v, want 2", report.Conflicts)
+			}
+			if !reflect.DeepEqual(report.Conflicts[0].Fields, []string{"kindle_email"}) {
+				t.Errorf("Conflict fields = %v, want [kindle_email]", report.Conflicts[0].Fields)
+			}
+
+			for id, want := range tt.wantEmails {
+				u, _ := repo.GetUser(ctx, id)
+				if u.KindleEmail != want {
+					t.Errorf("User %d KindleEmail = %v, want %v", id, u.KindleEmail, want)
+				}
+			}
+		})
+	}
+}
+
+func TestImport_NewerTwice(t *testing.T) {
+	ctx := context.Background()
+	january := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	export := func(email string, updated time.Time) string {
+		return `{"format":"flibusta-kindle-bot/users","version":1}` + "\n" +
+			`{"telegram_id":1,"first_name":"Alice","kindle_email":"` + email + `","updated_at":"` + updated.Format(time.RFC3339) + `"}`
+	}
+
+	repo := NewMemoryRepository()
+	if _, err := Import(ctx, repo, strings.NewReader(export("alice-jan@kindle.com", january)), ImportOptions{OnConflict: ConflictNewer}); err != nil {
+		t.Fatalf("Import(january) error = %v", err)
+	}
+	if u, _ := repo.GetUser(ctx, 1); !u.UpdatedAt.Equal(january) {
+		t.Errorf("UpdatedAt = %v after import, want %v", u.UpdatedAt, january)
+	}
+
+	// A later export wins over the earlier import, however long ago both were made
+	report, err := Import(ctx, repo, strings.NewReader(export("alice-jun@kindle.com", june)), ImportOptions{OnConflict: ConflictNewer})
+	if err != nil {
+		t.Fatalf("Import(june) error = %v", err)
+	}
+	if report.Updated != 1 {
+		t.Errorf("Report = %+v, want 1 updated", report)
+	}
+
+	// And an earlier one loses
+	report, err = Import(ctx, repo, strings.NewReader(export("alice-jan@kindle.com", january)), ImportOptions{OnConflict: ConflictNewer})
+	if err != nil {
+		t.Fatalf("Import(january again) error = %v", err)
+	}
+	if report.Skipped != 1 {
+		t.Errorf("Report = %+v, want 1 skipped", report)
+	}
+	if u, _ := repo.GetUser(ctx, 1); u.KindleEmail != "alice-jun@kindle.com" || !u.UpdatedAt.Equal(june) {
+		t.Errorf("User = %s updated %v, want the june version", u.KindleEmail, u.UpdatedAt)
+	}
+}
+
+func TestImport_DryRun(t *testing.T) {
+	ctx := context.Background()
+	repo := seedRepository(t, &models.User{TelegramID: 1, ID: 1, KindleEmail: "old@kindle.com"})
+
+	export := `{"format":"flibusta-kindle-bot/users","version":1}
+{"telegram_id":1,"kindle_email":"new@kindle.com"}
+{"telegram_id":2,"first_name":"Bob"}
+`
+	report, err := Import(ctx, repo, strings.NewReader(export), ImportOptions{DryRun: true, OnConflict: ConflictOverwrite})
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	if !report.DryRun || report.Created != 1 || report.Updated != 1 {
+		t.Errorf("Report = %+v, want dry run with 1 created and 1 updated", report)
+	}
+
+	// Nothing is written
+	if u, _ := repo.GetUser(ctx, 1); u.KindleEmail != "old@kindle.com" {
+		t.Errorf("KindleEmail = %v, want old@kindle.com", u.KindleEmail)
+	}
+	if _, err := repo.GetUser(ctx, 2); err != ErrUserNotFound {
+		t.Errorf("GetUser() error = %v, want %v", err, ErrUserNotFound)
+	}
+}
+
+func TestImport_InvalidRecords(t *testing.T) {
+	export := `{"format":"flibusta-kindle-bot/users","version":1}
+{"telegram_id":1}
+not json
+{"first_name":"Nobody"}
+{"telegram_id":1}
+`
+	report, err := Import(context.Background(), NewMemoryRepository(), strings.NewReader(export), ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	if report.Created != 1 {
+		t.Errorf("Created = %d, want 1", report.Created)
+	}
+
+	var lines []int
+	for _, e := range report.Errors {
+		lines = append(lines, e.Line)
+	}
+	if !reflect.DeepEqual(lines, []int{3, 4, 5}) {
+		t.Errorf("Error lines = %v, want [3 4 5]", lines)
+	}
+}
+
+func TestImport_InvalidHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "empty", input: ""},
+		{name: "no header", input: `{"telegram_id":1}`},
+		{name: "json array", input: `[{"telegram_id":1}]`},
+		{name: "future version", input: `{"format":"flibusta-kindle-bot/users","version":99}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Import(context.Background(), NewMemoryRepository(), strings.NewReader(tt.input), ImportOptions{}); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestParseConflictPolicy(t *testing.T) {
+	for _, s := range []string{"skip", "overwrite", "newer"} {
+		if _, err := ParseConflictPolicy(s); err != nil {
+			t.Errorf("ParseConflictPolicy(%q) error = %v", s, err)
+		}
+	}
+	if _, err := ParseConflictPolicy("merge"); err == nil {
+		t.Error("Expected error for unknown policy, got nil")
+	}
+}
@@ -3,9 +3,15 @@ package user
 import (
 	"context"
 	"errors"
-	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/destination"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/i18n"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/logging"
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/tracing"
 	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
 )
 
@@ -14,6 +20,9 @@ var (
 	ErrUserNotFound = errors.New("user not found")
 	// ErrInvalidEmail is returned when Kindle email format is invalid
 	ErrInvalidEmail = errors.New("invalid Kindle email format")
+	// ErrKindleEmailChanged is returned when verifying an address the user
+	// no longer has
+	ErrKindleEmailChanged = errors.New("Kindle email changed")
 )
 
 // Repository defines the interface for user storage
@@ -24,36 +33,108 @@ type Repository interface {
 	// SaveUser creates or updates a user
 	SaveUser(ctx context.Context, user *models.User) error
 
-	// UpdatePreferences updates user preferences
+	// ImportUser creates or replaces a user as is, keeping its UpdatedAt
+	// unless it is zero, so imports can be compared by age later
+	ImportUser(ctx context.Context, user *models.User) error
+
+	// UpdatePreferences updates user preferences. Changing the Kindle email
+	// clears the Kindle verification.
 	UpdatePreferences(ctx context.Context, telegramID int64, prefs *models.Preferences) error
 
+	// MarkKindleVerified marks the user's Kindle verified at the given time,
+	// unless their Kindle email is no longer email
+	MarkKindleVerified(ctx context.Context, telegramID int64, email string, at time.Time) error
+
 	// IncrementBooksSent increments the books sent counter
 	IncrementBooksSent(ctx context.Context, telegramID int64) error
 
-	// UpdateLastActive updates the last active timestamp
+	// UpdateLastActive updates the last active timestamp and marks the user
+	// active again
 	UpdateLastActive(ctx context.Context, telegramID int64) error
+
+	// DeactivateUsers marks active users not seen since the given time as
+	// inactive and returns how many were marked
+	DeactivateUsers(ctx context.Context, since time.Time) (int, error)
+
+	// CountActiveUsers counts users active since the given time
+	CountActiveUsers(ctx context.Context, since time.Time) (int, error)
+
+	// ListUsers returns all users ordered by Telegram ID
+	ListUsers(ctx context.Context) ([]*models.User, error)
+
+	// DeleteUser removes a user; deleting a missing user is not an error
+	DeleteUser(ctx context.Context, telegramID int64) error
+
+	// Ping checks that the storage backend is reachable
+	Ping(ctx context.Context) error
+}
+
+// Migrator is implemented by repositories whose storage schema needs migrating
+type Migrator interface {
+	// Migrate brings the storage schema up to date
+	Migrate(ctx context.Context) error
 }
 
 // Manager handles user operations
 type Manager struct {
-	repo Repository
+	repo         Repository
+	holders      []DataHolder
+	destinations *destination.Validator
+	languages    func() []string
 }
 
-// NewManager creates a new user manager
+// NewManager creates a new user manager accepting Kindle addresses
 func NewManager(repo Repository) *Manager {
 	return &Manager{
-		repo: repo,
+		repo:         repo,
+		destinations: destination.NewValidator(destination.Kindle),
+		languages:    i18n.EmbeddedLanguages,
+	}
+}
+
+// SetLanguages replaces the source of the languages new users are matched
+// against, such as translations that can be reloaded
+func (m *Manager) SetLanguages(languages func() []string) {
+	m.languages = languages
+}
+
+// SetDestinations replaces the validator deciding which e-reader addresses
+// users may set
+func (m *Manager) SetDestinations(v *destination.Validator) {
+	m.destinations = v
+}
+
+// Destination returns the destination an address delivers to
+func (m *Manager) Destination(address string) (*destination.Destination, error) {
+	_, d, err := m.destinations.Validate(address)
+	if err != nil {
+		return nil, ErrInvalidEmail
 	}
+	return d, nil
 }
 
 // GetOrCreateUser gets an existing user or creates a new one
-func (m *Manager) GetOrCreateUser(ctx context.Context, telegramID int64, username, firstName, lastName, langCode string) (*models.User, error) {
-	user, err := m.repo.GetUser(ctx, telegramID)
+func (m *Manager) GetOrCreateUser(ctx context.Context, telegramID int64, username, firstName, lastName, langCode string) (user *models.User, err error) {
+	ctx, span := startSpan(ctx, "user.GetOrCreateUser", telegramID)
+	defer func() { tracing.End(span, err) }()
+
+	user, err = m.repo.GetUser(ctx, telegramID)
+	if err == nil && !user.IsActive {
+		// Returning user marked inactive by maintenance
+		user.IsActive = true
+		user.LastActive = time.Now()
+		if err := m.repo.UpdateLastActive(ctx, telegramID); err != nil {
+			return nil, err
+		}
+		logging.FromContext(ctx).Info("Reactivated user")
+		return user, nil
+	}
 	if err == nil {
 		// User exists, update last active
 		user.LastActive = time.Now()
 		if err := m.repo.UpdateLastActive(ctx, telegramID); err != nil {
 			// Log error but don't fail
+			logging.FromContext(ctx).Warn("Failed to update last active time", "error", err)
 			return user, nil
 		}
 		return user, nil
@@ -70,7 +151,7 @@ func (m *Manager) GetOrCreateUser(ctx context.Context, telegramID int64, usernam
 		Username:   username,
 		FirstName:  firstName,
 		LastName:   lastName,
-		Language:   detectLanguage(langCode),
+		Language:   detectLanguage(langCode, m.languages()),
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 		LastActive: time.Now(),
@@ -82,13 +163,28 @@ func (m *Manager) GetOrCreateUser(ctx context.Context, telegramID int64, usernam
 		return nil, err
 	}
 
+	logging.FromContext(ctx).Info("Created new user", "language", user.Language)
+
 	return user, nil
 }
 
-// SetKindleEmail sets the user's Kindle email
-func (m *Manager) SetKindleEmail(ctx context.Context, telegramID int64, email string) error {
-	if err := ValidateKindleEmail(email); err != nil {
-		return err
+// GetUser returns an existing user
+func (m *Manager) GetUser(ctx context.Context, telegramID int64) (user *models.User, err error) {
+	ctx, span := startSpan(ctx, "user.GetUser", telegramID)
+	defer func() { tracing.End(span, err) }()
+
+	return m.repo.GetUser(ctx, telegramID)
+}
+
+// SetKindleEmail sets the user's e-reader email, which must belong to one of
+// the manager's destinations
+func (m *Manager) SetKindleEmail(ctx context.Context, telegramID int64, email string) (err error) {
+	ctx, span := startSpan(ctx, "user.SetKindleEmail", telegramID)
+	defer func() { tracing.End(span, err) }()
+
+	email, _, err = m.destinations.Validate(email)
+	if err != nil {
+		return ErrInvalidEmail
 	}
 
 	prefs := &models.Preferences{
@@ -99,7 +195,10 @@ func (m *Manager) SetKindleEmail(ctx context.Context, telegramID int64, email st
 }
 
 // SetLanguage sets the user's preferred language
-func (m *Manager) SetLanguage(ctx context.Context, telegramID int64, language string) error {
+func (m *Manager) SetLanguage(ctx context.Context, telegramID int64, language string) (err error) {
+	ctx, span := startSpan(ctx, "user.SetLanguage", telegramID)
+	defer func() { tracing.End(span, err) }()
+
 	prefs := &models.Preferences{
 		Language: language,
 	}
@@ -107,43 +206,57 @@ func (m *Manager) SetLanguage(ctx context.Context, telegramID int64, language st
 	return m.repo.UpdatePreferences(ctx, telegramID, prefs)
 }
 
-// RecordBookSent increments the books sent counter
-func (m *Manager) RecordBookSent(ctx context.Context, telegramID int64) error {
-	return m.repo.IncrementBooksSent(ctx, telegramID)
-}
+// MarkKindleVerified records that the user received a test document sent to
+// email on their Kindle. It fails with ErrKindleEmailChanged when the user
+// has set another address since.
+func (m *Manager) MarkKindleVerified(ctx context.Context, telegramID int64, email string) (err error) {
+	ctx, span := startSpan(ctx, "user.MarkKindleVerified", telegramID)
+	defer func() { tracing.End(span, err) }()
 
-// ValidateKindleEmail validates the format of a Kindle email
-func ValidateKindleEmail(email string) error {
 	if email == "" {
 		return ErrInvalidEmail
 	}
+	return m.repo.MarkKindleVerified(ctx, telegramID, email, time.Now())
+}
 
-	// Cannot contain spaces
-	if strings.Contains(email, " ") {
-		return ErrInvalidEmail
-	}
+// RecordBookSent increments the books sent counter
+func (m *Manager) RecordBookSent(ctx context.Context, telegramID int64) (err error) {
+	ctx, span := startSpan(ctx, "user.RecordBookSent", telegramID)
+	defer func() { tracing.End(span, err) }()
 
-	// Must end with @kindle.com
-	if len(email) < 11 || email[len(email)-11:] != "@kindle.com" {
-		return ErrInvalidEmail
-	}
+	return m.repo.IncrementBooksSent(ctx, telegramID)
+}
+
+// CountActiveUsers counts users who interacted with the bot within the given period
+func (m *Manager) CountActiveUsers(ctx context.Context, period time.Duration) (int, error) {
+	return m.repo.CountActiveUsers(ctx, time.Now().Add(-period))
+}
+
+// DeactivateInactiveUsers marks users not seen since the given time as
+// inactive and returns how many were marked. Users become active again on
+// their next interaction.
+func (m *Manager) DeactivateInactiveUsers(ctx context.Context, since time.Time) (int, error) {
+	return m.repo.DeactivateUsers(ctx, since)
+}
+
+// startSpan starts a span for a user operation
+func startSpan(ctx context.Context, name string, telegramID int64) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name, trace.WithAttributes(attribute.Int64("user.telegram_id", telegramID)))
+}
 
-	// Must have at least one character before @
-	if len(email) == 11 {
+// ValidateKindleEmail validates the format of a Send to Kindle email.
+// Every Amazon Kindle domain is accepted, case-insensitively.
+func ValidateKindleEmail(email string) error {
+	if _, _, err := destination.NewValidator(destination.Kindle).Validate(email); err != nil {
 		return ErrInvalidEmail
 	}
-
 	return nil
 }
 
 // detectLanguage detects user's language from Telegram language code
-func detectLanguage(langCode string) string {
-	// Simple detection based on language code
-	if len(langCode) >= 2 {
-		lang := langCode[:2]
-		if lang == "ru" {
-			return "ru"
-		}
+func detectLanguage(langCode string, languages []string) string {
+	if lang, ok := i18n.MatchLanguage(langCode, languages); ok {
+		return lang
 	}
 	return "en" // Default to English
 }
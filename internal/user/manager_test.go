@@ -3,7 +3,9 @@ package user
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/stpatrick2016/flibusta_kindle_bot/internal/destination"
 	"github.com/stpatrick2016/flibusta_kindle_bot/pkg/models"
 )
 
@@ -28,6 +30,26 @@ func TestValidateKindleEmail(t *testing.T) {
 			email:   "user.name@kindle.com",
 			wantErr: false,
 		},
+		{
+			name:    "valid free kindle email",
+			email:   "user@free.kindle.com",
+			wantErr: false,
+		},
+		{
+			name:    "valid regional kindle email",
+			email:   "user@kindle.cn",
+			wantErr: false,
+		},
+		{
+			name:    "valid - case insensitive domain",
+			email:   "User@Kindle.COM",
+			wantErr: false,
+		},
+		{
+			name:    "invalid - lookalike domain",
+			email:   "user@fakekindle.com",
+			wantErr: true,
+		},
 		{
 			name:    "invalid - wrong domain",
 			email:   "user@gmail.com",
@@ -94,6 +116,11 @@ func TestDetectLanguage(t *testing.T) {
 			langCode: "en-US",
 			expected: "en",
 		},
+		{
+			name:     "ukrainian",
+			langCode: "uk-UA",
+			expected: "uk",
+		},
 		{
 			name:     "other language defaults to english",
 			langCode: "fr",
@@ -108,7 +135,7 @@ func TestDetectLanguage(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := detectLanguage(tt.langCode)
+			result := detectLanguage(tt.langCode, []string{"en", "ru", "uk"})
 			if result != tt.expected {
 				t.Errorf("detectLanguage(%q) = %v, want %v", tt.langCode, result, tt.expected)
 			}
@@ -376,3 +403,117 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestManager_CountActiveUsers(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	manager := NewManager(repo)
+
+	// Create an active user and a user last seen long ago
+	manager.GetOrCreateUser(ctx, 1, "active", "Active", "User", "en")
+	repo.SaveUser(ctx, &models.User{TelegramID: 2, LastActive: time.Now().Add(-48 * time.Hour)})
+
+	count, err := manager.CountActiveUsers(ctx, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("CountActiveUsers() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("CountActiveUsers() = %v, want %v", count, 1)
+	}
+}
+
+func TestManager_DeactivateInactiveUsers(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	manager := NewManager(repo)
+
+	manager.GetOrCreateUser(ctx, 1, "active", "Active", "User", "en")
+	repo.SaveUser(ctx, &models.User{TelegramID: 2, IsActive: true, LastActive: time.Now().Add(-48 * time.Hour)})
+	repo.SaveUser(ctx, &models.User{TelegramID: 3, IsActive: false, LastActive: time.Now().Add(-48 * time.Hour)})
+
+	count, err := manager.DeactivateInactiveUsers(ctx, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("DeactivateInactiveUsers() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("DeactivateInactiveUsers() = %v, want %v", count, 1)
+	}
+
+	for id, want := range map[int64]bool{1: true, 2: false, 3: false} {
+		user, _ := repo.GetUser(ctx, id)
+		if user.IsActive != want {
+			t.Errorf("user %d IsActive = %v, want %v", id, user.IsActive, want)
+		}
+	}
+
+	// An inactive user becomes active again on the next interaction
+	user, err := manager.GetOrCreateUser(ctx, 2, "", "", "", "en")
+	if err != nil {
+		t.Fatalf("GetOrCreateUser() error = %v", err)
+	}
+	stored, _ := repo.GetUser(ctx, 2)
+	if !user.IsActive || !stored.IsActive {
+		t.Errorf("IsActive = %v (stored %v), want reactivated", user.IsActive, stored.IsActive)
+	}
+}
+
+func TestManager_MarkKindleVerified(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager(NewMemoryRepository())
+
+	manager.GetOrCreateUser(ctx, 1, "reader", "Reader", "", "en")
+	if err := manager.MarkKindleVerified(ctx, 1, ""); err != ErrInvalidEmail {
+		t.Errorf("MarkKindleVerified() without email error = %v, want ErrInvalidEmail", err)
+	}
+
+	// The address tested must still be the user's
+	manager.SetKindleEmail(ctx, 1, "reader@kindle.com")
+	if err := manager.MarkKindleVerified(ctx, 1, "old@kindle.com"); err != ErrKindleEmailChanged {
+		t.Errorf("MarkKindleVerified() of an old address error = %v, want ErrKindleEmailChanged", err)
+	}
+	if user, _ := manager.GetUser(ctx, 1); user.KindleVerified {
+		t.Error("KindleVerified after verifying an old address")
+	}
+
+	if err := manager.MarkKindleVerified(ctx, 1, "reader@kindle.com"); err != nil {
+		t.Fatalf("MarkKindleVerified() error = %v", err)
+	}
+	user, _ := manager.GetUser(ctx, 1)
+	if !user.KindleVerified || user.KindleVerifiedAt.IsZero() {
+		t.Errorf("KindleVerified = %v at %v, want verified", user.KindleVerified, user.KindleVerifiedAt)
+	}
+
+	// Setting the same address keeps the verification, a new one clears it
+	manager.SetKindleEmail(ctx, 1, "reader@kindle.com")
+	if user, _ := manager.GetUser(ctx, 1); !user.KindleVerified {
+		t.Error("verification cleared by setting the same email")
+	}
+	manager.SetKindleEmail(ctx, 1, "other@kindle.com")
+	if user, _ := manager.GetUser(ctx, 1); user.KindleVerified || !user.KindleVerifiedAt.IsZero() {
+		t.Error("verification kept after changing the email")
+	}
+}
+
+func TestManager_SetKindleEmail_Destinations(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager(NewMemoryRepository())
+	manager.GetOrCreateUser(ctx, 1, "reader", "Reader", "", "en")
+
+	// Only Kindle addresses are accepted by default
+	if err := manager.SetKindleEmail(ctx, 1, "reader@pbsync.com"); err != ErrInvalidEmail {
+		t.Errorf("SetKindleEmail(pocketbook) error = %v, want ErrInvalidEmail", err)
+	}
+
+	manager.SetDestinations(destination.NewValidator(destination.Kindle, destination.PocketBook))
+	if err := manager.SetKindleEmail(ctx, 1, "Reader@PBSync.com"); err != nil {
+		t.Fatalf("SetKindleEmail(pocketbook) error = %v", err)
+	}
+
+	user, _ := manager.GetUser(ctx, 1)
+	if user.KindleEmail != "Reader@pbsync.com" {
+		t.Errorf("KindleEmail = %q, want domain normalized", user.KindleEmail)
+	}
+	if d, err := manager.Destination(user.KindleEmail); err != nil || d.Name != "pocketbook" {
+		t.Errorf("Destination() = %v, %v, want pocketbook", d, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -53,6 +54,20 @@ func (r *MemoryRepository) SaveUser(ctx context.Context, user *models.User) erro
 	return nil
 }
 
+// ImportUser creates or replaces a user, keeping its UpdatedAt
+func (r *MemoryRepository) ImportUser(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userCopy := *user
+	if userCopy.UpdatedAt.IsZero() {
+		userCopy.UpdatedAt = time.Now()
+	}
+	r.users[user.TelegramID] = &userCopy
+
+	return nil
+}
+
 // UpdatePreferences updates user preferences
 func (r *MemoryRepository) UpdatePreferences(ctx context.Context, telegramID int64, prefs *models.Preferences) error {
 	r.mu.Lock()
@@ -63,8 +78,11 @@ func (r *MemoryRepository) UpdatePreferences(ctx context.Context, telegramID int
 		return ErrUserNotFound
 	}
 
-	if prefs.KindleEmail != "" {
+	if prefs.KindleEmail != "" && prefs.KindleEmail != user.KindleEmail {
+		// A new address has to be verified again
 		user.KindleEmail = prefs.KindleEmail
+		user.KindleVerified = false
+		user.KindleVerifiedAt = time.Time{}
 	}
 	if prefs.Language != "" {
 		user.Language = prefs.Language
@@ -74,6 +92,26 @@ func (r *MemoryRepository) UpdatePreferences(ctx context.Context, telegramID int
 	return nil
 }
 
+// MarkKindleVerified marks the user's Kindle verified, unless their Kindle
+// email is no longer email
+func (r *MemoryRepository) MarkKindleVerified(ctx context.Context, telegramID int64, email string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, exists := r.users[telegramID]
+	if !exists {
+		return ErrUserNotFound
+	}
+	if user.KindleEmail != email {
+		return ErrKindleEmailChanged
+	}
+
+	user.KindleVerified = true
+	user.KindleVerifiedAt = at
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
 // IncrementBooksSent increments the books sent counter
 func (r *MemoryRepository) IncrementBooksSent(ctx context.Context, telegramID int64) error {
 	r.mu.Lock()
@@ -89,7 +127,7 @@ func (r *MemoryRepository) IncrementBooksSent(ctx context.Context, telegramID in
 	return nil
 }
 
-// UpdateLastActive updates the last active timestamp
+// UpdateLastActive updates the last active timestamp and marks the user active
 func (r *MemoryRepository) UpdateLastActive(ctx context.Context, telegramID int64) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -100,10 +138,73 @@ func (r *MemoryRepository) UpdateLastActive(ctx context.Context, telegramID int6
 	}
 
 	user.LastActive = time.Now()
+	user.IsActive = true
+	return nil
+}
+
+// DeactivateUsers marks active users not seen since the given time as inactive
+func (r *MemoryRepository) DeactivateUsers(ctx context.Context, since time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for _, user := range r.users {
+		if user.IsActive && user.LastActive.Before(since) {
+			user.IsActive = false
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// CountActiveUsers counts users active since the given time
+func (r *MemoryRepository) CountActiveUsers(ctx context.Context, since time.Time) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, user := range r.users {
+		if !user.LastActive.Before(since) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// ListUsers returns all users ordered by Telegram ID
+func (r *MemoryRepository) ListUsers(ctx context.Context) ([]*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]*models.User, 0, len(r.users))
+	for _, user := range r.users {
+		userCopy := *user
+		users = append(users, &userCopy)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].TelegramID < users[j].TelegramID })
+
+	return users, nil
+}
+
+// DeleteUser removes a user; deleting a missing user is not an error
+func (r *MemoryRepository) DeleteUser(ctx context.Context, telegramID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.users, telegramID)
+	return nil
+}
+
+// Ping checks that the storage backend is reachable
+func (r *MemoryRepository) Ping(ctx context.Context) error {
 	return nil
 }
 
-// ExportData exports all users as JSON (for debugging)
+// ExportData exports all users as JSON (for debugging).
+//
+// Deprecated: use Export, which writes the portable format read by Import.
 func (r *MemoryRepository) ExportData() (string, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -11,6 +11,9 @@ type Book struct {
 	ID          string    `json:"id"`
 	Title       string    `json:"title"`
 	Author      string    `json:"author"`
+	AuthorID    string    `json:"author_id,omitempty"` // Flibusta ID of the first author
+	Series      string    `json:"series,omitempty"`
+	SeriesID    string    `json:"series_id,omitempty"`
 	Format      string    `json:"format"`
 	Size        int64     `json:"size"`
 	URL         string    `json:"url"`
@@ -57,3 +60,12 @@ func (b *Book) GetDownloadURL() string {
 func (b *Book) String() string {
 	return fmt.Sprintf("%s by %s (%s, %s)", b.Title, b.Author, b.Format, b.FormatSize())
 }
+
+// SavedBook is a book on a user's reading list, waiting to be sent
+type SavedBook struct {
+	BookID  string    `json:"book_id"`
+	Title   string    `json:"title,omitempty"`
+	Author  string    `json:"author,omitempty"`
+	Format  string    `json:"format,omitempty"` // Empty selects the destination's preferred format
+	AddedAt time.Time `json:"added_at"`
+}
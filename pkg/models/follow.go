@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Kinds of entities users can follow
+const (
+	FollowAuthor = "author"
+	FollowSeries = "series"
+)
+
+// Follow is an author or series a user wants to hear about
+type Follow struct {
+	Kind        string    `json:"kind"` // FollowAuthor or FollowSeries
+	EntityID    string    `json:"entity_id"`
+	Name        string    `json:"name,omitempty"` // Filled in from the first release seen
+	AutoDeliver bool      `json:"auto_deliver"`   // Send new books to the Kindle without asking
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -17,6 +17,11 @@ type User struct {
 	LastActive  time.Time `json:"last_active"` // Last interaction time
 	IsActive    bool      `json:"is_active"`   // Is user active
 	IsBanned    bool      `json:"is_banned"`   // Is user banned
+
+	// Set once the user confirms a test document arrived, proving our sender
+	// address is whitelisted for KindleEmail
+	KindleVerified   bool      `json:"kindle_verified"`
+	KindleVerifiedAt time.Time `json:"kindle_verified_at"`
 }
 
 // Preferences represents user preferences
@@ -44,13 +49,14 @@ func (u *User) HasKindleEmail() bool {
 	return u.KindleEmail != ""
 }
 
-// IsValidLanguage checks if the language code is supported
-func (u *User) IsValidLanguage() bool {
-	validLanguages := map[string]bool{
-		"en": true,
-		"ru": true,
+// IsValidLanguage checks if the user's language is one of the supported ones
+func (u *User) IsValidLanguage(supported []string) bool {
+	for _, lang := range supported {
+		if u.Language == lang {
+			return true
+		}
 	}
-	return validLanguages[u.Language]
+	return false
 }
 
 // GetDisplayName returns the user's display name
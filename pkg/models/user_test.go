@@ -105,6 +105,11 @@ func TestUser_IsValidLanguage(t *testing.T) {
 			language: "ru",
 			expected: true,
 		},
+		{
+			name:     "ukrainian",
+			language: "uk",
+			expected: true,
+		},
 		{
 			name:     "invalid language",
 			language: "fr",
@@ -120,7 +125,7 @@ func TestUser_IsValidLanguage(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			user := &User{Language: tt.language}
-			result := user.IsValidLanguage()
+			result := user.IsValidLanguage([]string{"en", "ru", "uk"})
 			if result != tt.expected {
 				t.Errorf("IsValidLanguage() for %q = %v, want %v", tt.language, result, tt.expected)
 			}
@@ -26,6 +26,11 @@ resource "azurerm_container_app" "bot" {
   resource_group_name          = azurerm_resource_group.main.name
   revision_mode                = "Single"
   
+  # Used by the bot to read Key Vault secrets
+  identity {
+    type = "SystemAssigned"
+  }
+  
   template {
     min_replicas = var.min_replicas
     max_replicas = var.max_replicas
@@ -36,9 +41,10 @@ resource "azurerm_container_app" "bot" {
       cpu    = var.container_cpu
       memory = var.container_memory
       
+      # Resolved by the bot at startup and refreshed periodically
       env {
         name  = "TELEGRAM_BOT_TOKEN"
-        secret_name = "telegram-bot-token"
+        value = "@Microsoft.KeyVault(SecretUri=${azurerm_key_vault_secret.telegram_bot_token.versionless_id})"
       }
       
       env {
@@ -73,11 +79,6 @@ resource "azurerm_container_app" "bot" {
     }
   }
   
-  secret {
-    name  = "telegram-bot-token"
-    value = var.telegram_bot_token
-  }
-  
   secret {
     name  = "acs-connection-string"
     value = azurerm_communication_service.main.primary_connection_string
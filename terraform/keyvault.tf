@@ -29,6 +29,17 @@ resource "azurerm_key_vault_access_policy" "deployer" {
   ]
 }
 
+# Key Vault Access Policy for the bot's managed identity
+resource "azurerm_key_vault_access_policy" "bot" {
+  key_vault_id = azurerm_key_vault.main.id
+  tenant_id    = azurerm_container_app.bot.identity[0].tenant_id
+  object_id    = azurerm_container_app.bot.identity[0].principal_id
+  
+  secret_permissions = [
+    "Get"
+  ]
+}
+
 # Store Telegram Bot Token in Key Vault
 resource "azurerm_key_vault_secret" "telegram_bot_token" {
   name         = "telegram-bot-token"